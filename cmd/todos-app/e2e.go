@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/urfave/cli/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var e2eCommand = &cli.Command{
+	Name:  "e2e",
+	Usage: "Run a smoke test of the API against a deployment",
+	Description: "Exercises login, create, cached and uncached reads, conditional updates,\n" +
+		"listing, paging, search and delete against --base-url and prints a TAP report.\n" +
+		"Every todo it creates carries an e2e-<run id> tag and is deleted and purged\n" +
+		"again, even when a step fails.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "base-url",
+			Required: true,
+			Usage:    "URL the API is served on, e.g. https://staging.example.com",
+		},
+		&cli.StringFlag{
+			Name:    "token",
+			EnvVars: []string{"E2E_TOKEN"},
+			Usage:   "JWT to authenticate with instead of logging in",
+		},
+		&cli.StringFlag{
+			Name:    "username",
+			EnvVars: []string{"E2E_USERNAME"},
+			Usage:   "user to log in as when no token is given",
+		},
+		&cli.StringFlag{
+			Name:    "password",
+			EnvVars: []string{"E2E_PASSWORD"},
+			Usage:   "password to log in with when no token is given",
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Value: 10 * time.Second,
+			Usage: "timeout for each request",
+		},
+		&cli.DurationFlag{
+			Name:  "cache-wait",
+			Value: 5 * time.Second,
+			Usage: "how long cached reads may take to reflect an update",
+		},
+	},
+	Action: runE2E,
+}
+
+// e2eClient calls the API of the deployment under test.
+type e2eClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// do sends body as JSON, decodes a JSON response into out if given, and
+// returns the response status.
+func (e *e2eClient) do(ctx context.Context, method string, path string, body interface{}, out interface{}) (int, error) {
+	status, _, err := e.doWithHeaders(ctx, method, path, nil, body, out)
+	return status, err
+}
+
+// doWithHeaders is do with extra request headers, also returning the
+// response headers.
+func (e *e2eClient) doWithHeaders(ctx context.Context, method string, path string, header http.Header, body interface{}, out interface{}) (int, http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, e.baseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.token)
+	}
+
+	resp, err := e.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, resp.Header, fmt.Errorf("decoding response to %s %s: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, resp.Header, nil
+}
+
+func expectStatus(got int, want int) error {
+	if got != want {
+		return fmt.Errorf("expected status %d, got %d", want, got)
+	}
+	return nil
+}
+
+type e2eStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func runE2E(c *cli.Context) error {
+	client := &e2eClient{
+		baseURL: strings.TrimRight(c.String("base-url"), "/"),
+		token:   c.String("token"),
+		http:    &http.Client{Timeout: c.Duration("timeout")},
+	}
+	runId := primitive.NewObjectID().Hex()
+	tag := "e2e-" + runId
+	text := "e2e smoke test " + runId
+
+	var created []string
+	var todo, second model.Todo
+	var etag string
+	var uncached int
+	// read fetches the todo, bypassing the URI-keyed response cache unless
+	// cached is set, and remembers its ETag.
+	read := func(ctx context.Context, cached bool) (*model.Todo, error) {
+		path := "/api/v1/todos/" + todo.ID.Hex()
+		if !cached {
+			uncached++
+			path += fmt.Sprintf("?e2e=%s-%d", runId, uncached)
+		}
+		var t model.Todo
+		status, header, err := client.doWithHeaders(ctx, http.MethodGet, path, nil, nil, &t)
+		if err != nil {
+			return nil, err
+		}
+		if err := expectStatus(status, http.StatusOK); err != nil {
+			return nil, err
+		}
+		etag = header.Get("ETag")
+		return &t, nil
+	}
+	create := func(ctx context.Context, text string, out *model.Todo) error {
+		status, err := client.do(ctx, http.MethodPost, "/api/v1/todos", map[string]interface{}{
+			"text": text,
+			"tags": []string{tag},
+		}, out)
+		if err != nil {
+			return err
+		}
+		if err := expectStatus(status, http.StatusCreated); err != nil {
+			return err
+		}
+		created = append(created, out.ID.Hex())
+		return nil
+	}
+	// runTodos checks that ids are exactly the todos the run created.
+	runTodos := func(ids []primitive.ObjectID) error {
+		want := []string{todo.ID.Hex(), second.ID.Hex()}
+		got := make([]string, len(ids))
+		for i, id := range ids {
+			got[i] = id.Hex()
+		}
+		slices.Sort(want)
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			return fmt.Errorf("expected todos %v, got %v", want, got)
+		}
+		return nil
+	}
+
+	steps := []e2eStep{
+		{"log in", func(ctx context.Context) error {
+			if client.token != "" {
+				return nil
+			}
+			var login struct {
+				Token string `json:"token"`
+			}
+			status, err := client.do(ctx, http.MethodPost, "/api/v1/login", map[string]string{
+				"username": c.String("username"),
+				"password": c.String("password"),
+			}, &login)
+			if err != nil {
+				return err
+			}
+			if err := expectStatus(status, http.StatusOK); err != nil {
+				return err
+			}
+			client.token = login.Token
+			return nil
+		}},
+		{"create a todo", func(ctx context.Context) error {
+			return create(ctx, text, &todo)
+		}},
+		{"create a second todo", func(ctx context.Context) error {
+			return create(ctx, text+" second", &second)
+		}},
+		{"read the todo uncached", func(ctx context.Context) error {
+			t, err := read(ctx, false)
+			if err != nil {
+				return err
+			}
+			if t.Text != text {
+				return fmt.Errorf("expected text %q, got %q", text, t.Text)
+			}
+			if etag == "" {
+				return errors.New("expected an ETag header")
+			}
+			return nil
+		}},
+		{"read the todo twice through the cache", func(ctx context.Context) error {
+			first, err := read(ctx, true)
+			if err != nil {
+				return err
+			}
+			again, err := read(ctx, true)
+			if err != nil {
+				return err
+			}
+			if first.Text != again.Text || !first.UpdatedAt.Equal(again.UpdatedAt) {
+				return errors.New("cached reads returned different todos")
+			}
+			return nil
+		}},
+		{"update the todo with If-Match", func(ctx context.Context) error {
+			status, _, err := client.doWithHeaders(ctx, http.MethodPut, "/api/v1/todos/"+todo.ID.Hex(), http.Header{"If-Match": {etag}}, map[string]interface{}{
+				"text":      text + " updated",
+				"completed": true,
+				"tags":      []string{tag},
+			}, nil)
+			if err != nil {
+				return err
+			}
+			return expectStatus(status, http.StatusNoContent)
+		}},
+		{"refuse an update with a stale If-Match", func(ctx context.Context) error {
+			status, _, err := client.doWithHeaders(ctx, http.MethodPut, "/api/v1/todos/"+todo.ID.Hex(), http.Header{"If-Match": {etag}}, map[string]interface{}{
+				"text": text + " overwritten",
+				"tags": []string{tag},
+			}, nil)
+			if err != nil {
+				return err
+			}
+			return expectStatus(status, http.StatusPreconditionFailed)
+		}},
+		{"see the update through the cache", func(ctx context.Context) error {
+			// Writes invalidate the cache, so the update should show at
+			// once; cache-wait only allows for replication lag.
+			deadline := time.Now().Add(c.Duration("cache-wait"))
+			for {
+				t, err := read(ctx, true)
+				if err != nil {
+					return err
+				}
+				if t.Completed {
+					return nil
+				}
+				if time.Now().After(deadline) {
+					return fmt.Errorf("cached read still stale after %s", c.Duration("cache-wait"))
+				}
+				time.Sleep(250 * time.Millisecond)
+			}
+		}},
+		{"read the update uncached", func(ctx context.Context) error {
+			t, err := read(ctx, false)
+			if err != nil {
+				return err
+			}
+			if !t.Completed || t.CompletedAt == nil {
+				return errors.New("expected the todo to be completed with completed_at set")
+			}
+			if t.Text != text+" updated" {
+				return fmt.Errorf("expected text %q, got %q", text+" updated", t.Text)
+			}
+			return nil
+		}},
+		{"list todos by the run's tag", func(ctx context.Context) error {
+			var todos []model.Todo
+			status, err := client.do(ctx, http.MethodGet, "/api/v1/todos?include_archived=true&tag="+url.QueryEscape(tag), nil, &todos)
+			if err != nil {
+				return err
+			}
+			if err := expectStatus(status, http.StatusOK); err != nil {
+				return err
+			}
+			ids := make([]primitive.ObjectID, len(todos))
+			for i, t := range todos {
+				ids[i] = t.ID
+			}
+			return runTodos(ids)
+		}},
+		{"page through the run's todos one at a time", func(ctx context.Context) error {
+			var ids []primitive.ObjectID
+			cursor := ""
+			for page := 0; page < 3; page++ {
+				var body struct {
+					Todos      []model.Todo `json:"todos"`
+					NextCursor string       `json:"next_cursor"`
+				}
+				path := "/api/v1/todos?include_archived=true&limit=1&tag=" + url.QueryEscape(tag) + "&after=" + cursor
+				status, err := client.do(ctx, http.MethodGet, path, nil, &body)
+				if err != nil {
+					return err
+				}
+				if err := expectStatus(status, http.StatusOK); err != nil {
+					return err
+				}
+				if len(body.Todos) > 1 {
+					return fmt.Errorf("expected at most 1 todo per page, got %d", len(body.Todos))
+				}
+				for _, t := range body.Todos {
+					ids = append(ids, t.ID)
+				}
+				if body.NextCursor == "" {
+					return runTodos(ids)
+				}
+				cursor = body.NextCursor
+			}
+			return errors.New("expected 2 pages, got more")
+		}},
+		{"search for the run's todos", func(ctx context.Context) error {
+			var todos []model.Todo
+			status, err := client.do(ctx, http.MethodGet, "/api/v1/todos/search?q="+url.QueryEscape(runId), nil, &todos)
+			if err != nil {
+				return err
+			}
+			if err := expectStatus(status, http.StatusOK); err != nil {
+				return err
+			}
+			ids := make([]primitive.ObjectID, len(todos))
+			for i, t := range todos {
+				ids[i] = t.ID
+			}
+			return runTodos(ids)
+		}},
+		{"delete the todo", func(ctx context.Context) error {
+			status, err := client.do(ctx, http.MethodDelete, "/api/v1/todos/"+todo.ID.Hex(), nil, nil)
+			if err != nil {
+				return err
+			}
+			return expectStatus(status, http.StatusNoContent)
+		}},
+		{"no longer read the deleted todo", func(ctx context.Context) error {
+			uncached++
+			path := fmt.Sprintf("/api/v1/todos/%s?e2e=%s-%d", todo.ID.Hex(), runId, uncached)
+			status, err := client.do(ctx, http.MethodGet, path, nil, nil)
+			if err != nil {
+				return err
+			}
+			return expectStatus(status, http.StatusNotFound)
+		}},
+		{"purge the todo from the trash", func(ctx context.Context) error {
+			status, err := client.do(ctx, http.MethodDelete, "/api/v1/todos/trash/"+todo.ID.Hex(), nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := expectStatus(status, http.StatusNoContent); err != nil {
+				return err
+			}
+			created = slices.DeleteFunc(created, func(id string) bool { return id == todo.ID.Hex() })
+			return nil
+		}},
+	}
+
+	ctx := c.Context
+	failed := false
+	fmt.Println("TAP version 13")
+	fmt.Printf("1..%d\n", len(steps))
+	for i, step := range steps {
+		if failed {
+			fmt.Printf("ok %d - %s # SKIP an earlier step failed\n", i+1, step.name)
+			continue
+		}
+		if err := step.run(ctx); err != nil {
+			failed = true
+			fmt.Printf("not ok %d - %s\n", i+1, step.name)
+			fmt.Printf("  ---\n  message: %q\n  ...\n", err.Error())
+			continue
+		}
+		fmt.Printf("ok %d - %s\n", i+1, step.name)
+	}
+
+	// Clean up whatever the run left behind, purging it from the trash so
+	// runs leave no trace.
+	for _, id := range created {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), c.Duration("timeout"))
+		if _, err := client.do(cleanupCtx, http.MethodDelete, "/api/v1/todos/"+id, nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Cleaning up todo %s failed: %v\n", id, err)
+		} else if status, err := client.do(cleanupCtx, http.MethodDelete, "/api/v1/todos/trash/"+id, nil, nil); err != nil || status != http.StatusNoContent {
+			fmt.Fprintf(os.Stderr, "Purging todo %s failed: status %d, %v\n", id, status, err)
+		}
+		cancel()
+	}
+
+	if failed {
+		return cli.Exit("e2e run "+runId+" failed", 1)
+	}
+	return nil
+}
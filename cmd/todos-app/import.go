@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/urfave/cli/v2"
+)
+
+var importCommand = &cli.Command{
+	Name:      "import",
+	Usage:     "Import todos from a file",
+	ArgsUsage: "<file, or - for stdin>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "csv",
+			Usage: "format of the file, only csv is supported",
+		},
+		&cli.StringFlag{
+			Name:  "map",
+			Usage: `columns to read fields from, e.g. "text=Task,completed=Done,due_date=Due,tags=Labels"`,
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "validate the file without importing anything",
+		},
+		&cli.IntFlag{
+			Name:  "concurrency",
+			Value: 4,
+			Usage: "number of batches to insert in parallel",
+		},
+		waitFlag,
+	},
+	Action: importTodos,
+}
+
+func importTodos(c *cli.Context) error {
+	if format := c.String("format"); format != "csv" {
+		return fmt.Errorf("unsupported format %q, only csv is supported", format)
+	}
+
+	path := c.Args().First()
+	if path == "" {
+		return errors.New("a file to import is required, use - to read from stdin")
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	reader, err := model.NewCSVReader(in)
+	if err != nil {
+		return err
+	}
+
+	mapping := reader.DefaultColumnMapping()
+	if spec := c.String("map"); spec != "" {
+		mapping, err = model.ParseColumnMapping(spec)
+		if err != nil {
+			return err
+		}
+	} else if _, ok := mapping["text"]; !ok && path != "-" {
+		mapping, err = promptColumnMapping(reader.Header())
+		if err != nil {
+			return err
+		}
+	}
+	if err := reader.SetMapping(mapping); err != nil {
+		return err
+	}
+
+	if c.Int("concurrency") < 1 {
+		return errors.New("--concurrency must be at least 1")
+	}
+
+	// On Ctrl-C, batches already being written are finished and the rest of
+	// the file is skipped.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	dryRun := c.Bool("dry-run")
+	if !dryRun {
+		lock, err := acquireBatchLock(ctx, c, "import")
+		if err != nil {
+			return err
+		}
+		defer lock.Release(context.Background())
+	}
+
+	result, err := model.ImportTodos(ctx, repository(c), reader, model.ImportOptions{
+		DryRun:      dryRun,
+		Concurrency: c.Int("concurrency"),
+	})
+	for _, rowErr := range result.Errors {
+		fmt.Fprintln(os.Stderr, rowErr)
+	}
+
+	if result.Interrupted {
+		fmt.Println("Interrupted, the remaining rows were skipped")
+	}
+	if dryRun {
+		fmt.Printf("%d todos would be imported, %d rows have errors\n", result.Imported, len(result.Errors))
+	} else {
+		fmt.Printf("Imported %d todos, %d rows have errors\n", result.Imported, len(result.Errors))
+	}
+	return err
+}
+
+// promptColumnMapping asks which column holds each importable field.
+func promptColumnMapping(header []string) (map[string]string, error) {
+	fmt.Printf("Columns: %s\n", strings.Join(header, ", "))
+
+	scanner := bufio.NewScanner(os.Stdin)
+	mapping := map[string]string{}
+	for _, field := range model.ImportFields {
+		fmt.Printf("Column for %s (leave empty to skip): ", field)
+		if !scanner.Scan() {
+			break
+		}
+		if column := strings.TrimSpace(scanner.Text()); column != "" {
+			mapping[field] = column
+		}
+	}
+
+	return mapping, scanner.Err()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/urfave/cli/v2"
+)
+
+// lastListing remembers which todo each number in the most recent listing
+// referred to, so commands can take "3" instead of an id.
+type lastListing struct {
+	Namespace string            `json:"namespace"`
+	IDs       map[string]string `json:"ids"`
+}
+
+func lastListingPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todos-app", "last-listing.json"), nil
+}
+
+// saveLastListing records the todos printed by a listing, keyed by the
+// number they were printed with.
+func saveLastListing(listing *lastListing) error {
+	path, err := lastListingPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(listing)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// todoIdFromListing resolves a number printed by the last listing of the
+// current collection to the todo's id.
+func todoIdFromListing(c *cli.Context, arg string) (string, error) {
+	if _, err := strconv.Atoi(arg); err != nil {
+		return "", fmt.Errorf("%q is not a number from the last listing", arg)
+	}
+
+	path, err := lastListingPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errors.New("no listing to pick from, list your todos first")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var listing lastListing
+	if err := json.Unmarshal(data, &listing); err != nil {
+		return "", err
+	}
+	if namespace := repository(c).Namespace(); listing.Namespace != namespace {
+		return "", fmt.Errorf("the last listing was of %s, list %s first", listing.Namespace, namespace)
+	}
+
+	id, ok := listing.IDs[arg]
+	if !ok {
+		return "", fmt.Errorf("the last listing has no todo %s", arg)
+	}
+	return id, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/urfave/cli/v2"
+)
+
+var waitFlag = &cli.BoolFlag{
+	Name:  "wait",
+	Usage: "wait for another batch job to finish instead of failing",
+}
+
+// acquireBatchLock takes model.BatchLock for job, failing straight away
+// with the holder's name unless --wait was given.
+func acquireBatchLock(ctx context.Context, c *cli.Context, job string) (*model.Lock, error) {
+	holder := model.LockHolder(job)
+	if !c.Bool("wait") {
+		lock, err := repository(c).AcquireLock(ctx, model.BatchLock, holder)
+		if err != nil {
+			return nil, fmt.Errorf("%w, retry with --wait to queue behind it", err)
+		}
+		return lock, nil
+	}
+
+	return model.WaitForLock(ctx, repository(c), model.BatchLock, holder, func(held *model.LockHeldError) {
+		fmt.Fprintf(os.Stderr, "Waiting for %s to release the %s lock\n", held.Holder, held.Name)
+	})
+}
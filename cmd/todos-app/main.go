@@ -2,10 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	golangtodomanager "github.com/CharlesPatterson/todos-app"
@@ -23,35 +31,131 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
 
 	"github.com/urfave/cli/v2"
 )
 
+const (
+	healthHistorySize     = 120
+	healthMonitorInterval = 30 * time.Second
+	agingInterval         = time.Hour
+	reminderInterval      = time.Minute
+	usageFlushInterval    = time.Hour
+	shutdownTimeout       = 10 * time.Second
+)
+
+// repository returns the store the CLI commands and the server work on,
+// connecting to MongoDB the first time a command asks for it so commands
+// that never touch the database, such as e2e, run without one. --collection
+// picks an existing collection instead of DB_COLLECTION_NAME.
+func repository(c *cli.Context) *model.MongoRepository {
+	if repo, ok := c.App.Metadata["repository"].(*model.MongoRepository); ok {
+		return repo
+	}
+
+	coll := model.Connect()
+	if name := c.String("collection"); name != "" {
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var err error
+		if coll, err = model.UseCollection(ctx, coll.Database(), name, false); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	repo := model.NewMongoRepository(coll)
+	if c.App.Metadata == nil {
+		c.App.Metadata = map[string]interface{}{}
+	}
+	c.App.Metadata["repository"] = repo
+	return repo
+}
+
 // @Summary	Login
 // @ID			login
 // @Tags		Auth
 // @Produce	json
 // @Param		data	body		middleware.Login	true	"Login credentials"
 // @Success	200		{object}	model.Todo
+// @Failure	401		{object}	controller.UnauthorizedResponse
 // @Router		/login [post]
-func runServer() {
+func runServer(repo *model.MongoRepository) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config := model.LoadConfig()
+	if err := config.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
 	cacheConfig := model.SetupRedisCache()
+	log.Printf("Starting version=%s %s", golangtodomanager.Version, config.Summary())
+	log.Printf("Using MongoDB namespace %s", repo.Namespace())
 
+	if err := controller.RegisterValidators(); err != nil {
+		log.Fatal("Failed to register validators: ", err)
+	}
+
+	indexCtx, cancelIndexes := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := repo.EnsureIndexes(indexCtx); err != nil {
+		log.Fatal("Failed to create indexes: ", err)
+	}
+	cancelIndexes()
+
+	monitor := model.NewHealthMonitor(healthHistorySize)
+	monitor.AddProbe("mongodb", repo.Ping)
+	monitor.AddProbe("redis", func(ctx context.Context) error {
+		return cacheConfig.Store.RedisClient.Ping(ctx).Err()
+	})
+	go monitor.Run(ctx, healthMonitorInterval)
+
+	undo, err := model.NewUndoStore(cacheConfig.Store.RedisClient)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	staleAfter, aging, err := model.StaleAfter()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if aging {
+		go model.RunAging(ctx, repo, agingInterval, staleAfter)
+	}
+
+	todos := controller.NewTodoController(repo, undo)
+	usage := model.NewUsageStore(cacheConfig.Store.RedisClient, repo.Database())
+	go usage.RunUsageFlush(ctx, usageFlushInterval)
+
+	remindersDone := make(chan struct{})
+	go func() {
+		defer close(remindersDone)
+		repo.RunReminders(ctx, reminderInterval, model.LogNotifier{})
+	}()
+
+	production := os.Getenv("ENVIRONMENT") == "production"
 	r := gin.New()
-	if os.Getenv("ENVIRONMENT") == "production" {
+	if production {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	r.Use(requestid.New())
+	latencyBudget, err := middleware.LatencyBudgetFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.Use(middleware.LatencyMiddleware(latencyBudget))
 	docs.SwaggerInfo.BasePath = "/api/v1"
-	r.Use(gzip.Gzip(gzip.DefaultCompression))
-	r.Use(middleware.TimeoutMiddleware())
+	r.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths([]string{controller.ExportPath})))
+	r.Use(middleware.TimeoutMiddleware(controller.ExportPath, controller.ImportPath))
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
-	err := r.SetTrustedProxies(nil)
+	err = r.SetTrustedProxies(nil)
 	if err != nil {
 		return
 	}
+	if err := middleware.ValidateJWTConfig(); err != nil {
+		log.Print("Warning: invalid JWT configuration: ", err)
+	}
 	authMiddleware, err := jwt.New(middleware.InitJWTParams())
 	r.Use(middleware.HandlerMiddleware(authMiddleware))
 	if err != nil {
@@ -67,32 +171,109 @@ func runServer() {
 	r.GET("/healthz", func(c *gin.Context) {
 		c.JSON(200, "")
 	})
+	r.GET("/version", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"version": golangtodomanager.Version,
+		})
+	})
 	r.GET("/readyz", func(c *gin.Context) {
-		redisStatusError := cacheConfig.Store.RedisClient.Ping(c).Err()
+		ctx := c.Request.Context()
+		redisStatusError := cacheConfig.Store.RedisClient.Ping(ctx).Err()
 		if redisStatusError != nil {
 			c.JSON(500, "Redis is unreachable")
+			return
 		}
-		mongoStatusError := model.Collection.Database().Client().Ping(c, readpref.Primary())
+		if err := model.MongoDegraded(); err != nil {
+			c.JSON(503, "MongoDB is degraded: "+err.Error())
+			return
+		}
+		mongoStatusError := repo.Ping(ctx)
 		if mongoStatusError != nil {
 			c.JSON(500, "MongoDB is unreachable")
+			return
+		}
+		jwtStatusError := middleware.VerifyTokenRoundTrip(authMiddleware)
+		if jwtStatusError != nil {
+			c.JSON(500, "JWT tokens cannot be signed and parsed")
+			return
 		}
 		c.JSON(200, "")
 	})
 
 	r.Static("/assets", "./assets")
 	version := "/api/v1"
-	r.POST("/api/v1/login", authMiddleware.LoginHandler)
-	auth := r.Group("/auth", authMiddleware.MiddlewareFunc())
+	r.POST("/api/v1/login", middleware.PrivateNoStore(), authMiddleware.LoginHandler)
+	auth := r.Group("/auth", middleware.PrivateNoStore(), authMiddleware.MiddlewareFunc())
 	auth.GET("/refresh_token", authMiddleware.RefreshHandler)
-	v1 := r.Group(version, authMiddleware.MiddlewareFunc())
+	admin := r.Group("/admin", middleware.PrivateNoStore(), authMiddleware.MiddlewareFunc())
+	admin.GET("/health/history", middleware.RequireAdmin(), controller.HealthHistoryHandler(monitor))
+	admin.POST("/aging/run", middleware.RequireAdmin(), todos.RunAgingHandler)
+	admin.GET("/metrics", middleware.RequireAdmin(), gin.WrapH(expvar.Handler()))
+	admin.GET("/config", middleware.RequireAdmin(), controller.ConfigHandler(repo.Namespace()))
+	if model.ChaosEnabled() {
+		log.Print("Warning: chaos mode is enabled, faults can be injected through /admin/chaos")
+		admin.GET("/chaos", middleware.RequireAdmin(), controller.GetChaosHandler)
+		admin.PUT("/chaos", middleware.RequireAdmin(), controller.PutChaosHandler)
+	}
+	admin.GET("/usage", middleware.RequireAdmin(), controller.UsageHandler(usage))
+	v1 := r.Group(version, middleware.PrivateNoStore(), authMiddleware.MiddlewareFunc(), middleware.ScopeToCurrentUser(), middleware.UsageMiddleware(usage), middleware.InvalidateTodosOnWrite(cacheConfig))
+	{
+		v1.GET("/todos", cache.CacheByRequestURI(cacheConfig.Store, cacheConfig.DefaultCacheTime, cache.WithCacheStrategyByRequest(middleware.CacheByUserAndRequestURI)), todos.GetAllTodosHandler)
+		v1.PUT("/todos/:id", todos.UpdateTodoByIdHandler)
+		v1.POST("/todos", todos.CreateTodoHandler)
+		v1.POST("/todos/bulk", todos.CreateTodosBulkHandler)
+		v1.POST("/todos/complete", todos.CompleteTodosHandler(cacheConfig))
+		v1.GET("/todos/export", todos.ExportTodosHandler)
+		v1.POST("/todos/import", todos.ImportTodosHandler)
+		v1.GET("/todos/duplicates", todos.GetDuplicateTodosHandler)
+		v1.GET("/todos/search", cache.CacheByRequestURI(cacheConfig.Store, cacheConfig.DefaultCacheTime, cache.WithCacheStrategyByRequest(middleware.CacheByUserAndRequestURI)), todos.SearchTodosHandler)
+		v1.GET("/todos/trash", todos.GetTrashHandler)
+		v1.DELETE("/todos/trash/:id", todos.PurgeTodoHandler)
+		v1.GET("/todos/stats", todos.GetStatsHandler)
+		v1.GET("/workload", todos.GetWorkloadHandler)
+		v1.DELETE("/todos", todos.DeleteCompletedHandler(cacheConfig))
+		v1.POST("/todos/:id/restore", todos.RestoreTodoHandler)
+		v1.POST("/todos/:id/archive", todos.ArchiveTodoHandler)
+		v1.GET("/todos/:id/children", todos.GetTodoChildrenHandler)
+		v1.GET("/todos/:id/blockers", todos.GetBlockersHandler)
+		v1.POST("/todos/:id/unarchive", todos.UnarchiveTodoHandler)
+		v1.POST("/todos/:id/pin", todos.PinTodoHandler)
+		v1.POST("/todos/:id/unpin", todos.UnpinTodoHandler)
+		v1.POST("/todos/:id/move", todos.MoveTodoHandler)
+		v1.POST("/todos/:id/complete-with-followup", todos.CompleteWithFollowupHandler)
+		v1.POST("/todos/:id/reopen", todos.ReopenTodoHandler)
+		v1.POST("/todos/:id/log-time", todos.LogTimeHandler)
+		v1.POST("/todos/:id/snooze", todos.SnoozeTodoHandler)
+		v1.POST("/todos/:id/reminders", todos.AddReminderHandler)
+		v1.DELETE("/todos/:id/reminders", todos.RemoveReminderHandler)
+		v1.GET("/todos/:id", cache.CacheByRequestURI(cacheConfig.Store, cacheConfig.DefaultCacheTime, cache.WithCacheStrategyByRequest(middleware.CacheByUserAndRequestURI)), todos.GetTodoByIdHandler)
+		v1.DELETE("/todos/:id", todos.DeleteTodoByIdHandler)
+		v1.POST("/undo", todos.UndoHandler)
+		v1.GET("/me/usage", controller.MyUsageHandler(usage))
+		v1.GET("/me/defaults", todos.GetDefaultsHandler)
+		v1.PUT("/me/defaults", todos.SetDefaultsHandler)
+		v1.PUT("/me/defaults/projects/:project", todos.SetProjectDefaultsHandler)
+		v1.DELETE("/me/defaults/projects/:project", todos.DeleteProjectDefaultsHandler)
+		v1.GET("/projects", todos.GetProjectsHandler)
+		v1.DELETE("/projects/:name", todos.DeleteProjectHandler)
+		v1.POST("/projects/rename", todos.RenameProjectHandler)
+		v1.GET("/templates", todos.GetTemplatesHandler)
+		v1.POST("/templates", todos.CreateTemplateHandler)
+		v1.GET("/templates/:id", todos.GetTemplateHandler)
+		v1.PUT("/templates/:id", todos.UpdateTemplateHandler)
+		v1.DELETE("/templates/:id", todos.DeleteTemplateHandler)
+		v1.POST("/templates/:id/instantiate", todos.InstantiateTemplateHandler)
+		v1.POST("/admin/todos/reassign", middleware.RequireAdmin(), todos.ReassignTodosHandler)
+		v1.POST("/todos/:id/notes", todos.AddNoteHandler)
+		v1.GET("/todos/:id/notes", todos.GetNotesHandler)
+		v1.GET("/todos/:id/history", todos.GetHistoryHandler)
+		v1.DELETE("/todos/:id/notes/:noteId", middleware.RequireAdmin(), todos.DeleteNoteHandler)
+	}
+	v2 := r.Group("/api/v2", middleware.PrivateNoStore(), authMiddleware.MiddlewareFunc(), middleware.ScopeToCurrentUser(), middleware.UsageMiddleware(usage))
 	{
-		v1.GET("/todos", cache.CacheByRequestURI(cacheConfig.Store, cacheConfig.DefaultCacheTime), controller.GetAllTodosHandler)
-		v1.PUT("/todos/:id", controller.UpdateTodoByIdHandler)
-		v1.POST("/todos", controller.CreateTodoHandler)
-		v1.GET("/todos/:id", cache.CacheByRequestURI(cacheConfig.Store, cacheConfig.DefaultCacheTime), controller.GetTodoByIdHandler)
-		v1.DELETE("/todos/:id", controller.DeleteTodoByIdHandler)
+		v2.GET("/todos", cache.CacheByRequestURI(cacheConfig.Store, cacheConfig.DefaultCacheTime, cache.WithCacheStrategyByRequest(middleware.CacheByUserAndRequestURI)), todos.GetAllTodosV2Handler)
 	}
-	if os.Getenv("ENVIRONMENT") != "production" {
+	if !production {
 		authorized := r.Group("/")
 		authorized.Use(middleware.BasicAuthMiddleware())
 		{
@@ -100,11 +281,129 @@ func runServer() {
 			authorized.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 		}
 	}
-	port := os.Getenv("PORT")
-	err = r.Run(port)
-	if err != nil {
-		log.Fatal("Failed to start server: ", err)
+	srv := &http.Server{Addr: os.Getenv("PORT"), Handler: r}
+	go func() {
+		log.Printf("Listening and serving HTTP on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server: ", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Print("Shutting down, send another interrupt to force")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Print("Server did not shut down cleanly: ", err)
+	}
+	<-remindersDone
+}
+
+// parseDate parses a date given on the command line as either YYYY-MM-DD,
+// taken as midnight local time, or RFC3339.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
 	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// cliAuthor names the author of notes written from the CLI.
+func cliAuthor() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "cli"
+}
+
+const defaultListLimit = 200
+
+var listFlags = []cli.Flag{
+	&cli.IntFlag{
+		Name:  "limit",
+		Value: defaultListLimit,
+		Usage: "maximum number of todos to print per page",
+	},
+	&cli.IntFlag{
+		Name:  "page",
+		Value: 1,
+		Usage: "page of results to print, starting at 1",
+	},
+	&cli.BoolFlag{
+		Name:  "all-pages",
+		Usage: "print every page, fetching one page at a time",
+	},
+	&cli.StringFlag{
+		Name:  "tag",
+		Usage: "only list todos carrying this tag",
+	},
+	&cli.StringFlag{
+		Name:  "project",
+		Usage: "only list todos in this project",
+	},
+	&cli.BoolFlag{
+		Name:  "include-archived",
+		Usage: "also list archived todos",
+	},
+}
+
+// listTodos prints the todos matching query in its order, a page at a time,
+// so large collections are never held in memory or behind a long-lived
+// cursor.
+func listTodos(c *cli.Context, query model.TodoQuery) error {
+	query.Tag = c.String("tag")
+	query.Project = c.String("project")
+	query.IncludeArchived = c.Bool("include-archived")
+
+	limit := c.Int("limit")
+	if limit < 1 {
+		return errors.New("--limit must be at least 1")
+	}
+	page := c.Int("page")
+	if page < 1 {
+		return errors.New("--page must be at least 1")
+	}
+	query.Offset = (page - 1) * limit
+	query.Limit = limit
+
+	repo := repository(c)
+	listing := &lastListing{Namespace: repo.Namespace(), IDs: map[string]string{}}
+	for {
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		todos, total, err := repo.List(ctx, query)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if total == 0 {
+			fmt.Print("Nothing to see here.\nRun `add 'todo'` to add a todo")
+			return nil
+		}
+		if int64(query.Offset) >= total && len(listing.IDs) == 0 {
+			fmt.Printf("Page %d is empty, there are only %d todos\n", page, total)
+			return nil
+		}
+
+		model.PrintTodoPage(todos, int64(query.Offset))
+		for i, todo := range todos {
+			listing.IDs[strconv.Itoa(query.Offset+i+1)] = todo.ID.Hex()
+		}
+
+		if !c.Bool("all-pages") {
+			if shown := int64(query.Offset + limit); query.Offset > 0 || shown < total {
+				fmt.Printf("Showing %d-%d of %d, use --limit, --page or --all-pages to see more\n",
+					query.Offset+1, min(shown, total), total)
+			}
+			break
+		}
+		query.Offset += limit
+		if int64(query.Offset) >= total {
+			break
+		}
+	}
+	return saveLastListing(listing)
 }
 
 // @title						Gin Todo API
@@ -123,118 +422,659 @@ func runServer() {
 // @in							header
 // @name						Authorization
 func main() {
-
 	app := &cli.App{
 		Version: golangtodomanager.Version,
 		Name:    "Todos App",
 		Usage:   "A simple CLI program to manage your todos",
-		Action: func(c *cli.Context) error {
-			var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-
-			todos, err := model.GetPending(ctx)
-			if err != nil {
-				if err == mongo.ErrNoDocuments {
-					fmt.Print("Nothing to see here.\nRun `add 'todo'` to add a todo")
-					return nil
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "collection",
+				Usage: "query this collection instead of DB_COLLECTION_NAME",
+			},
+			&cli.StringFlag{
+				Name:  "freeze-clock",
+				Usage: "pin the clock used for timestamps, as YYYY-MM-DD or RFC3339",
+			},
+		}, listFlags...),
+		Before: func(c *cli.Context) error {
+			if frozen := c.String("freeze-clock"); frozen != "" {
+				t, err := parseDate(frozen)
+				if err != nil {
+					return fmt.Errorf("invalid --freeze-clock: %w", err)
 				}
-				return err
+				model.FreezeClock(t)
 			}
-
-			model.PrintTodos(todos)
 			return nil
 		},
+		Action: func(c *cli.Context) error {
+			pending := false
+			return listTodos(c, model.TodoQuery{Completed: &pending, Awake: true})
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "add",
 				Aliases: []string{"a"},
 				Usage:   "Add a todo to the list",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "expand",
+						Usage: "expand template variables such as {{.Month}} in the text",
+					},
+					&cli.StringSliceFlag{
+						Name:  "var",
+						Usage: "define a template variable as name=value, implies --expand",
+					},
+					&cli.StringFlag{
+						Name:  "due",
+						Usage: "due date as YYYY-MM-DD or RFC3339",
+					},
+					&cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "tag the todo, can be repeated",
+					},
+					&cli.StringFlag{
+						Name:  "parent",
+						Usage: "make the todo a subtask of this number from the last listing",
+					},
+					&cli.StringFlag{
+						Name:  "project",
+						Usage: "add the todo to this project",
+					},
+					&cli.StringFlag{
+						Name:  "recur",
+						Usage: `recreate the todo when completed: daily, weekly, monthly, yearly or "every N days"`,
+					},
+				},
 				Action: func(c *cli.Context) error {
 					str := c.Args().First()
-					if str == "" {
+					if c.Bool("expand") || c.IsSet("var") {
+						vars := map[string]string{}
+						for _, v := range c.StringSlice("var") {
+							name, value, ok := strings.Cut(v, "=")
+							if !ok || name == "" {
+								return fmt.Errorf("invalid variable %q, expected name=value", v)
+							}
+							vars[name] = value
+						}
+
+						expanded, err := model.ExpandText(str, vars, model.Now())
+						if err != nil {
+							return err
+						}
+						str = expanded
+					}
+					if model.NormalizeText(str) == "" {
 						return errors.New("cannot add an empty todo")
 					}
 
 					todo := &model.Todo{
-						ID:        primitive.NewObjectID(),
-						CreatedAt: time.Now(),
-						UpdatedAt: time.Now(),
-						Text:      str,
-						Completed: false,
+						ID:         primitive.NewObjectID(),
+						CreatedAt:  model.Now(),
+						UpdatedAt:  model.Now(),
+						Text:       str,
+						Completed:  false,
+						Tags:       c.StringSlice("tag"),
+						Project:    c.String("project"),
+						Recurrence: c.String("recur"),
+					}
+					if c.IsSet("due") {
+						due, err := parseDate(c.String("due"))
+						if err != nil {
+							return fmt.Errorf("invalid --due %q, expected YYYY-MM-DD or RFC3339", c.String("due"))
+						}
+						todo.DueDate = &due
+					}
+					if c.IsSet("parent") {
+						id, err := todoIdFromListing(c, c.String("parent"))
+						if err != nil {
+							return err
+						}
+						parentId, err := primitive.ObjectIDFromHex(id)
+						if err != nil {
+							return err
+						}
+						todo.ParentID = &parentId
 					}
 					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 					defer cancel()
 
-					return model.CreateTodo(ctx, todo)
+					err := repository(c).Create(ctx, todo)
+					if errors.Is(err, model.ErrDuplicateTodo) {
+						return fmt.Errorf("%q is already on your list, complete or delete it first", model.NormalizeText(str))
+					}
+					return err
 				},
 			},
 			{
 				Name:    "all",
 				Aliases: []string{"l"},
 				Usage:   "List all todos",
+				Flags:   listFlags,
+				Action: func(c *cli.Context) error {
+					return listTodos(c, model.TodoQuery{})
+				},
+			},
+			{
+				Name:  "overdue",
+				Usage: "List pending todos past their due date",
+				Flags: listFlags,
+				Action: func(c *cli.Context) error {
+					return listTodos(c, model.TodoQuery{Overdue: true})
+				},
+			},
+			{
+				Name:      "done",
+				Aliases:   []string{"d"},
+				Usage:     "Complete todos on the list",
+				ArgsUsage: "one or several <text> or <number> from the last listing, or one <number> with --then",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "then",
+						Usage: "add this todo as the follow-up, in the same project and with the same tags",
+					},
+				},
 				Action: func(c *cli.Context) error {
 					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 					defer cancel()
 
-					todos, err := model.GetAll(ctx)
+					if !c.IsSet("then") {
+						return completeTodos(ctx, c, c.Args().Slice())
+					}
+
+					if model.NormalizeText(c.String("then")) == "" {
+						return errors.New("cannot add an empty follow-up")
+					}
+					id, err := todoIdFromListing(c, c.Args().First())
 					if err != nil {
-						if err == mongo.ErrNoDocuments {
-							fmt.Print("Nothing to see here.\nRun `add 'todo'` to add a todo")
-							return nil
-						}
+						return err
+					}
+					completed, followup, err := repository(c).CompleteWithFollowup(ctx, id, &model.Todo{Text: c.String("then")})
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Completed %q, next up %q\n", completed.Text, followup.Text)
+					return nil
+				},
+			},
+			{
+				Name:  "stats",
+				Usage: "Count pending, completed and overdue todos and show the last 30 days and weeks",
+				Action: func(c *cli.Context) error {
+					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
 
+					stats, err := repository(c).Stats(ctx)
+					if err != nil {
 						return err
 					}
-					model.PrintTodos(todos)
+
+					fmt.Printf("Total:     %d\n", stats.Total)
+					fmt.Printf("Pending:   %d\n", stats.Pending)
+					fmt.Printf("Completed: %d\n", stats.Completed)
+					fmt.Printf("Overdue:   %d\n", stats.Overdue)
+					fmt.Printf("Estimated: %dm\n", stats.EstimateMinutes)
+					fmt.Printf("Spent:     %dm\n", stats.SpentMinutes)
+					fmt.Println()
+					fmt.Println("Day         Created  Completed")
+					for _, day := range stats.Days {
+						fmt.Printf("%s  %7d  %9d\n", day.Date, day.Created, day.Completed)
+					}
+					fmt.Println()
+					fmt.Println("Week      Estimated     Spent")
+					for _, week := range stats.Weeks {
+						fmt.Printf("%s  %8dm  %7dm\n", week.Week, week.EstimateMinutes, week.SpentMinutes)
+					}
 					return nil
 				},
 			},
 			{
-				Name:    "done",
-				Aliases: []string{"d"},
-				Usage:   "Complete a todo on the list",
+				Name:      "undone",
+				Usage:     "Reopen a completed todo",
+				ArgsUsage: "<text> or <number> from the last listing",
 				Action: func(c *cli.Context) error {
 					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 					defer cancel()
 
-					text := c.Args().First()
-					return model.CompleteTodo(ctx, text)
+					id, err := todoIdFromArg(ctx, c, c.Args().First())
+					if err != nil {
+						return err
+					}
+					todo, err := repository(c).Reopen(ctx, id)
+					if errors.Is(err, model.ErrDuplicateTodo) {
+						return errors.New("a pending todo with the same text is already on your list, complete or delete it first")
+					}
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Reopened %q\n", todo.Text)
+					return nil
 				},
 			},
 			{
 				Name:    "finished",
 				Aliases: []string{"f"},
 				Usage:   "List completed todos",
+				Flags:   listFlags,
+				Action: func(c *cli.Context) error {
+					return listTodos(c, model.TodoQuery{Finished: true})
+				},
+			},
+			{
+				Name:    "delete",
+				Aliases: []string{"rm"},
+				Usage:   "Deletes a todo on the list",
+				Action: func(c *cli.Context) error {
+					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+
+					text := c.Args().First()
+					todo, err := repository(c).GetByText(ctx, text)
+					if errors.Is(err, mongo.ErrNoDocuments) {
+						return errors.New("no todos were deleted")
+					}
+					if err != nil {
+						return err
+					}
+					_, err = repository(c).Delete(ctx, todo.ID.Hex())
+					return err
+				},
+			},
+			{
+				Name:      "archive",
+				Usage:     "Archive a todo by its number in the last listing",
+				ArgsUsage: "<number>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "undo",
+						Usage: "unarchive the todo instead",
+					},
+				},
 				Action: func(c *cli.Context) error {
+					id, err := todoIdFromListing(c, c.Args().First())
+					if err != nil {
+						return err
+					}
+
+					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+
+					todo, err := repository(c).SetArchived(ctx, id, !c.Bool("undo"))
+					if err != nil {
+						return err
+					}
+					if todo.Archived {
+						fmt.Printf("Archived %q\n", todo.Text)
+					} else {
+						fmt.Printf("Unarchived %q\n", todo.Text)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "pin",
+				Usage:     "Pin a todo by its number in the last listing, so it is listed first",
+				ArgsUsage: "<number>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "undo",
+						Usage: "unpin the todo instead",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					id, err := todoIdFromListing(c, c.Args().First())
+					if err != nil {
+						return err
+					}
+
 					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 					defer cancel()
 
-					todos, err := model.GetFinished(ctx)
+					todo, err := repository(c).SetPinned(ctx, id, !c.Bool("undo"))
 					if err != nil {
-						if err == mongo.ErrNoDocuments {
-							fmt.Print("Nothing to see here.\nRun `add 'todo'` to add a todo")
-							return nil
+						return err
+					}
+					if todo.Pinned {
+						fmt.Printf("Pinned %q\n", todo.Text)
+					} else {
+						fmt.Printf("Unpinned %q\n", todo.Text)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "snooze",
+				Usage:     "Hide a pending todo from the list for a while, e.g. 2h or 3d",
+				ArgsUsage: "<number> <duration>",
+				Action: func(c *cli.Context) error {
+					id, err := todoIdFromListing(c, c.Args().First())
+					if err != nil {
+						return err
+					}
+					d, err := model.ParseSnoozeDuration(c.Args().Get(1))
+					if err != nil {
+						return err
+					}
+
+					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+
+					todo, err := repository(c).Snooze(ctx, id, model.Now().Add(d))
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Snoozed %q until %s\n", todo.Text, todo.SnoozedUntil.Local().Format(time.DateTime))
+					return nil
+				},
+			},
+			{
+				Name:      "remind",
+				Usage:     "Add a reminder to a pending todo, e.g. \"tomorrow 9am\", \"friday 17:30\" or \"in 2 hours\"",
+				ArgsUsage: "<number> <when>",
+				Action: func(c *cli.Context) error {
+					id, err := todoIdFromListing(c, c.Args().First())
+					if err != nil {
+						return err
+					}
+					at, err := model.ParseWhen(strings.Join(c.Args().Tail(), " "), model.Now())
+					if err != nil {
+						return err
+					}
+
+					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+
+					todo, err := repository(c).AddReminder(ctx, id, at)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Will remind you of %q at %s\n", todo.Text, at.Local().Format(time.DateTime))
+					return nil
+				},
+			},
+			importCommand,
+			searchCommand,
+			templateCommand,
+			projectCommand,
+			workloadCommand,
+			e2eCommand,
+			{
+				Name:  "purge",
+				Usage: "Permanently remove todos deleted more than --days ago",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "days",
+						Value: 30,
+						Usage: "days a deleted todo is kept in the trash",
+					},
+					waitFlag,
+				},
+				Action: func(c *cli.Context) error {
+					if c.Int("days") < 0 {
+						return errors.New("--days must not be negative")
+					}
+
+					lock, err := acquireBatchLock(context.Background(), c, "purge")
+					if err != nil {
+						return err
+					}
+					defer lock.Release(context.Background())
+
+					var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
+
+					n, err := repository(c).Purge(ctx, time.Duration(c.Int("days"))*24*time.Hour)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Purged %d todos\n", n)
+					return nil
+				},
+			},
+			{
+				Name:  "clear",
+				Usage: "Permanently remove all completed todos",
+				Flags: []cli.Flag{waitFlag},
+				Action: func(c *cli.Context) error {
+					lock, err := acquireBatchLock(context.Background(), c, "clear")
+					if err != nil {
+						return err
+					}
+					defer lock.Release(context.Background())
+
+					var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
+
+					n, err := repository(c).DeleteCompleted(ctx)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Removed %d completed todos\n", n)
+
+					if n > 0 {
+						if err := model.SetupRedisCache().InvalidateAllTodos(ctx); err != nil {
+							fmt.Fprintf(os.Stderr, "Dropping cached todo responses failed, they expire on their own: %v\n", err)
+						}
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "reassign",
+				Usage: "Give the todos of one user to another",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Required: true,
+						Usage:    "user whose todos are reassigned",
+					},
+					&cli.StringFlag{
+						Name:     "to",
+						Required: true,
+						Usage:    "user to give the todos to",
+					},
+					&cli.StringFlag{
+						Name:  "project",
+						Usage: "only reassign todos in this project",
+					},
+					&cli.StringFlag{
+						Name:  "tag",
+						Usage: "only reassign todos carrying this tag",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "only count the todos that would be reassigned",
+					},
+					waitFlag,
+				},
+				Action: func(c *cli.Context) error {
+					from, to := c.String("from"), c.String("to")
+					if from == to {
+						return errors.New("--from and --to must be different users")
+					}
+					if !middleware.KnownUser(to) {
+						return fmt.Errorf("unknown user %q", to)
+					}
+
+					if !c.Bool("dry-run") {
+						lock, err := acquireBatchLock(context.Background(), c, "reassign")
+						if err != nil {
+							return err
+						}
+						defer lock.Release(context.Background())
+					}
+
+					var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
+
+					result, err := repository(c).Reassign(ctx, from, to, model.TodoQuery{Project: c.String("project"), Tag: c.String("tag")}, c.Bool("dry-run"))
+					if err != nil {
+						return err
+					}
+					if result.DryRun {
+						fmt.Printf("Would reassign %d todos from %s to %s\n", result.Matched, from, to)
+					} else {
+						fmt.Printf("Reassigned %d todos from %s to %s\n", result.Reassigned, from, to)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "migrate",
+				Usage: "Inspect and run schema migrations",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "lazy-status",
+						Usage: "report how many todos still lack each lazily migrated field",
+					},
+					&cli.StringFlag{
+						Name:  "assign-owner",
+						Usage: "give todos without an owner to this user",
+					},
+					waitFlag,
+				},
+				Action: func(c *cli.Context) error {
+					if !c.Bool("lazy-status") && c.String("assign-owner") == "" {
+						return errors.New("nothing to do, pass --lazy-status or --assign-owner")
+					}
+
+					lock, err := acquireBatchLock(context.Background(), c, "migrate")
+					if err != nil {
+						return err
+					}
+					defer lock.Release(context.Background())
+
+					var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
+
+					if owner := c.String("assign-owner"); owner != "" {
+						n, err := repository(c).AssignUnowned(ctx, owner)
+						if err != nil {
+							return err
 						}
+						fmt.Printf("Assigned %d todos to %s\n", n, owner)
+					}
+					if !c.Bool("lazy-status") {
+						return nil
+					}
+
+					status, err := repository(c).LazyMigrationStatus(ctx)
+					if err != nil {
 						return err
 					}
+					for field, missing := range status {
+						fmt.Printf("%s: %d todos pending\n", field, missing)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "seed",
+				Usage: "Load a named set of demo todos",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "fixture",
+						Required: true,
+						Usage:    "fixture set to load, one of " + strings.Join(model.FixtureNames(), ", "),
+					},
+				},
+				Action: func(c *cli.Context) error {
+					var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
 
-					model.PrintTodos(todos)
+					n, err := model.LoadFixture(ctx, repository(c), c.String("fixture"))
+					if err != nil {
+						return err
+					}
+
+					fmt.Printf("Loaded %d todos from fixture %s\n", n, c.String("fixture"))
 					return nil
 				},
 			},
 			{
-				Name:    "delete",
-				Aliases: []string{"rm"},
-				Usage:   "Deletes a todo on the list",
+				Name:      "show",
+				Usage:     "Show everything about one todo",
+				ArgsUsage: "<id>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "output",
+						Value: "text",
+						Usage: "output format, text or json",
+					},
+				},
 				Action: func(c *cli.Context) error {
+					id := c.Args().First()
+					if id == "" {
+						return errors.New("usage: show <id>")
+					}
+
 					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 					defer cancel()
 
-					text := c.Args().First()
-					err := model.DeleteTodo(ctx, text)
+					detail, err := model.GetTodoDetail(ctx, repository(c), id)
 					if err != nil {
 						return err
 					}
+
+					switch c.String("output") {
+					case "json":
+						enc := json.NewEncoder(os.Stdout)
+						enc.SetIndent("", "  ")
+						return enc.Encode(detail)
+					case "text":
+						model.PrintTodoDetail(detail)
+						return nil
+					default:
+						return fmt.Errorf("unknown output format %q, expected text or json", c.String("output"))
+					}
+				},
+			},
+			{
+				Name:      "note",
+				Usage:     "Append a note to a todo",
+				ArgsUsage: "<id> <text>",
+				Action: func(c *cli.Context) error {
+					id := c.Args().Get(0)
+					text := c.Args().Get(1)
+					if id == "" || model.NormalizeText(text) == "" {
+						return errors.New("usage: note <id> <text>")
+					}
+
+					var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+
+					_, err := repository(c).AddNote(ctx, id, cliAuthor(), text)
+					return err
+				},
+			},
+			{
+				Name:  "dedupe",
+				Usage: "List groups of pending todos with near-identical text",
+				Flags: []cli.Flag{
+					&cli.Float64Flag{
+						Name:  "threshold",
+						Value: 0.85,
+						Usage: "minimum similarity between 0 and 1",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
+
+					clusters, err := repository(c).Duplicates(ctx, c.Float64("threshold"))
+					if err != nil {
+						return err
+					}
+					if len(clusters) == 0 {
+						fmt.Println("No near-duplicate todos found.")
+						return nil
+					}
+
+					for i, cluster := range clusters {
+						fmt.Printf("Group %d (similarity %.2f):\n", i+1, cluster.Similarity)
+						for _, t := range cluster.Todos {
+							fmt.Printf("  %s  %s\n", t.ID.Hex(), t.Text)
+						}
+					}
 					return nil
 				},
 			},
@@ -243,7 +1083,7 @@ func main() {
 				Aliases: []string{"s"},
 				Usage:   "Starts a server to interact with mongodb",
 				Action: func(c *cli.Context) error {
-					runServer()
+					runServer(repository(c))
 					return nil
 				},
 			},
@@ -255,3 +1095,48 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// todoIdFromArg resolves a todo named on the command line, either by its
+// number in the last listing or by its text, to the todo's id.
+func todoIdFromArg(ctx context.Context, c *cli.Context, arg string) (string, error) {
+	if _, err := strconv.Atoi(arg); err == nil {
+		return todoIdFromListing(c, arg)
+	}
+
+	todo, err := repository(c).GetByText(ctx, arg)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("no todo %q", arg)
+	}
+	if err != nil {
+		return "", err
+	}
+	return todo.ID.Hex(), nil
+}
+
+// completeTodos completes the todos args name, one or several, each either
+// by its number in the last listing or by its text, in a single bulk update.
+func completeTodos(ctx context.Context, c *cli.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: done <text> or <number> from the last listing")
+	}
+
+	ids := make([]primitive.ObjectID, len(args))
+	for i, arg := range args {
+		hex, err := todoIdFromArg(ctx, c, arg)
+		if err != nil {
+			return err
+		}
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return err
+		}
+		ids[i] = id
+	}
+
+	completed, _, err := repository(c).CompleteMany(ctx, ids)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Completed %d of %d todos\n", completed, len(ids))
+	return nil
+}
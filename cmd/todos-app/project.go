@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/urfave/cli/v2"
+)
+
+// projectImpact describes what each deletion strategy does to the todos.
+var projectImpact = map[model.ProjectStrategy]string{
+	model.ProjectOrphan:  "taken out of the project",
+	model.ProjectArchive: "archived",
+	model.ProjectTrash:   "moved to the trash",
+}
+
+var projectCommand = &cli.Command{
+	Name:  "project",
+	Usage: "Manage projects",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "rm",
+			Usage:     "Delete a project, orphaning, archiving or deleting its todos",
+			ArgsUsage: "<name>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "strategy",
+					Usage: "what to do with the project's todos: orphan, archive or delete",
+				},
+				&cli.BoolFlag{
+					Name:    "yes",
+					Aliases: []string{"y"},
+					Usage:   "do not ask for confirmation",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				name := strings.Join(c.Args().Slice(), " ")
+				if model.NormalizeProject(name) == "" {
+					return errors.New("a project name is required")
+				}
+
+				var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+
+				repo := repository(c)
+				impact, err := repo.DeleteProject(ctx, name, "", true)
+				if err != nil {
+					return err
+				}
+				if c.String("strategy") == "" {
+					return fmt.Errorf("project %q holds %d todos, pass --strategy orphan, archive or delete", impact.Project, impact.Todos)
+				}
+				strategy, err := model.ParseProjectStrategy(c.String("strategy"))
+				if err != nil {
+					return err
+				}
+
+				if !c.Bool("yes") {
+					fmt.Printf("%d todos in project %q will be %s. Continue? [y/N] ", impact.Todos, impact.Project, projectImpact[strategy])
+					if !confirmed() {
+						return errors.New("cancelled")
+					}
+				}
+
+				deletion, err := repo.DeleteProject(ctx, name, strategy, false)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Deleted project %q: %d of %d todos %s\n", deletion.Project, deletion.Changed, deletion.Todos, projectImpact[strategy])
+				if deletion.Kept > 0 {
+					fmt.Printf("Kept %d todos with subtasks in other projects\n", deletion.Kept)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "rename",
+			Usage:     "Rename a project, or merge it into another with --merge",
+			ArgsUsage: "<old> <new>",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "merge",
+					Usage: "combine the todos of both projects when the new name is in use",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 {
+					return errors.New("the old and new project names are required")
+				}
+
+				var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+
+				rename, err := repository(c).RenameProject(ctx, c.Args().Get(0), c.Args().Get(1), c.Bool("merge"))
+				if errors.Is(err, model.ErrProjectExists) {
+					return fmt.Errorf("project %q is in use, pass --merge to combine them", model.NormalizeProject(c.Args().Get(1)))
+				}
+				if err != nil {
+					return err
+				}
+				if rename.Merged {
+					fmt.Printf("Merged %d todos from %q into %q\n", rename.Renamed, rename.From, rename.To)
+				} else {
+					fmt.Printf("Renamed %q to %q for %d todos\n", rename.From, rename.To, rename.Renamed)
+				}
+				return nil
+			},
+		},
+	},
+}
+
+// confirmed reads a yes or no answer from stdin, taking anything but yes
+// as no.
+func confirmed() bool {
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
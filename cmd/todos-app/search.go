@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+var searchCommand = &cli.Command{
+	Name:      "search",
+	Usage:     "Search the text of todos, best match first",
+	ArgsUsage: "<words>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "completed",
+			Usage: "only show completed todos, or pending ones with --completed=false",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		query := strings.Join(c.Args().Slice(), " ")
+
+		var completed *bool
+		if c.IsSet("completed") {
+			value := c.Bool("completed")
+			completed = &value
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		repo := repository(c)
+		todos, err := repo.Search(ctx, query, completed)
+		if err != nil {
+			return err
+		}
+		if len(todos) == 0 {
+			fmt.Printf("No todos match %q\n", query)
+			return nil
+		}
+
+		terms := searchTerms(query)
+		match := color.New(color.Bold, color.Underline)
+		listing := &lastListing{Namespace: repo.Namespace(), IDs: map[string]string{}}
+		for i, todo := range todos {
+			base := color.New(color.FgYellow)
+			if todo.Completed {
+				base = color.New(color.FgGreen)
+			}
+			n := strconv.Itoa(i + 1)
+			fmt.Println(base.Sprint(n+": ") + highlightTerms(todo.Text, terms, base, match))
+			listing.IDs[n] = todo.ID.Hex()
+		}
+		return saveLastListing(listing)
+	},
+}
+
+// searchTerms returns the words of a text search query worth highlighting,
+// leaving out negated words and the quotes around phrases.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		if word = strings.Trim(word, `"`); word != "" {
+			terms = append(terms, strings.ToLower(word))
+		}
+	}
+	return terms
+}
+
+// highlightTerms prints text in base with every case-insensitive occurrence
+// of terms in match instead. The text index matches stemmed words, so a todo
+// can match without any term appearing in it verbatim.
+func highlightTerms(text string, terms []string, base *color.Color, match *color.Color) string {
+	lower := strings.ToLower(text)
+	// Lowercasing can change the length of some characters, in which case
+	// offsets into lower would not line up with text.
+	if len(lower) != len(text) {
+		return base.Sprint(text)
+	}
+
+	var b strings.Builder
+	start := 0
+	for start < len(text) {
+		at, length := -1, 0
+		for _, term := range terms {
+			i := strings.Index(lower[start:], term)
+			if i >= 0 && (at < 0 || i < at || (i == at && len(term) > length)) {
+				at, length = i, len(term)
+			}
+		}
+		if at < 0 {
+			break
+		}
+		b.WriteString(base.Sprint(text[start : start+at]))
+		b.WriteString(match.Sprint(text[start+at : start+at+length]))
+		start += at + length
+	}
+	b.WriteString(base.Sprint(text[start:]))
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/urfave/cli/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var templateCommand = &cli.Command{
+	Name:  "template",
+	Usage: "Save and reuse lists of todos",
+	Subcommands: []*cli.Command{
+		{
+			Name:      "save",
+			Usage:     "Save the pending todos as a template",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				name := strings.Join(c.Args().Slice(), " ")
+				if model.NormalizeText(name) == "" {
+					return errors.New("a template name is required")
+				}
+
+				var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				template, err := repository(c).TemplateFromPending(ctx, name)
+				if err != nil {
+					return err
+				}
+				if len(template.Items) == 0 {
+					return errors.New("there are no pending todos to save")
+				}
+				if err := repository(c).CreateTemplate(ctx, template); err != nil {
+					return err
+				}
+				fmt.Printf("Saved %d todos as %q\n", len(template.Items), template.Name)
+				return nil
+			},
+		},
+		{
+			Name:      "apply",
+			Usage:     "Add the todos of a template",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				name := strings.Join(c.Args().Slice(), " ")
+
+				var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				repo := repository(c)
+				template, err := repo.GetTemplateByName(ctx, name)
+				if errors.Is(err, mongo.ErrNoDocuments) {
+					return fmt.Errorf("no template is named %q", name)
+				}
+				if err != nil {
+					return err
+				}
+				todos, err := model.InstantiateTemplate(ctx, repo, template)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Added %d todos from %q\n", len(todos), template.Name)
+				return nil
+			},
+		},
+	},
+}
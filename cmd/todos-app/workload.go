@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+// workloadBarWidth is how many characters the bar of a day at capacity
+// takes. Days over capacity are drawn longer, up to twice that.
+const workloadBarWidth = 40
+
+var workloadCommand = &cli.Command{
+	Name:  "workload",
+	Usage: "Show the estimated work scheduled on each day, highlighting days over capacity",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "first day as YYYY-MM-DD, defaults to today",
+		},
+		&cli.IntFlag{
+			Name:  "days",
+			Value: 7,
+			Usage: "number of days to show, at most 90",
+		},
+		&cli.StringFlag{
+			Name:  "tz",
+			Value: "Local",
+			Usage: "IANA time zone of the days",
+		},
+		&cli.IntFlag{
+			Name:  "capacity",
+			Usage: "daily capacity in minutes, defaults to WORKLOAD_CAPACITY_MINUTES or 480",
+		},
+		&cli.IntFlag{
+			Name:  "default-estimate",
+			Usage: "minutes counted for a todo without an estimate, defaults to WORKLOAD_DEFAULT_ESTIMATE_MINUTES or 30",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		capacity, estimate, err := model.WorkloadSettings()
+		if err != nil {
+			return err
+		}
+		if c.IsSet("capacity") {
+			if capacity = c.Int("capacity"); capacity < 1 {
+				return errors.New("--capacity must be a positive number of minutes")
+			}
+		}
+		if c.IsSet("default-estimate") {
+			estimate = c.Int("default-estimate")
+		}
+		loc, err := time.LoadLocation(c.String("tz"))
+		if err != nil {
+			return fmt.Errorf("unknown time zone %q", c.String("tz"))
+		}
+		from := model.Now().In(loc)
+		if raw := c.String("from"); raw != "" {
+			if from, err = time.ParseInLocation(time.DateOnly, raw, loc); err != nil {
+				return fmt.Errorf("--from must be a date as YYYY-MM-DD, got %q", raw)
+			}
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		workload, err := repository(c).Workload(ctx, model.WorkloadQuery{
+			From:            from,
+			To:              from.AddDate(0, 0, c.Int("days")-1),
+			Location:        loc,
+			CapacityMinutes: capacity,
+			DefaultEstimate: estimate,
+		})
+		if err != nil {
+			return err
+		}
+		printWorkload(workload)
+		return nil
+	},
+}
+
+// printWorkload draws a bar per day scaled to the capacity, in red for the
+// days over it.
+func printWorkload(workload *model.Workload) {
+	for _, day := range workload.Days {
+		date, _ := time.Parse(time.DateOnly, day.Date)
+		width := int(day.Minutes * workloadBarWidth / int64(workload.CapacityMinutes))
+		width = min(width, 2*workloadBarWidth)
+		if width == 0 && day.Minutes > 0 {
+			width = 1
+		}
+		bar := fmt.Sprintf("%s %s%s %2dh%02d",
+			date.Format("Mon 2006-01-02"),
+			strings.Repeat("█", width), strings.Repeat(" ", 2*workloadBarWidth-width),
+			day.Minutes/60, day.Minutes%60)
+		if day.Todos > 0 {
+			bar += fmt.Sprintf(" (%d todos)", day.Todos)
+		}
+		if day.OverCapacity {
+			color.Red("%s", bar)
+		} else {
+			fmt.Println(bar)
+		}
+	}
+	fmt.Printf("Capacity %dh%02d a day, %d minutes counted for todos without an estimate\n",
+		workload.CapacityMinutes/60, workload.CapacityMinutes%60, workload.DefaultEstimateMinutes)
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	golangtodomanager "github.com/CharlesPatterson/todos-app"
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHistoryHandler reports the recent probe results the monitor
+// collected for each dependency, along with runtime statistics.
+func HealthHistoryHandler(monitor *model.HealthMonitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		c.JSON(http.StatusOK, gin.H{
+			"uptime":     monitor.Uptime().String(),
+			"goroutines": runtime.NumGoroutine(),
+			"heap": gin.H{
+				"alloc_bytes": mem.HeapAlloc,
+				"sys_bytes":   mem.HeapSys,
+				"objects":     mem.HeapObjects,
+			},
+			"dependencies": monitor.History(),
+		})
+	}
+}
+
+// RunAgingHandler marks pending todos untouched for STALE_AFTER_DAYS, or the
+// older_than_days query parameter, as stale. With dry_run=true it only
+// reports what would change.
+func (h *TodoController) RunAgingHandler(c *gin.Context) {
+	olderThan, _, err := model.StaleAfter()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if raw := c.Query("older_than_days"); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days < 1 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "older_than_days must be a positive number"})
+			return
+		}
+		olderThan = time.Duration(days) * 24 * time.Hour
+	}
+	if olderThan == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "aging is not configured, set STALE_AFTER_DAYS or pass older_than_days"})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	if !dryRun {
+		lock, err := h.todos.AcquireLock(ctx, model.BatchLock, model.LockHolder("aging"))
+		var held *model.LockHeldError
+		if errors.As(err, &held) {
+			c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse{Error: held.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+			return
+		}
+		defer lock.Release(context.Background())
+	}
+
+	result, err := h.todos.MarkStale(ctx, olderThan, dryRun)
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ConfigHandler reports the effective configuration with secrets redacted,
+// and the MongoDB URI the server uses along with the namespace it keeps
+// todos in.
+func ConfigHandler(namespace string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"version":   golangtodomanager.Version,
+			"namespace": namespace,
+			"db_uri":    model.RedactedURI(),
+			"config":    model.LoadConfig().Redacted(),
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// @Summary		Archive a todo
+// @ID				archive-todo
+// @Tags			Todos
+// @Description	Hide a todo from the default listings without deleting it
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/archive [post]
+func (h *TodoController) ArchiveTodoHandler(c *gin.Context) {
+	h.setArchived(c, true)
+}
+
+// @Summary	Unarchive a todo
+// @ID			unarchive-todo
+// @Tags		Todos
+// @Produce	json
+// @Param		id				path	string	true	"Todo ID"
+// @Param		Authorization	header	string	false	"Authorization"
+// @Security	JWT
+// @Success	200	{object}	model.Todo
+// @Failure	401	{object}	controller.UnauthorizedResponse
+// @Failure	404	{object}	controller.ErrorResponse
+// @Failure	408	{object}	controller.ErrorResponse
+// @Failure	500	{object}	controller.ErrorResponse
+// @Failure	504	{object}	controller.ErrorResponse
+// @Router		/todos/{id}/unarchive [post]
+func (h *TodoController) UnarchiveTodoHandler(c *gin.Context) {
+	h.setArchived(c, false)
+}
+
+func (h *TodoController) setArchived(c *gin.Context, archived bool) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.SetArchived(ctx, c.Param("id"), archived)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/CharlesPatterson/todos-app/middleware"
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxBulkTodos bounds the number of todos a single bulk request can create.
+const maxBulkTodos = 500
+
+// BulkItemResult is the outcome for one element of a bulk request. Status
+// is 201 for a created todo, 400 for an element that failed validation, 409
+// for a completed one with pending blocking todos or, with
+// REJECT_DUPLICATE_TODOS set, one duplicating a pending todo, and 500 for
+// one the database refused.
+type BulkItemResult struct {
+	Index  int         `json:"index" example:"0"`
+	Status int         `json:"status" example:"201"`
+	Todo   *model.Todo `json:"todo,omitempty"`
+	Errors []ErrorMsg  `json:"errors,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// BulkCreateResponse reports the outcome of every element of a bulk request,
+// in request order.
+type BulkCreateResponse struct {
+	Created int              `json:"created" example:"2"`
+	Failed  int              `json:"failed" example:"1"`
+	Results []BulkItemResult `json:"results"`
+}
+
+// @Summary		Create todos in bulk
+// @ID				create-todos-bulk
+// @Tags			Todos
+// @Description	Create up to 500 todos from a JSON array with a single unordered insert. Each element is validated on its own and reported in results by its index; a failing element never stops the others from being created.
+// @Accept			json
+// @Produce		json
+// @Param			data			body	[]model.TodoDocInput	true	"Todos to create"
+// @Param			Authorization	header	string					false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	controller.BulkCreateResponse
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/bulk [post]
+func (h *TodoController) CreateTodosBulkHandler(c *gin.Context) {
+	var elements []json.RawMessage
+	if err := c.ShouldBindJSON(&elements); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "body must be a JSON array of todos"})
+		return
+	}
+	if len(elements) == 0 || len(elements) > maxBulkTodos {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "between 1 and " + strconv.Itoa(maxBulkTodos) + " todos are required"})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	response := BulkCreateResponse{Results: make([]BulkItemResult, len(elements))}
+	var todos []*model.Todo
+	var indexes []int
+	for i, element := range elements {
+		result := &response.Results[i]
+		result.Index = i
+
+		input := &model.TodoDocInput{}
+		if err := json.Unmarshal(element, input); err != nil {
+			result.Status = http.StatusBadRequest
+			result.Error = err.Error()
+			continue
+		}
+		if err := binding.Validator.ValidateStruct(input); err != nil {
+			result.Status = http.StatusBadRequest
+			var ve validator.ValidationErrors
+			if errors.As(err, &ve) {
+				for _, fe := range ve {
+					result.Errors = append(result.Errors, ErrorMsg{fe.Field(), getErrorMsg(fe)})
+				}
+			} else {
+				result.Error = err.Error()
+			}
+			continue
+		}
+		if model.NormalizeText(input.Text) == "" {
+			result.Status = http.StatusBadRequest
+			result.Errors = []ErrorMsg{{Field: "Text", Message: "This field is required"}}
+			continue
+		}
+		todo, err := input.Todo()
+		if err != nil {
+			result.Status = http.StatusBadRequest
+			result.Error = err.Error()
+			continue
+		}
+		todo.ID = primitive.NewObjectID()
+		if err := h.todos.Validate(ctx, todo); err != nil {
+			var re *model.InvalidRecurrenceError
+			var blocked *model.BlockedError
+			switch {
+			case errors.As(err, &re):
+				result.Status = http.StatusBadRequest
+				result.Errors = []ErrorMsg{{Field: "Recurrence", Message: re.Error()}}
+				continue
+			case errors.Is(err, model.ErrParentNotFound), errors.Is(err, model.ErrBlockerNotFound), errors.Is(err, model.ErrDependencyCycle):
+				result.Status = http.StatusBadRequest
+				result.Error = err.Error()
+				continue
+			case errors.As(err, &blocked):
+				result.Status = http.StatusConflict
+				result.Error = err.Error()
+				continue
+			default:
+				c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+				return
+			}
+		}
+
+		todo.CreatedAt = model.Now()
+		todo.UpdatedAt = model.Now()
+		todo.Owner = middleware.CurrentUserName(c)
+		result.Status = http.StatusCreated
+		result.Todo = todo
+		todos = append(todos, todo)
+		indexes = append(indexes, i)
+	}
+
+	if len(todos) > 0 {
+		err := h.todos.CreateMany(ctx, todos)
+		var bulkErr mongo.BulkWriteException
+		if err != nil && (!errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil) {
+			c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+			return
+		}
+		for _, writeErr := range bulkErr.WriteErrors {
+			result := &response.Results[indexes[writeErr.Index]]
+			result.Status = http.StatusInternalServerError
+			if mongo.IsDuplicateKeyError(writeErr.WriteError) {
+				result.Status = http.StatusConflict
+			}
+			result.Todo = nil
+			result.Error = writeErr.Message
+		}
+	}
+
+	for _, result := range response.Results {
+		if result.Status == http.StatusCreated {
+			response.Created++
+		} else {
+			response.Failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CompleteTodosInput names the todos to complete.
+type CompleteTodosInput struct {
+	IDs []string `json:"ids" binding:"required,min=1,max=500"`
+}
+
+// CompleteTodosResponse reports how many todos a bulk completion completed.
+type CompleteTodosResponse struct {
+	Modified int64 `json:"modified" example:"3"`
+}
+
+// InvalidIDsResponse lists the ids of a request that are not ObjectIDs.
+type InvalidIDsResponse struct {
+	Error      string   `json:"error" example:"invalid ids"`
+	InvalidIDs []string `json:"invalid_ids"`
+}
+
+// @Summary		Complete todos in bulk
+// @ID				complete-todos-bulk
+// @Tags			Todos
+// @Description	Complete up to 500 pending todos by id with a single update. Missing and already completed todos are skipped, so modified may be lower than the number of ids. Cached todo responses of the current user are dropped. The X-Undo-Token response header can be posted to /undo within the undo window to make the todos this request completed pending again.
+// @Accept			json
+// @Produce		json
+// @Param			data			body	controller.CompleteTodosInput	true	"Ids of the todos to complete"
+// @Param			Authorization	header	string							false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	controller.CompleteTodosResponse
+// @Failure		400	{object}	controller.InvalidIDsResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/complete [post]
+func (h *TodoController) CompleteTodosHandler(cache *model.RedisCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input CompleteTodosInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			var ve validator.ValidationErrors
+			if errors.As(err, &ve) {
+				out := make([]ErrorMsg, len(ve))
+				for i, fe := range ve {
+					out[i] = ErrorMsg{fe.Field(), getErrorMsg(fe)}
+				}
+				c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{Errors: out})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		ids := make([]primitive.ObjectID, 0, len(input.IDs))
+		var invalid []string
+		for _, hex := range input.IDs {
+			id, err := primitive.ObjectIDFromHex(hex)
+			if err != nil {
+				invalid = append(invalid, hex)
+				continue
+			}
+			ids = append(ids, id)
+		}
+		if len(invalid) > 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, InvalidIDsResponse{Error: "invalid ids", InvalidIDs: invalid})
+			return
+		}
+
+		ctx, ok := requestContext(c)
+		if !ok {
+			return
+		}
+
+		var pending []*model.Todo
+		if h.undo != nil {
+			var err error
+			if pending, err = h.pendingTodos(ctx, ids); err != nil {
+				c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+				return
+			}
+		}
+
+		modified, spawned, err := h.todos.CompleteMany(ctx, ids)
+		if modified > 0 {
+			if err := cache.InvalidateTodos(ctx, middleware.CurrentUserName(c)); err != nil {
+				_ = c.Error(err)
+			}
+			h.saveBulkUndo(ctx, c, pending, spawned)
+		}
+		if err != nil {
+			c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, CompleteTodosResponse{Modified: modified})
+	}
+}
+
+// pendingTodos returns the todos among ids that exist and are pending,
+// with a single read.
+func (h *TodoController) pendingTodos(ctx context.Context, ids []primitive.ObjectID) ([]*model.Todo, error) {
+	todos, err := h.todos.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	var pending []*model.Todo
+	for _, todo := range todos {
+		if !todo.Completed {
+			pending = append(pending, todo)
+		}
+	}
+	return pending, nil
+}
+
+// saveBulkUndo hands out an X-Undo-Token that makes the todos of pending
+// the bulk completion did complete pending again, and deletes the next
+// occurrences it spawned. Todos it skipped, such as blocked ones, are left
+// out so undo never overwrites later changes to them.
+func (h *TodoController) saveBulkUndo(ctx context.Context, c *gin.Context, pending []*model.Todo, spawned []primitive.ObjectID) {
+	ids := make([]primitive.ObjectID, len(pending))
+	for i, todo := range pending {
+		ids[i] = todo.ID
+	}
+	now, err := h.todos.GetByIDs(ctx, ids)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	done := map[primitive.ObjectID]bool{}
+	for _, todo := range now {
+		done[todo.ID] = todo.Completed
+	}
+	var completed []*model.Todo
+	for _, todo := range pending {
+		if done[todo.ID] {
+			completed = append(completed, todo)
+		}
+	}
+	if len(completed) == 0 {
+		return
+	}
+
+	token, err := h.undo.Save(ctx, &model.UndoSnapshot{Todos: completed, Bulk: true, Spawned: spawned})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Header("X-Undo-Token", token)
+}
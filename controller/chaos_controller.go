@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// GetChaosHandler reports the faults chaos mode is currently injecting.
+func GetChaosHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, model.CurrentChaos())
+}
+
+// PutChaosHandler replaces the faults chaos mode injects into Mongo and
+// Redis operations. An all-zero spec turns injection off.
+func PutChaosHandler(c *gin.Context) {
+	var spec model.ChaosSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := model.SetChaos(spec); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.CurrentChaos())
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/CharlesPatterson/todos-app/middleware"
+	"github.com/CharlesPatterson/todos-app/model"
+	cache "github.com/chenyahui/gin-cache"
+	"github.com/gin-gonic/gin"
+)
+
+// chaosRepository fails the operations of the repository it wraps according
+// to the mongo fault spec, as the chaos dialer does for MongoDB.
+type chaosRepository struct {
+	model.TodoRepository
+}
+
+func (r chaosRepository) Create(ctx context.Context, todo *model.Todo) error {
+	if err := model.InjectMongoFault(ctx); err != nil {
+		return err
+	}
+	return r.TodoRepository.Create(ctx, todo)
+}
+
+func (r chaosRepository) GetByID(ctx context.Context, id string) (*model.Todo, error) {
+	if err := model.InjectMongoFault(ctx); err != nil {
+		return nil, err
+	}
+	return r.TodoRepository.GetByID(ctx, id)
+}
+
+func (r chaosRepository) List(ctx context.Context, query model.TodoQuery) ([]*model.Todo, int64, error) {
+	if err := model.InjectMongoFault(ctx); err != nil {
+		return nil, 0, err
+	}
+	return r.TodoRepository.List(ctx, query)
+}
+
+// setChaos turns chaos mode on with spec for the rest of the test.
+func setChaos(t *testing.T, spec model.ChaosSpec) {
+	t.Helper()
+
+	t.Setenv("CHAOS_MODE", "true")
+	t.Setenv("ENVIRONMENT", "test")
+	if err := model.SetChaos(spec); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := model.SetChaos(model.ChaosSpec{}); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestMongoErrorsDegradeTo5xx(t *testing.T) {
+	repo := model.NewMemoryRepository()
+	todo := &model.Todo{Text: "water plants"}
+	if err := repo.Create(context.Background(), todo); err != nil {
+		t.Fatal(err)
+	}
+
+	setChaos(t, model.ChaosSpec{Mongo: model.FaultSpec{ErrorRate: 0.3}})
+	todos := NewTodoController(chaosRepository{repo}, nil)
+	r := gin.New()
+	r.GET("/todos", todos.GetAllTodosHandler)
+	r.GET("/todos/:id", todos.GetTodoByIdHandler)
+
+	codes := map[int]int{}
+	for i := 0; i < 200; i++ {
+		path := "/todos"
+		if i%2 == 1 {
+			path += "/" + todo.ID.Hex()
+		}
+		w := serve(t, r, http.MethodGet, path, nil)
+		codes[w.Code]++
+		if w.Code == http.StatusOK {
+			continue
+		}
+		if w.Code < 500 {
+			t.Fatalf("GET %s: got %d %s, want 200 or 5xx", path, w.Code, w.Body.String())
+		}
+		var res ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Error == "" {
+			t.Fatalf("GET %s: %d body %q is not an error envelope", path, w.Code, w.Body.String())
+		}
+	}
+	if codes[http.StatusOK] == 0 || codes[http.StatusInternalServerError] == 0 {
+		t.Errorf("got status counts %v, want both successes and injected 500s", codes)
+	}
+}
+
+func TestRedisErrorsFallBackToHandler(t *testing.T) {
+	setChaos(t, model.ChaosSpec{Redis: model.FaultSpec{ErrorRate: 1}})
+	redisCache := model.SetupRedisCache()
+
+	todos := NewTodoController(model.NewMemoryRepository(), nil)
+	r := gin.New()
+	r.GET("/todos", cache.CacheByRequestURI(redisCache.Store, redisCache.DefaultCacheTime,
+		cache.WithCacheStrategyByRequest(middleware.CacheByUserAndRequestURI)), todos.GetAllTodosHandler)
+	r.POST("/todos", todos.CreateTodoHandler)
+
+	createTodo(t, r, map[string]interface{}{"text": "call the plumber"})
+	for i := 0; i < 2; i++ {
+		w := serve(t, r, http.MethodGet, "/todos", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /todos with Redis failing: got %d %s, want 200", w.Code, w.Body.String())
+		}
+		var listed []*model.Todo
+		decode(t, w, &listed)
+		if len(listed) != 1 {
+			t.Errorf("GET /todos with Redis failing: got %d todos, want 1 from the store", len(listed))
+		}
+	}
+}
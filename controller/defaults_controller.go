@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultsInput sets the values new todos take for the fields their
+// request leaves out.
+type DefaultsInput struct {
+	Tags      []string `json:"tags" binding:"max=20,dive,max=32" example:"inbox"`
+	DueInDays *int     `json:"due_in_days" binding:"omitempty,gte=0,lte=3650" example:"7"`
+	Project   string   `json:"project" binding:"max=64" example:"work"`
+}
+
+// ProjectDefaultsInput overrides the defaults for the new todos of one
+// project. Tags left out fall back to the user's, while an empty list
+// leaves the todos untagged.
+type ProjectDefaultsInput struct {
+	Tags      []string `json:"tags" binding:"max=20,dive,max=32" example:"office"`
+	DueInDays *int     `json:"due_in_days" binding:"omitempty,gte=0,lte=3650" example:"2"`
+}
+
+// bindDefaults binds the JSON body into input, answering 400 and returning
+// false when it is invalid.
+func bindDefaults(c *gin.Context, input interface{}) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrorMsg, len(ve))
+			for i, fe := range ve {
+				out[i] = ErrorMsg{fe.Field(), getErrorMsg(fe)}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{Errors: out})
+			return false
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return false
+	}
+	return true
+}
+
+// @Summary		Get my defaults for new todos
+// @ID				get-my-defaults
+// @Tags			Defaults
+// @Description	Get the tags, due date offset and project new todos of the current user take when their request leaves them out, along with the overrides per project.
+// @Produce		json
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Defaults
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/me/defaults [get]
+func (h *TodoController) GetDefaultsHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	defaults, err := h.todos.Defaults(ctx)
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, defaults)
+}
+
+// @Summary		Set my defaults for new todos
+// @ID				set-my-defaults
+// @Tags			Defaults
+// @Description	Replace the tags, due date offset in days and project new todos of the current user take when their request leaves them out. A request's own values always win, and the overrides of the todo's project win over these. The overrides per project are kept.
+// @Accept			json
+// @Produce		json
+// @Param			data			body	controller.DefaultsInput	true	"Defaults"
+// @Param			Authorization	header	string					false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Defaults
+// @Failure		400	{object}	controller.ValidationErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/me/defaults [put]
+func (h *TodoController) SetDefaultsHandler(c *gin.Context) {
+	var input DefaultsInput
+	if !bindDefaults(c, &input) {
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	defaults, err := h.todos.Defaults(ctx)
+	if err == nil {
+		defaults.Tags, defaults.DueInDays, defaults.Project = input.Tags, input.DueInDays, input.Project
+		err = h.todos.SetDefaults(ctx, defaults)
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, defaults)
+}
+
+// @Summary		Set my defaults for a project
+// @ID				set-my-project-defaults
+// @Tags			Defaults
+// @Description	Override the tags and due date offset in days that new todos of the current user take in a project, whether the project was given or came from the user's defaults. Fields left out fall back to the user's defaults.
+// @Accept			json
+// @Produce		json
+// @Param			project			path	string							true	"Project name"
+// @Param			data			body	controller.ProjectDefaultsInput	true	"Overrides"
+// @Param			Authorization	header	string							false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Defaults
+// @Failure		400	{object}	controller.ValidationErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/me/defaults/projects/{project} [put]
+func (h *TodoController) SetProjectDefaultsHandler(c *gin.Context) {
+	var input ProjectDefaultsInput
+	if !bindDefaults(c, &input) {
+		return
+	}
+	if model.NormalizeProject(c.Param("project")) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: model.ErrEmptyProject.Error()})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	defaults, err := h.todos.Defaults(ctx)
+	if err == nil {
+		defaults.SetProject(model.ProjectDefaults{Project: c.Param("project"), Tags: input.Tags, DueInDays: input.DueInDays})
+		err = h.todos.SetDefaults(ctx, defaults)
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, defaults)
+}
+
+// @Summary		Remove my defaults for a project
+// @ID				delete-my-project-defaults
+// @Tags			Defaults
+// @Description	Drop the overrides of a project, so its new todos take the user's defaults again.
+// @Param			project			path	string	true	"Project name"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		204
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/me/defaults/projects/{project} [delete]
+func (h *TodoController) DeleteProjectDefaultsHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	defaults, err := h.todos.Defaults(ctx)
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !defaults.RemoveProject(c.Param("project")) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "the project has no defaults"})
+		return
+	}
+	if err := h.todos.SetDefaults(ctx, defaults); err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
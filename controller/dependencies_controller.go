@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BlockedResponse is the body of a 409 response to completing a todo whose
+// blocking todos are not all completed, listing the pending ones.
+type BlockedResponse struct {
+	Error     string   `json:"error" example:"todo is blocked by 1 pending todos"`
+	BlockedBy []string `json:"blocked_by" example:"66b1f0c2e4b0a1a2b3c4d5e6"`
+}
+
+func newBlockedResponse(blocked *model.BlockedError) BlockedResponse {
+	ids := make([]string, len(blocked.BlockedBy))
+	for i, id := range blocked.BlockedBy {
+		ids[i] = id.Hex()
+	}
+	return BlockedResponse{Error: blocked.Error(), BlockedBy: ids}
+}
+
+// abortOnDependencyError responds, and returns true, if err is about the
+// todos blocking a todo: 409 with the pending ones when they prevent its
+// completion, 400 when they are missing or would form a cycle.
+func abortOnDependencyError(c *gin.Context, err error) bool {
+	var blocked *model.BlockedError
+	switch {
+	case errors.As(err, &blocked):
+		c.AbortWithStatusJSON(http.StatusConflict, newBlockedResponse(blocked))
+	case errors.Is(err, model.ErrBlockerNotFound), errors.Is(err, model.ErrDependencyCycle):
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+// @Summary		List the todos blocking a todo
+// @ID				get-todo-blockers
+// @Tags			Todos
+// @Description	List the todos in blocked_by that are still pending and keep the todo from being completed. Deleted blocking todos no longer block.
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{array}		model.Todo
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/blockers [get]
+func (h *TodoController) GetBlockersHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	todos, err := h.todos.Blockers(ctx, c.Param("id"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todos)
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+)
+
+// ErrorResponse is the body of error responses carrying a single message.
+type ErrorResponse struct {
+	Error string `json:"error" example:"todo not found"`
+}
+
+// ValidationErrorResponse is the body of a 400 response listing each field
+// that failed validation.
+type ValidationErrorResponse struct {
+	Errors []ErrorMsg `json:"errors"`
+}
+
+// UnauthorizedResponse is the body the JWT middleware sends when a request
+// is not authenticated or not allowed.
+type UnauthorizedResponse struct {
+	Code    int    `json:"code" example:"401"`
+	Message string `json:"message" example:"cookie token is empty"`
+}
+
+// storeErrorStatus is the status for a failed model call: 504 when the
+// database ran out of the request's time, 500 for anything else.
+func storeErrorStatus(err error) int {
+	if errors.Is(err, model.ErrDeadline) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportPath is served outside the gzip and timeout middlewares, which both
+// buffer the whole response; the handler compresses and flushes on its own.
+const ExportPath = "/api/v1/todos/export"
+
+// exportFlushEvery is the number of records written between flushes.
+const exportFlushEvery = 100
+
+// @Summary		Export all todos
+// @ID				export-todos
+// @Tags			Todos
+// @Description	Stream every todo as newline-delimited JSON. The number of exported records is sent in the X-Export-Count trailer.
+// @Produce		json
+// @Param			include_deleted	query	bool	false	"Also return todos in the trash, admins only"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{array}	model.Todo
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		403	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Router			/todos/export [get]
+func (h *TodoController) ExportTodosHandler(c *gin.Context) {
+	ctx, ok := readContext(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Trailer", "X-Export-Count, X-Export-Error")
+
+	var w io.Writer = c.Writer
+	var gz *gzip.Writer
+	if strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+		c.Header("Content-Encoding", "gzip")
+		c.Header("Vary", "Accept-Encoding")
+		gz = gzip.NewWriter(c.Writer)
+		w = gz
+	}
+	c.Status(http.StatusOK)
+
+	flush := func() error {
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	enc := json.NewEncoder(w)
+	var written int
+	count, err := h.todos.Stream(ctx, func(t *model.Todo) error {
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		_ = c.Error(err)
+		c.Writer.Header().Set("X-Export-Error", err.Error())
+	}
+
+	if gz != nil {
+		_ = gz.Close()
+	}
+	c.Writer.Header().Set("X-Export-Count", strconv.FormatInt(count, 10))
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// exportBenchmarkTodos is how many todos the export benchmarks stream.
+const exportBenchmarkTodos = 100_000
+
+// discardResponseWriter counts what is written to it without keeping it,
+// so the benchmarks measure the export rather than a growing buffer.
+type discardResponseWriter struct {
+	header  http.Header
+	status  int
+	written int64
+}
+
+func (w *discardResponseWriter) Header() http.Header { return w.header }
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	w.written += int64(len(b))
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(status int) { w.status = status }
+
+func (w *discardResponseWriter) Flush() {}
+
+func BenchmarkExportTodos(b *testing.B) {
+	repo := model.NewMemoryRepository()
+	todos := make([]*model.Todo, exportBenchmarkTodos)
+	now := model.Now()
+	for i := range todos {
+		todos[i] = &model.Todo{
+			ID:        primitive.NewObjectID(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Text:      fmt.Sprintf("todo %d", i),
+			Tags:      []string{"bench"},
+			Project:   "export",
+		}
+	}
+	if err := repo.CreateMany(context.Background(), todos); err != nil {
+		b.Fatal(err)
+	}
+
+	controller := NewTodoController(repo, nil)
+	r := gin.New()
+	r.GET("/todos/export", controller.ExportTodosHandler)
+
+	for _, encoding := range []string{"identity", "gzip"} {
+		b.Run(encoding, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/todos/export", nil)
+				req.Header.Set("Accept-Encoding", encoding)
+				w := &discardResponseWriter{header: http.Header{}}
+				r.ServeHTTP(w, req)
+				if w.status != http.StatusOK || w.header.Get("X-Export-Count") != fmt.Sprint(exportBenchmarkTodos) {
+					b.Fatalf("GET /todos/export: got %d with count %q, want 200 with %d", w.status, w.header.Get("X-Export-Count"), exportBenchmarkTodos)
+				}
+				b.SetBytes(w.written)
+			}
+		})
+	}
+}
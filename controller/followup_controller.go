@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FollowupResponse holds the completed todo and the follow-up created for it.
+type FollowupResponse struct {
+	Completed *model.Todo `json:"completed"`
+	Followup  *model.Todo `json:"followup"`
+}
+
+// @Summary		Complete a todo and add a follow-up
+// @ID				complete-with-followup
+// @Tags			Todos
+// @Description	Complete a todo and create the todo to do next from the body, linked through followup_of, as one operation. The follow-up takes the project and tags of the completed todo unless it sets its own.
+// @Produce		json
+// @Param			id				path	string				true	"Todo ID"
+// @Param			data			body	model.TodoDocInput	true	"Follow-up todo"
+// @Param			Authorization	header	string				false	"Authorization"
+// @Security		JWT
+// @Success		201	{object}	controller.FollowupResponse
+// @Failure		400	{object}	controller.ValidationErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		409	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/complete-with-followup [post]
+func (h *TodoController) CompleteWithFollowupHandler(c *gin.Context) {
+	var followup model.Todo
+	if err := c.BindJSON(&followup); err != nil {
+
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrorMsg, len(ve))
+			for i, fe := range ve {
+				out[i] = ErrorMsg{fe.Field(), getErrorMsg(fe)}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{Errors: out})
+		}
+		return
+	}
+	if model.NormalizeText(followup.Text) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{
+			Errors: []ErrorMsg{{Field: "Text", Message: "This field is required"}},
+		})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	completed, created, err := h.todos.CompleteWithFollowup(ctx, c.Param("id"), &followup)
+	if abortOnInvalidRecurrence(c, err) || abortOnDependencyError(c, err) {
+		return
+	}
+	if errors.Is(err, model.ErrParentNotFound) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if errors.Is(err, model.ErrAlreadyCompleted) || errors.Is(err, model.ErrDuplicateTodo) {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, FollowupResponse{Completed: completed, Followup: created})
+}
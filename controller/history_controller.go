@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// @Summary		Get the history of a todo
+// @ID				get-history
+// @Tags			Todos
+// @Description	List the updates, completions and deletions of a todo, newest first, each with the todo as it was before the change
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			limit			query	int		false	"Maximum number of entries, defaults to 20"
+// @Param			offset			query	int		false	"Number of entries to skip"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{array}		model.HistoryEntry
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/history [get]
+func (h *TodoController) GetHistoryHandler(c *gin.Context) {
+	limit, err := queryInt(c, "limit", defaultHistoryLimit)
+	if err != nil || limit < 1 || limit > maxHistoryLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "limit must be between 1 and " + strconv.Itoa(maxHistoryLimit)})
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "offset must not be negative"})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.todos.History(ctx, c.Param("id"), int64(offset), int64(limit))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
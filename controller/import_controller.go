@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ImportPath is served outside the timeout middleware since large files take
+// longer than a regular request to insert.
+const ImportPath = "/api/v1/todos/import"
+
+// importConcurrency is the number of batches an upload inserts in parallel.
+const importConcurrency = 4
+
+// @Summary		Import todos
+// @ID				import-todos
+// @Tags			Todos
+// @Description	Import todos from an uploaded CSV file with a header row. Rows failing validation are reported by line number and skipped.
+// @Accept			multipart/form-data
+// @Produce		json
+// @Param			format			query		string	false	"File format, only csv is supported"
+// @Param			dry_run			query		bool	false	"Validate the file without importing anything"
+// @Param			file			formData	file	true	"CSV file"
+// @Param			map				formData	string	false	"Column mapping onto text, completed, created_at, due_date, tags and project, e.g. text=Task,completed=Done,due_date=Due"
+// @Param			Authorization	header		string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.ImportResult
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Router			/todos/import [post]
+func (h *TodoController) ImportTodosHandler(c *gin.Context) {
+	if format := c.DefaultQuery("format", "csv"); format != "csv" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "unsupported format " + format + ", only csv is supported"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer file.Close()
+
+	reader, err := model.NewCSVReader(file)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	mapping := reader.DefaultColumnMapping()
+	if spec := c.PostForm("map"); spec != "" {
+		mapping, err = model.ParseColumnMapping(spec)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	if err := reader.SetMapping(mapping); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	result, err := model.ImportTodos(ctx, h.todos, reader, model.ImportOptions{
+		DryRun:      c.Query("dry_run") == "true",
+		Concurrency: importConcurrency,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
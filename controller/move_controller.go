@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MoveInput places a todo either directly after another todo or at an
+// explicit position. Exactly one of the two must be given.
+type MoveInput struct {
+	After    *string  `json:"after,omitempty" example:"66b1f0c2e4b0a1a2b3c4d5e6"`
+	Position *float64 `json:"position,omitempty" example:"1536"`
+}
+
+// @Summary		Move a todo
+// @ID				move-todo
+// @Tags			Todos
+// @Description	Reorder a todo, either directly after the todo with id after or to an explicit position. Positions are rebalanced when there is no room left between two todos.
+// @Produce		json
+// @Param			id				path	string					true	"Todo ID"
+// @Param			data			body	controller.MoveInput	true	"Where to move the todo"
+// @Param			Authorization	header	string					false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/move [post]
+func (h *TodoController) MoveTodoHandler(c *gin.Context) {
+	var input MoveInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if (input.After == nil) == (input.Position == nil) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "exactly one of after and position is required"})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	var todo *model.Todo
+	var err error
+	if input.After != nil {
+		todo, err = h.todos.MoveAfter(ctx, c.Param("id"), *input.After)
+	} else {
+		todo, err = h.todos.SetPosition(ctx, c.Param("id"), *input.Position)
+	}
+	if errors.Is(err, model.ErrMoveAfterSelf) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/CharlesPatterson/todos-app/middleware"
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultNotesLimit = 20
+	maxNotesLimit     = 100
+)
+
+type NoteInput struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// @Summary		Append a note to a todo
+// @ID				add-note
+// @Tags			Notes
+// @Description	Notes are immutable once written; the author is taken from the JWT identity.
+// @Produce		json
+// @Param			id				path	string					true	"Todo ID"
+// @Param			data			body	controller.NoteInput	true	"Note"
+// @Param			Authorization	header	string					false	"Authorization"
+// @Security		JWT
+// @Success		201	{object}	model.Note
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/notes [post]
+func (h *TodoController) AddNoteHandler(c *gin.Context) {
+	var input NoteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if model.NormalizeText(input.Text) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "note text must not be empty"})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.todos.AddNote(ctx, c.Param("id"), middleware.CurrentUserName(c), input.Text)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, note)
+}
+
+// @Summary	Get the notes of a todo
+// @ID			get-notes
+// @Tags		Notes
+// @Produce	json
+// @Param		id				path	string	true	"Todo ID"
+// @Param		limit			query	int		false	"Maximum number of notes, defaults to 20"
+// @Param		offset			query	int		false	"Number of notes to skip"
+// @Param		Authorization	header	string	false	"Authorization"
+// @Security	JWT
+// @Success	200	{array}	model.Note
+// @Failure	400	{object}	controller.ErrorResponse
+// @Failure	401	{object}	controller.UnauthorizedResponse
+// @Failure	404	{object}	controller.ErrorResponse
+// @Failure	408	{object}	controller.ErrorResponse
+// @Failure	500	{object}	controller.ErrorResponse
+// @Failure	504	{object}	controller.ErrorResponse
+// @Router		/todos/{id}/notes [get]
+func (h *TodoController) GetNotesHandler(c *gin.Context) {
+	limit, err := queryInt(c, "limit", defaultNotesLimit)
+	if err != nil || limit < 1 || limit > maxNotesLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "limit must be between 1 and " + strconv.Itoa(maxNotesLimit)})
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "offset must not be negative"})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	notes, err := h.todos.Notes(ctx, c.Param("id"), int64(offset), int64(limit))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+// @Summary		Delete a note
+// @ID				delete-note
+// @Tags			Notes
+// @Description	Only administrators may delete notes.
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			noteId			path	string	true	"Note ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		204
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		403	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/notes/{noteId} [delete]
+func (h *TodoController) DeleteNoteHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	err := h.todos.DeleteNote(ctx, c.Param("id"), c.Param("noteId"))
+	if errors.Is(err, model.ErrNoteNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, "")
+}
+
+// queryInt parses an integer query parameter, returning def when absent.
+func queryInt(c *gin.Context, name string, def int) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return def, nil
+	}
+	return strconv.Atoi(raw)
+}
@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CharlesPatterson/todos-app/docs"
+)
+
+// errorEnvelopes are the Go types error responses are documented with.
+var errorEnvelopes = []interface{}{
+	ErrorResponse{},
+	ValidationErrorResponse{},
+	UnauthorizedResponse{},
+	BlockedResponse{},
+	InvalidIDsResponse{},
+	ProjectInUseResponse{},
+}
+
+type openAPISchema struct {
+	Type       string                    `json:"type"`
+	Ref        string                    `json:"$ref"`
+	Items      *openAPISchema            `json:"items"`
+	Properties map[string]*openAPISchema `json:"properties"`
+}
+
+type openAPIDoc struct {
+	Paths map[string]map[string]struct {
+		Responses map[string]struct {
+			Schema *openAPISchema `json:"schema"`
+		} `json:"responses"`
+	} `json:"paths"`
+	Definitions map[string]*openAPISchema `json:"definitions"`
+}
+
+func readOpenAPIDoc(t *testing.T) *openAPIDoc {
+	t.Helper()
+
+	doc := &openAPIDoc{}
+	if err := json.Unmarshal([]byte(docs.SwaggerInfo.ReadDoc()), doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// definitionName is the name swag gives the definition of type t.
+func definitionName(t reflect.Type) string {
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+// schemaOf is the schema swag generates for a value of type t.
+func schemaOf(t reflect.Type) *openAPISchema {
+	switch t.Kind() {
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice:
+		return &openAPISchema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.Struct:
+		return &openAPISchema{Ref: "#/definitions/" + definitionName(t)}
+	}
+	return &openAPISchema{Type: t.Kind().String()}
+}
+
+// checkDefinition compares the documented definition of the struct type t,
+// and of the structs it nests, with its fields.
+func checkDefinition(t *testing.T, doc *openAPIDoc, typ reflect.Type) {
+	t.Helper()
+
+	name := definitionName(typ)
+	def, ok := doc.Definitions[name]
+	if !ok {
+		t.Errorf("%s is not documented", name)
+		return
+	}
+
+	fields := map[string]bool{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || key == "-" {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		fields[key] = true
+
+		want := schemaOf(field.Type)
+		got, ok := def.Properties[key]
+		if !ok {
+			t.Errorf("%s: field %q is not documented", name, key)
+			continue
+		}
+		if !reflect.DeepEqual(stripProperties(got), want) {
+			t.Errorf("%s: field %q is documented as %+v, want %+v", name, key, *got, *want)
+		}
+		for elem := field.Type; ; elem = elem.Elem() {
+			if elem.Kind() == reflect.Struct {
+				checkDefinition(t, doc, elem)
+				break
+			}
+			if elem.Kind() != reflect.Slice && elem.Kind() != reflect.Pointer {
+				break
+			}
+		}
+	}
+	for key := range def.Properties {
+		if !fields[key] {
+			t.Errorf("%s: documented property %q is not a field", name, key)
+		}
+	}
+}
+
+// stripProperties drops the inline properties swag never emits for fields,
+// so a schema compares equal to the one built by schemaOf.
+func stripProperties(s *openAPISchema) *openAPISchema {
+	if s == nil {
+		return nil
+	}
+	return &openAPISchema{Type: s.Type, Ref: s.Ref, Items: stripProperties(s.Items)}
+}
+
+func TestOpenAPIErrorEnvelopes(t *testing.T) {
+	doc := readOpenAPIDoc(t)
+	for _, envelope := range errorEnvelopes {
+		checkDefinition(t, doc, reflect.TypeOf(envelope))
+	}
+}
+
+func TestOpenAPIRoutesDocumentErrors(t *testing.T) {
+	doc := readOpenAPIDoc(t)
+	envelopes := map[string]bool{}
+	for _, envelope := range errorEnvelopes {
+		envelopes["#/definitions/"+definitionName(reflect.TypeOf(envelope))] = true
+	}
+
+	if len(doc.Paths) == 0 {
+		t.Fatal("the OpenAPI document has no paths")
+	}
+	for route, operations := range doc.Paths {
+		for method, op := range operations {
+			var failures int
+			for code, res := range op.Responses {
+				status, err := strconv.Atoi(code)
+				if err != nil || status < 400 {
+					continue
+				}
+				failures++
+				if res.Schema == nil || !envelopes[res.Schema.Ref] {
+					t.Errorf("%s %s: %s response is not an error envelope", strings.ToUpper(method), route, code)
+				}
+			}
+			if failures == 0 {
+				t.Errorf("%s %s documents no error responses", strings.ToUpper(method), route)
+			}
+		}
+	}
+}
+
+func TestOpenAPIFilesMatchDocs(t *testing.T) {
+	raw, err := os.ReadFile("../docs/swagger.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var file, embedded interface{}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(docs.SwaggerInfo.ReadDoc()), &embedded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file, embedded) {
+		t.Error("docs/swagger.json differs from docs/docs.go, regenerate them with make docs")
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// @Summary		Pin a todo
+// @ID				pin-todo
+// @Tags			Todos
+// @Description	List a todo before the unpinned ones in the default order
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/pin [post]
+func (h *TodoController) PinTodoHandler(c *gin.Context) {
+	h.setPinned(c, true)
+}
+
+// @Summary	Unpin a todo
+// @ID			unpin-todo
+// @Tags		Todos
+// @Produce	json
+// @Param		id				path	string	true	"Todo ID"
+// @Param		Authorization	header	string	false	"Authorization"
+// @Security	JWT
+// @Success	200	{object}	model.Todo
+// @Failure	401	{object}	controller.UnauthorizedResponse
+// @Failure	404	{object}	controller.ErrorResponse
+// @Failure	408	{object}	controller.ErrorResponse
+// @Failure	500	{object}	controller.ErrorResponse
+// @Failure	504	{object}	controller.ErrorResponse
+// @Router		/todos/{id}/unpin [post]
+func (h *TodoController) UnpinTodoHandler(c *gin.Context) {
+	h.setPinned(c, false)
+}
+
+func (h *TodoController) setPinned(c *gin.Context, pinned bool) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.SetPinned(ctx, c.Param("id"), pinned)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// @Summary		List projects
+// @ID				get-projects
+// @Tags			Projects
+// @Description	List the projects todos are grouped into, with how many of their todos are pending. Archived and deleted todos are not counted.
+// @Produce		json
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{array}		model.ProjectSummary
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/projects [get]
+func (h *TodoController) GetProjectsHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	projects, err := h.todos.Projects(ctx)
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+// ProjectInUseResponse refuses to delete a project without a strategy for
+// the todos in it.
+type ProjectInUseResponse struct {
+	Error string `json:"error" example:"project holds 12 todos, pass a strategy of orphan, archive or delete"`
+	Todos int64  `json:"todos" example:"12"`
+}
+
+// @Summary		Delete a project
+// @ID				delete-project
+// @Tags			Projects
+// @Description	Delete a project by applying a strategy to every todo in it outside the trash: orphan takes them out of the project, archive archives them and delete moves them to the trash. Todos with subtasks in other projects are kept by delete. Without a strategy nothing changes and the todos are counted in a 409 response.
+// @Produce		json
+// @Param			name			path	string	true	"Project name"
+// @Param			strategy		query	string	false	"What to do with the project's todos"	Enums(orphan, archive, delete)
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.ProjectDeletion
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		409	{object}	controller.ProjectInUseResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/projects/{name} [delete]
+func (h *TodoController) DeleteProjectHandler(c *gin.Context) {
+	var strategy model.ProjectStrategy
+	if raw := c.Query("strategy"); raw != "" {
+		var err error
+		if strategy, err = model.ParseProjectStrategy(raw); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	deletion, err := h.todos.DeleteProject(ctx, c.Param("name"), strategy, strategy == "")
+	if errors.Is(err, model.ErrProjectNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+	if strategy == "" {
+		c.JSON(http.StatusConflict, ProjectInUseResponse{
+			Error: fmt.Sprintf("project holds %d todos, pass a strategy of orphan, archive or delete", deletion.Todos),
+			Todos: deletion.Todos,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletion)
+}
+
+// ProjectRenameInput renames a project, or merges it into another.
+type ProjectRenameInput struct {
+	From string `json:"from" binding:"required" example:"hmoe"`
+	To   string `json:"to" binding:"required" example:"home"`
+	// Merge allows renaming onto a project that is in use.
+	Merge bool `json:"merge"`
+}
+
+// @Summary		Rename a project
+// @ID				rename-project
+// @Tags			Projects
+// @Description	Move every todo in a project, including those in the trash and the archive, to a new project name. Renaming onto a project in use is refused unless merge is set, which combines the todos of both. Each moved todo records the change in its history, and the defaults for new todos follow the new name.
+// @Accept			json
+// @Produce		json
+// @Param			data			body	controller.ProjectRenameInput	true	"Rename"
+// @Param			Authorization	header	string						false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.ProjectRename
+// @Failure		400	{object}	controller.ValidationErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		409	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/projects/rename [post]
+func (h *TodoController) RenameProjectHandler(c *gin.Context) {
+	var input ProjectRenameInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrorMsg, len(ve))
+			for i, fe := range ve {
+				out[i] = ErrorMsg{fe.Field(), getErrorMsg(fe)}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{Errors: out})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	rename, err := h.todos.RenameProject(ctx, input.From, input.To, input.Merge)
+	switch {
+	case errors.Is(err, model.ErrEmptyProject) || errors.Is(err, model.ErrSameProject):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, model.ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	case errors.Is(err, model.ErrProjectExists):
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rename)
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/middleware"
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ReassignFilter narrows a reassignment to some of the user's todos.
+type ReassignFilter struct {
+	Project string `json:"project,omitempty" example:"home"`
+	Tag     string `json:"tag,omitempty" example:"errands"`
+}
+
+// ReassignInput moves the todos of one user to another.
+type ReassignInput struct {
+	From   string         `json:"from" binding:"required" example:"test"`
+	To     string         `json:"to" binding:"required" example:"admin"`
+	Filter ReassignFilter `json:"filter"`
+	DryRun bool           `json:"dry_run"`
+}
+
+// @Summary		Reassign todos to another user
+// @ID				reassign-todos
+// @Tags			Admin
+// @Description	Give the todos of from, optionally only those in a project or carrying a tag, to to. With dry_run only the matching todos are counted.
+// @Produce		json
+// @Param			data			body	controller.ReassignInput	true	"Reassignment"
+// @Param			Authorization	header	string						false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.ReassignResult
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		403	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/admin/todos/reassign [post]
+func (h *TodoController) ReassignTodosHandler(c *gin.Context) {
+	var input ReassignInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if input.From == input.To {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "from and to must be different users"})
+		return
+	}
+	if !middleware.KnownUser(input.To) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "unknown user " + input.To})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.todos.Reassign(ctx, input.From, input.To, model.TodoQuery{Project: input.Filter.Project, Tag: input.Filter.Tag}, input.DryRun)
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ReminderInput is when to be reminded of a todo.
+type ReminderInput struct {
+	At *time.Time `json:"at" binding:"required" example:"2024-05-01T09:00:00Z"`
+}
+
+// @Summary		Add a reminder to a todo
+// @ID				add-reminder
+// @Tags			Todos
+// @Description	Schedule a reminder for a pending todo, independent of its due date. Each reminder fires once. A todo has at most 10 outstanding reminders, and completing or deleting it cancels them.
+// @Accept			json
+// @Produce		json
+// @Param			id				path	string					true	"Todo ID"
+// @Param			data			body	controller.ReminderInput	true	"When to remind"
+// @Param			Authorization	header	string					false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		400	{object}	controller.ValidationErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		409	{object}	controller.ErrorResponse
+// @Failure		422	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/reminders [post]
+func (h *TodoController) AddReminderHandler(c *gin.Context) {
+	var input ReminderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrorMsg, len(ve))
+			for i, fe := range ve {
+				out[i] = ErrorMsg{fe.Field(), getErrorMsg(fe)}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{Errors: out})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.AddReminder(ctx, c.Param("id"), *input.At)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if errors.Is(err, model.ErrReminderInPast) || errors.Is(err, model.ErrTooManyReminders) {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, model.ErrAlreadyCompleted) {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
+
+// @Summary		Remove a reminder from a todo
+// @ID				remove-reminder
+// @Tags			Todos
+// @Description	Cancel a reminder of a todo that has not fired yet.
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			at				query	string	true	"RFC3339 time of the reminder"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/reminders [delete]
+func (h *TodoController) RemoveReminderHandler(c *gin.Context) {
+	at, err := time.Parse(time.RFC3339, c.Query("at"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "at must be an RFC3339 time"})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.RemoveReminder(ctx, c.Param("id"), at)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if errors.Is(err, model.ErrReminderNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
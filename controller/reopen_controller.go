@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// @Summary		Reopen a completed todo
+// @ID				reopen-todo
+// @Tags			Todos
+// @Description	Mark a completed todo as pending again and clear its completion time. Reopening a pending todo changes nothing.
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		409	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/reopen [post]
+func (h *TodoController) ReopenTodoHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.Reopen(ctx, c.Param("id"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if errors.Is(err, model.ErrDuplicateTodo) {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary		Search todos
+// @ID				search-todos
+// @Tags			Todos
+// @Description	Full-text search over the text of todos outside the trash and the archive, best match first. Words are stemmed and case is ignored.
+// @Produce		json
+// @Param			q				query	string	true	"Words to search for"
+// @Param			completed		query	bool	false	"Only return completed, or only pending, todos"
+// @Param			include_deleted	query	bool	false	"Also return todos in the trash, admins only"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{array}		model.Todo
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		403	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/search [get]
+func (h *TodoController) SearchTodosHandler(c *gin.Context) {
+	completed, ok := queryCompleted(c)
+	if !ok {
+		return
+	}
+
+	ctx, ok := readContext(c)
+	if !ok {
+		return
+	}
+
+	todos, err := h.todos.Search(ctx, c.Query("q"), completed)
+	if errors.Is(err, model.ErrEmptyQuery) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todos)
+}
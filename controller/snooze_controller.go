@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SnoozeInput is when a snoozed todo comes back, either as a time or as a
+// duration from now. Exactly one of them is given.
+type SnoozeInput struct {
+	Until *time.Time `json:"until,omitempty" binding:"required_without=For,excluded_with=For" example:"2024-05-01T09:00:00Z"`
+	For   string     `json:"for,omitempty" binding:"required_without=Until" example:"2h"`
+}
+
+// @Summary		Snooze a todo
+// @ID				snooze-todo
+// @Tags			Todos
+// @Description	Hide a pending todo from the pending list until a later time. It is still listed with all todos.
+// @Accept			json
+// @Produce		json
+// @Param			id				path	string					true	"Todo ID"
+// @Param			data			body	controller.SnoozeInput	true	"Until when"
+// @Param			Authorization	header	string					false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		400	{object}	controller.ValidationErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/snooze [post]
+func (h *TodoController) SnoozeTodoHandler(c *gin.Context) {
+	var input SnoozeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrorMsg, len(ve))
+			for i, fe := range ve {
+				out[i] = ErrorMsg{fe.Field(), getErrorMsg(fe)}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{Errors: out})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var until time.Time
+	if input.Until != nil {
+		until = *input.Until
+	} else {
+		d, err := model.ParseSnoozeDuration(input.For)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{
+				Errors: []ErrorMsg{{Field: "For", Message: "Should be a duration such as 2h or 3d"}},
+			})
+			return
+		}
+		until = model.Now().Add(d)
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.Snooze(ctx, c.Param("id"), until)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if errors.Is(err, model.ErrAlreadyCompleted) || errors.Is(err, model.ErrSnoozeInPast) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
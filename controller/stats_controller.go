@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary		Get todo statistics
+// @ID				get-todo-stats
+// @Tags			Todos
+// @Description	Count pending, completed and overdue todos, and how many were created and completed on each of the last 30 UTC days. Estimated and spent minutes are totalled, and compared per ISO week for the todos completed in the weeks those days touch. Archived todos are not counted, nor are deleted ones unless an admin sets include_deleted.
+// @Produce		json
+// @Param			include_deleted	query	bool	false	"Also count todos in the trash, admins only"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.TodoStats
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		403	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/stats [get]
+func (h *TodoController) GetStatsHandler(c *gin.Context) {
+	ctx, ok := readContext(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.todos.Stats(ctx)
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
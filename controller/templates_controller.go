@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TemplateInput is the name and todos of a template.
+type TemplateInput struct {
+	Name  string               `json:"name" binding:"required,max=64" example:"release steps"`
+	Items []model.TemplateItem `json:"items" binding:"required,min=1,max=500,dive"`
+}
+
+// bindTemplate binds the request body to a template, responding with 400,
+// and returning false, if it is invalid.
+func bindTemplate(c *gin.Context) (*model.Template, bool) {
+	var input TemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			out := make([]ErrorMsg, len(ve))
+			for i, fe := range ve {
+				out[i] = ErrorMsg{fe.Field(), getErrorMsg(fe)}
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{Errors: out})
+			return nil, false
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+	if model.NormalizeText(input.Name) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{
+			Errors: []ErrorMsg{{Field: "Name", Message: "This field is required"}},
+		})
+		return nil, false
+	}
+	for _, item := range input.Items {
+		if model.NormalizeText(item.Text) == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{
+				Errors: []ErrorMsg{{Field: "Text", Message: "This field is required"}},
+			})
+			return nil, false
+		}
+	}
+
+	return &model.Template{Name: input.Name, Items: input.Items}, true
+}
+
+// @Summary		List templates
+// @ID				get-templates
+// @Tags			Templates
+// @Produce		json
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{array}		model.Template
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/templates [get]
+func (h *TodoController) GetTemplatesHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	templates, err := h.todos.Templates(ctx)
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// @Summary		Create a template
+// @ID				create-template
+// @Tags			Templates
+// @Description	Save a named list of todos to create again and again with instantiate. Names are unique.
+// @Accept			json
+// @Produce		json
+// @Param			data			body	controller.TemplateInput	true	"Template"
+// @Param			Authorization	header	string						false	"Authorization"
+// @Security		JWT
+// @Success		201	{object}	model.Template
+// @Failure		400	{object}	controller.ValidationErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		409	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/templates [post]
+func (h *TodoController) CreateTemplateHandler(c *gin.Context) {
+	template, ok := bindTemplate(c)
+	if !ok {
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	err := h.todos.CreateTemplate(ctx, template)
+	if errors.Is(err, model.ErrDuplicateTemplate) {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// @Summary	Get a template
+// @ID			get-template
+// @Tags		Templates
+// @Produce	json
+// @Param		id				path	string	true	"Template ID"
+// @Param		Authorization	header	string	false	"Authorization"
+// @Security	JWT
+// @Success	200	{object}	model.Template
+// @Failure	401	{object}	controller.UnauthorizedResponse
+// @Failure	404	{object}	controller.ErrorResponse
+// @Failure	408	{object}	controller.ErrorResponse
+// @Failure	500	{object}	controller.ErrorResponse
+// @Failure	504	{object}	controller.ErrorResponse
+// @Router		/templates/{id} [get]
+func (h *TodoController) GetTemplateHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.todos.GetTemplateByID(ctx, c.Param("id"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// @Summary	Update a template
+// @ID			update-template
+// @Tags		Templates
+// @Accept		json
+// @Produce	json
+// @Param		id				path	string						true	"Template ID"
+// @Param		data			body	controller.TemplateInput	true	"Template"
+// @Param		Authorization	header	string						false	"Authorization"
+// @Security	JWT
+// @Success	200	{object}	model.Template
+// @Failure	400	{object}	controller.ValidationErrorResponse
+// @Failure	401	{object}	controller.UnauthorizedResponse
+// @Failure	404	{object}	controller.ErrorResponse
+// @Failure	408	{object}	controller.ErrorResponse
+// @Failure	409	{object}	controller.ErrorResponse
+// @Failure	500	{object}	controller.ErrorResponse
+// @Failure	504	{object}	controller.ErrorResponse
+// @Router		/templates/{id} [put]
+func (h *TodoController) UpdateTemplateHandler(c *gin.Context) {
+	template, ok := bindTemplate(c)
+	if !ok {
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.todos.UpdateTemplate(ctx, c.Param("id"), template)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "template not found"})
+		return
+	}
+	if errors.Is(err, model.ErrDuplicateTemplate) {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// @Summary		Delete a template
+// @ID				delete-template
+// @Tags			Templates
+// @Description	Delete a template for good. Todos already created from it are kept.
+// @Param			id				path	string	true	"Template ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		204
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/templates/{id} [delete]
+func (h *TodoController) DeleteTemplateHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	err := h.todos.DeleteTemplate(ctx, c.Param("id"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary		Create todos from a template
+// @ID				instantiate-template
+// @Tags			Templates
+// @Description	Create a pending todo for every item of the template, in order, with a single insert. With REJECT_DUPLICATE_TODOS set, an item duplicating a pending todo is refused with 409 and the other items are still created.
+// @Produce		json
+// @Param			id				path	string	true	"Template ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		201	{array}		model.Todo
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		409	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/templates/{id}/instantiate [post]
+func (h *TodoController) InstantiateTemplateHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.todos.GetTemplateByID(ctx, c.Param("id"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	todos, err := model.InstantiateTemplate(ctx, h.todos, template)
+	if errors.Is(err, model.ErrDuplicateTodo) {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, todos)
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/middleware"
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// slowRepository lists todos only after delay, giving up as the Mongo
+// repository does once the context of the call is done. cancelled is closed
+// when that happens.
+type slowRepository struct {
+	model.TodoRepository
+	delay     time.Duration
+	calls     chan struct{}
+	cancelled chan struct{}
+}
+
+func newSlowRepository(delay time.Duration) *slowRepository {
+	return &slowRepository{
+		TodoRepository: model.NewMemoryRepository(),
+		delay:          delay,
+		calls:          make(chan struct{}, 1),
+		cancelled:      make(chan struct{}),
+	}
+}
+
+func (r *slowRepository) List(ctx context.Context, query model.TodoQuery) ([]*model.Todo, int64, error) {
+	r.calls <- struct{}{}
+	select {
+	case <-time.After(r.delay):
+		return r.TodoRepository.List(ctx, query)
+	case <-ctx.Done():
+		close(r.cancelled)
+		return nil, 0, fmt.Errorf("%w: %v", model.ErrDeadline, ctx.Err())
+	}
+}
+
+// waitCancelled fails the test unless the store saw its context cancelled
+// within a second.
+func (r *slowRepository) waitCancelled(t *testing.T) {
+	t.Helper()
+
+	select {
+	case <-r.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the store call was not cancelled after the request gave up")
+	}
+}
+
+func TestTimeoutCancelsSlowStore(t *testing.T) {
+	repo := newSlowRepository(time.Minute)
+	todos := NewTodoController(repo, nil)
+	r := gin.New()
+	r.Use(middleware.TimeoutMiddleware())
+	r.GET("/todos", todos.GetAllTodosHandler)
+
+	start := time.Now()
+	w := serve(t, r, http.MethodGet, "/todos", nil)
+	if w.Code != http.StatusRequestTimeout {
+		t.Fatalf("GET /todos on a slow store: got %d %s, want 408", w.Code, w.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("GET /todos on a slow store answered after %s, want about the request timeout", elapsed)
+	}
+	repo.waitCancelled(t)
+}
+
+func TestStoreDeadlineIs504(t *testing.T) {
+	repo := newSlowRepository(time.Minute)
+	todos := NewTodoController(repo, nil)
+	r := gin.New()
+	r.GET("/todos", todos.GetAllTodosHandler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/todos", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("GET /todos past its deadline: got %d %s, want 504", w.Code, w.Body.String())
+	}
+	var res ErrorResponse
+	decode(t, w, &res)
+	if res.Error == "" {
+		t.Errorf("GET /todos past its deadline: body %q is not an error envelope", w.Body.String())
+	}
+	repo.waitCancelled(t)
+}
+
+func TestCancelledRequestSkipsStore(t *testing.T) {
+	repo := newSlowRepository(0)
+	todos := NewTodoController(repo, nil)
+	r := gin.New()
+	r.GET("/todos", todos.GetAllTodosHandler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/todos", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestTimeout {
+		t.Fatalf("GET /todos after the client went away: got %d %s, want 408", w.Code, w.Body.String())
+	}
+	if len(repo.calls) != 0 {
+		t.Error("GET /todos after the client went away still called the store")
+	}
+}
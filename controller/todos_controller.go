@@ -1,16 +1,97 @@
 package controller
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/CharlesPatterson/todos-app/middleware"
 	"github.com/CharlesPatterson/todos-app/model"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+const (
+	defaultTodoLimit = 100
+	maxTodoLimit     = 1000
+)
+
+// TodoCursorPage is a page of a listing paginated with after. NextCursor is
+// the after to request the next page with, empty on the last page.
+type TodoCursorPage struct {
+	Todos      interface{} `json:"todos" swaggertype:"array,object"`
+	NextCursor string      `json:"next_cursor" example:"66b1f0c2e4b0a1a2b3c4d5e6"`
+}
+
+// TodoController serves the todo endpoints from a TodoRepository, so they
+// can be exercised against model.MemoryRepository without MongoDB.
+type TodoController struct {
+	todos model.TodoRepository
+	undo  *model.UndoStore
+}
+
+// NewTodoController returns the handlers for the todos in todos. Deleted
+// todos are saved to undo to be restored by UndoHandler, unless it is nil.
+func NewTodoController(todos model.TodoRepository, undo *model.UndoStore) *TodoController {
+	return &TodoController{todos: todos, undo: undo}
+}
+
+// requestContext returns the context of the underlying HTTP request, which is
+// cancelled when the client disconnects or the request times out. The second
+// return value is false, and the request aborted, if that already happened.
+func requestContext(c *gin.Context) (context.Context, bool) {
+	ctx := c.Request.Context()
+	if err := ctx.Err(); err != nil {
+		c.AbortWithStatusJSON(http.StatusRequestTimeout, ErrorResponse{Error: err.Error()})
+		return ctx, false
+	}
+
+	return ctx, true
+}
+
+// readContext is requestContext for the listings, search, stats and
+// export, widened to todos in the trash by ?include_deleted=true. Only the
+// admin may include deleted todos; anyone else is refused with 403.
+func readContext(c *gin.Context) (context.Context, bool) {
+	ctx, ok := requestContext(c)
+	if !ok || c.Query("include_deleted") != "true" {
+		return ctx, ok
+	}
+	if !middleware.IsAdmin(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{Error: "include_deleted is restricted to admins"})
+		return ctx, false
+	}
+	return model.IncludingDeleted(ctx), true
+}
+
+// queryCompleted reads the completed query parameter, nil when it is absent.
+// Unlike the other boolean parameters only true and false are accepted, as
+// clients filter on it and a typo silently listing everything would go
+// unnoticed. The second return value is false, and the request aborted, for
+// any other value.
+func queryCompleted(c *gin.Context) (*bool, bool) {
+	var completed bool
+	switch c.Query("completed") {
+	case "":
+		return nil, true
+	case "true":
+		completed = true
+	case "false":
+		completed = false
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "completed must be true or false"})
+		return nil, false
+	}
+	return &completed, true
+}
+
 func GetRootRedirectHandler(c *gin.Context) {
 	c.Redirect(http.StatusMovedPermanently, "/swagger/index.html")
 }
@@ -23,16 +104,31 @@ func GetRootRedirectHandler(c *gin.Context) {
 // @Param		Authorization	header	string	false	"Authorization"
 // @Security	JWT
 // @Success	200	{object}	model.Todo
+// @Header		200	{string}	ETag	"Version of the todo, for If-Match on updates"
+// @Failure	401	{object}	controller.UnauthorizedResponse
+// @Failure	404	{object}	controller.ErrorResponse
+// @Failure	408	{object}	controller.ErrorResponse
+// @Failure	500	{object}	controller.ErrorResponse
+// @Failure	504	{object}	controller.ErrorResponse
 // @Router		/todos/{id} [get]
-func GetTodoByIdHandler(c *gin.Context) {
+func (h *TodoController) GetTodoByIdHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
 	id := c.Param("id")
 
-	todo, err := model.GetTodoById(c, id)
+	todo, err := h.todos.GetByID(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	c.Header("ETag", todo.ETag())
 	c.JSON(http.StatusOK, todo)
 }
 
@@ -42,11 +138,20 @@ func GetTodoByIdHandler(c *gin.Context) {
 // @Produce	json
 // @Param		id				path	string				true	"Todo ID"
 // @Param		data			body	model.TodoDocInput	true	"Todo data"
+// @Param		If-Match		header	string				false	"ETag the todo was read with; the update is refused if it has changed since"
 // @Param		Authorization	header	string				false	"Authorization"
 // @Security	JWT
 // @Success	204	{object}	model.Todo
+// @Failure	400	{object}	controller.ValidationErrorResponse
+// @Failure	401	{object}	controller.UnauthorizedResponse
+// @Failure	404	{object}	controller.ErrorResponse
+// @Failure	408	{object}	controller.ErrorResponse
+// @Failure	409	{object}	controller.BlockedResponse
+// @Failure	412	{object}	controller.ErrorResponse
+// @Failure	500	{object}	controller.ErrorResponse
+// @Failure	504	{object}	controller.ErrorResponse
 // @Router		/todos/{id} [put]
-func UpdateTodoByIdHandler(c *gin.Context) {
+func (h *TodoController) UpdateTodoByIdHandler(c *gin.Context) {
 	id := c.Param("id")
 
 	var todo model.Todo
@@ -58,47 +163,105 @@ func UpdateTodoByIdHandler(c *gin.Context) {
 			for i, fe := range ve {
 				out[i] = ErrorMsg{fe.Field(), getErrorMsg(fe)}
 			}
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": out})
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{Errors: out})
 		}
 		return
 	}
 
-	err := model.UpdateTodo(c, &todo, id)
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+	if etag := c.GetHeader("If-Match"); etag != "" && etag != "*" {
+		version, err := model.ParseETag(etag)
+		if err != nil {
+			c.JSON(http.StatusPreconditionFailed, ErrorResponse{Error: err.Error()})
+			return
+		}
+		ctx = model.WithVersion(ctx, version)
+	}
+
+	err := h.todos.Update(ctx, id, &todo)
+	if abortOnInvalidRecurrence(c, err) || abortOnDependencyError(c, err) {
+		return
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if errors.Is(err, model.ErrVersionMismatch) {
+		c.JSON(http.StatusPreconditionFailed, ErrorResponse{Error: err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusNoContent, "")
 }
 
+// abortOnInvalidRecurrence responds with a validation error on the
+// recurrence field, and returns true, if err is an invalid recurrence.
+func abortOnInvalidRecurrence(c *gin.Context, err error) bool {
+	var re *model.InvalidRecurrenceError
+	if !errors.As(err, &re) {
+		return false
+	}
+
+	c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{
+		Errors: []ErrorMsg{{Field: "Recurrence", Message: re.Error()}},
+	})
+	return true
+}
+
 type ErrorMsg struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
+	Field   string `json:"field" example:"text"`
+	Message string `json:"message" example:"This field is required"`
 }
 
 func getErrorMsg(fe validator.FieldError) string {
 	switch fe.Tag() {
 	case "required":
 		return "This field is required"
+	case "required_without":
+		return "This field is required unless " + fe.Param() + " is given"
+	case "excluded_with":
+		return "Should not be given together with " + fe.Param()
 	case "lte":
 		return "Should be less than " + fe.Param()
+	case "gt":
+		return "Should be greater than " + fe.Param()
 	case "gte":
 		return "Should be greater than " + fe.Param()
+	case "max":
+		if fe.Kind() == reflect.Slice {
+			return "Should have at most " + fe.Param() + " entries"
+		}
+		return "Should be at most " + fe.Param() + " characters long"
+	case "todocolor":
+		return "Should be one of " + strings.Join(model.PaletteNames, ", ") + " or a #RRGGBB hex color"
 	}
 	return "Unknown error"
 }
 
-// @Summary	Create a todo
-// @ID			create-todo
-// @Tags		Todos
-// @Produce	json
-// @Param		data			body	model.TodoDocInput	true	"Todo data"
-// @Param		Authorization	header	string				false	"Authorization"
-// @Security	JWT
-// @Success	200	{object}	model.Todo
-// @Router		/todos [post]
-func CreateTodoHandler(c *gin.Context) {
+// @Summary		Create a todo
+// @ID				create-todo
+// @Tags			Todos
+// @Description	Create a todo, optionally as a subtask of parent_id, which must exist. The project, tags and due date left out of the request are taken from the user's defaults, see /me/defaults. With REJECT_DUPLICATE_TODOS set, a todo duplicating the text of a pending one is refused with 409.
+// @Produce		json
+// @Param			data			body	model.TodoDocInput	true	"Todo data"
+// @Param			Authorization	header	string				false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		400	{object}	controller.ValidationErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		409	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos [post]
+func (h *TodoController) CreateTodoHandler(c *gin.Context) {
 	var newTodo model.Todo
 
 	if err := c.BindJSON(&newTodo); err != nil {
@@ -109,17 +272,42 @@ func CreateTodoHandler(c *gin.Context) {
 			for i, fe := range ve {
 				out[i] = ErrorMsg{fe.Field(), getErrorMsg(fe)}
 			}
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": out})
+			c.AbortWithStatusJSON(http.StatusBadRequest, ValidationErrorResponse{Errors: out})
 		}
 		return
 	}
 
-	newTodo.CreatedAt = time.Now()
-	newTodo.UpdatedAt = time.Now()
+	newTodo.CreatedAt = model.Now()
+	newTodo.UpdatedAt = model.Now()
 	newTodo.ID = primitive.NewObjectID()
+	newTodo.Owner = middleware.CurrentUserName(c)
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
 
-	if err := model.CreateTodo(c, &newTodo); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"errors": err.Error()})
+	defaults, err := h.todos.Defaults(ctx)
+	if err != nil {
+		c.AbortWithStatusJSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+	defaults.Apply(&newTodo)
+
+	err = h.todos.Create(ctx, &newTodo)
+	if abortOnInvalidRecurrence(c, err) || abortOnDependencyError(c, err) {
+		return
+	}
+	if errors.Is(err, model.ErrParentNotFound) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, model.ErrDuplicateTodo) {
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
@@ -129,39 +317,383 @@ func CreateTodoHandler(c *gin.Context) {
 // @Summary		Get all todos
 // @ID				get-all-todos
 // @Tags			Todos
-// @Description	Get all todos without any filtering
+// @Description	Get a page of todos in their manual order with pinned todos first, or ordered by sort, optionally only those marked stale or overdue. The X-Total-Count response header holds the number of matching todos across all pages. Passing after, empty for the first page, switches to pagination by id instead: todos are then ordered by id and wrapped in a controller.TodoCursorPage whose next_cursor is the after of the next page. With view=slim, or any expand, only _id, text, completed and due_date are returned plus the expanded field groups. /api/v2/todos takes the same parameters but returns that slim representation by default, and full todos with view=full or expand=all.
 // @Produce		json
+// @Param			stale			query	bool	false	"Only return pending todos marked stale"
+// @Param			overdue			query	bool	false	"Only return pending todos past their due date"
+// @Param			completed		query	bool	false	"Only return completed, or only pending, todos"
+// @Param			created_after	query	string	false	"Only return todos created at or after this RFC3339 time"
+// @Param			created_before	query	string	false	"Only return todos created at or before this RFC3339 time"
+// @Param			updated_after	query	string	false	"Only return todos updated at or after this RFC3339 time"
+// @Param			tag				query	string	false	"Only return todos carrying this tag"
+// @Param			project			query	string	false	"Only return todos in this project"
+// @Param			include_archived	query	bool	false	"Also return archived todos"
+// @Param			include			query	string	false	"Comma-separated extras to include: last_note"
+// @Param			view			query	string	false	"Set to slim for the slim representation"	Enums(full, slim)
+// @Param			expand			query	string	false	"Comma-separated field groups to add to the slim representation: tags, project, metadata or all"
+// @Param			limit			query	int		false	"Maximum number of todos, defaults to 100"
+// @Param			offset			query	int		false	"Number of todos to skip"
+// @Param			after			query	string	false	"Id of the last todo of the previous page"
+// @Param			sort			query	string	false	"Field to sort by instead of the manual order, prefixed with - for descending order"	Enums(created_at, -created_at, updated_at, -updated_at, text, -text)
+// @Param			include_deleted	query	bool	false	"Also return todos in the trash, admins only"
 // @Param			Authorization	header	string	false	"Authorization"
 // @Security		JWT
 // @Success		200	{array}	model.Todo
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		403	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
 // @Router			/todos [get]
-func GetAllTodosHandler(c *gin.Context) {
-	todos, err := model.GetAll(c)
+func (h *TodoController) GetAllTodosHandler(c *gin.Context) {
+	h.listTodos(c, false)
+}
+
+// GetAllTodosV2Handler serves /api/v2/todos, which takes the parameters of
+// GetAllTodosHandler but returns the slim representation unless view=full
+// or expand=all asks for full documents.
+func (h *TodoController) GetAllTodosV2Handler(c *gin.Context) {
+	h.listTodos(c, true)
+}
+
+// listTodos serves a page of todos, in the slim representation when asked
+// for or when slimByDefault is set.
+func (h *TodoController) listTodos(c *gin.Context, slimByDefault bool) {
+	var query model.TodoQuery
+	if raw := c.Query("stale"); raw != "" {
+		stale, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "stale must be true or false"})
+			return
+		}
+		query.Stale = stale
+	}
+	if raw := c.Query("overdue"); raw != "" {
+		overdue, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "overdue must be true or false"})
+			return
+		}
+		query.Overdue = overdue
+	}
+	completed, ok := queryCompleted(c)
+	if !ok {
+		return
+	}
+	query.Completed = completed
+	for _, bound := range []struct {
+		param string
+		dest  **time.Time
+	}{
+		{"created_after", &query.CreatedAfter},
+		{"created_before", &query.CreatedBefore},
+		{"updated_after", &query.UpdatedAfter},
+	} {
+		raw := c.Query(bound.param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: bound.param + " must be an RFC3339 time such as 2006-01-02T15:04:05Z"})
+			return
+		}
+		*bound.dest = &t
+	}
+	query.Tag = c.Query("tag")
+	query.Project = c.Query("project")
+	if raw := c.Query("include_archived"); raw != "" {
+		includeArchived, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "include_archived must be true or false"})
+			return
+		}
+		query.IncludeArchived = includeArchived
+	}
+
+	limit, err := queryInt(c, "limit", defaultTodoLimit)
+	if err != nil || limit < 1 || limit > maxTodoLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "limit must be between 1 and " + strconv.Itoa(maxTodoLimit)})
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "offset must not be negative"})
+		return
+	}
+	query.Limit = limit
+	query.Offset = offset
+	if key := c.Query("sort"); key != "" {
+		if _, err := model.ParseSort(key); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		query.Sort = key
+	}
+	rawAfter, byCursor := c.GetQuery("after")
+	if byCursor {
+		if offset != 0 || c.Query("sort") != "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "offset and sort cannot be combined with after"})
+			return
+		}
+		if rawAfter != "" {
+			query.After, err = primitive.ObjectIDFromHex(rawAfter)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "after must be a todo id"})
+				return
+			}
+		}
+		// One todo more than the page tells whether there is a next one.
+		query.ByCursor = true
+		query.Limit = limit + 1
+	}
+
+	// Slim listings are chosen by the path and query string rather than a
+	// header, so the URI-keyed response cache keeps them apart.
+	view := c.Query("view")
+	if view != "" && view != "full" && view != "slim" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "view must be full or slim"})
+		return
+	}
+	if view == "slim" || c.Query("expand") != "" || (slimByDefault && view != "full") {
+		query.View, err = model.ParseListView(c.Query("expand"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	ctx, ok := readContext(c)
+	if !ok {
+		return
+	}
+
+	todos, total, err := h.todos.List(ctx, query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	var nextCursor string
+	if byCursor && len(todos) > limit {
+		todos = todos[:limit]
+		nextCursor = todos[limit-1].ID.Hex()
+	}
+
+	if slices.Contains(strings.Split(c.Query("include"), ","), "last_note") {
+		ids := make([]primitive.ObjectID, len(todos))
+		for i, todo := range todos {
+			ids[i] = todo.ID
+		}
 
-	c.JSON(http.StatusOK, todos)
+		latest, err := h.todos.LatestNotes(ctx, ids)
+		if err != nil {
+			c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+			return
+		}
+		for _, todo := range todos {
+			todo.LastNote = latest[todo.ID]
+		}
+	}
+
+	var body interface{} = todos
+	if query.View != nil {
+		summaries := make([]*model.TodoSummary, len(todos))
+		for i, todo := range todos {
+			summaries[i] = query.View.Summarize(todo)
+		}
+		body = summaries
+	}
+	if byCursor {
+		body = TodoCursorPage{Todos: body, NextCursor: nextCursor}
+	}
+
+	c.JSON(http.StatusOK, body)
 }
 
-// @Summary	Delete a todo
-// @ID			delete-todo-by-id
+// @Summary		Delete a todo
+// @ID				delete-todo-by-id
+// @Tags			Todos
+// @Description	Delete a todo. The X-Undo-Token response header can be posted to /undo to restore it within the undo window. Todos with subtasks cannot be deleted until their subtasks are.
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		204	{object}	model.Todo
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		409	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}  [delete]
+func (h *TodoController) DeleteTodoByIdHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+
+	todo, err := h.todos.Delete(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "todo not found"})
+		return
+	}
+	if errors.Is(err, model.ErrHasChildren) {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if h.undo != nil {
+		token, err := h.undo.Save(ctx, &model.UndoSnapshot{Todos: []*model.Todo{todo}})
+		if err != nil {
+			_ = c.Error(err)
+		} else {
+			c.Header("X-Undo-Token", token)
+		}
+	}
+
+	c.JSON(http.StatusNoContent, "")
+}
+
+// @Summary	List the subtasks of a todo
+// @ID			get-todo-children
 // @Tags		Todos
 // @Produce	json
 // @Param		id				path	string	true	"Todo ID"
 // @Param		Authorization	header	string	false	"Authorization"
 // @Security	JWT
-// @Success	204	{object}	model.Todo
-// @Router		/todos/{id}  [delete]
-func DeleteTodoByIdHandler(c *gin.Context) {
-	id := c.Param("id")
+// @Success	200	{array}		model.Todo
+// @Failure	401	{object}	controller.UnauthorizedResponse
+// @Failure	408	{object}	controller.ErrorResponse
+// @Failure	500	{object}	controller.ErrorResponse
+// @Failure	504	{object}	controller.ErrorResponse
+// @Router		/todos/{id}/children [get]
+func (h *TodoController) GetTodoChildrenHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
 
-	err := model.DeleteTodoById(c, id)
+	children, err := h.todos.Children(ctx, c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusNoContent, "")
+	c.JSON(http.StatusOK, children)
+}
+
+type UndoInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// @Summary		Undo a deletion or bulk completion
+// @ID				undo
+// @Tags			Todos
+// @Description	Restore the todos of an operation using the X-Undo-Token it returned: a deleted todo comes back as it was, and the todos a bulk completion completed are pending again, returned as an array, with the next occurrences it created for recurring todos moved to the trash. Tokens are single-use, only work for the user who got them and expire after the undo window.
+// @Produce		json
+// @Param			data			body	controller.UndoInput	true	"Undo token"
+// @Param			Authorization	header	string					false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/undo [post]
+func (h *TodoController) UndoHandler(c *gin.Context) {
+	var input UndoInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	if h.undo == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: model.ErrUndoTokenNotFound.Error()})
+		return
+	}
+	snapshot, err := h.undo.Take(ctx, input.Token)
+	if errors.Is(err, model.ErrUndoTokenNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	for _, todo := range snapshot.Todos {
+		if err := h.todos.Replace(ctx, todo); err != nil {
+			c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	for _, id := range snapshot.Spawned {
+		_, err := h.todos.Delete(ctx, id.Hex())
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	if snapshot.Bulk {
+		c.JSON(http.StatusOK, snapshot.Todos)
+		return
+	}
+	c.JSON(http.StatusOK, snapshot.Todos[0])
+}
+
+const defaultDuplicateThreshold = 0.85
+
+// @Summary		Find near-duplicate todos
+// @ID				get-duplicate-todos
+// @Tags			Todos
+// @Description	Group pending todos whose normalized texts are highly similar
+// @Produce		json
+// @Param			threshold		query	number	false	"Minimum similarity between 0 and 1, defaults to 0.85"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{array}	model.DuplicateCluster
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/duplicates [get]
+func (h *TodoController) GetDuplicateTodosHandler(c *gin.Context) {
+	threshold := defaultDuplicateThreshold
+	if raw := c.Query("threshold"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "threshold must be a number greater than 0 and at most 1"})
+			return
+		}
+		threshold = parsed
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	clusters, err := h.todos.Duplicates(ctx, threshold)
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, clusters)
 }
@@ -0,0 +1,1137 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/middleware"
+	"github.com/CharlesPatterson/todos-app/model"
+	cache "github.com/chenyahui/gin-cache"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	if err := RegisterValidators(); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestRouter serves the todo endpoints from an empty in-memory
+// repository.
+func newTestRouter() *gin.Engine {
+	todos := NewTodoController(model.NewMemoryRepository(), nil)
+	r := gin.New()
+	r.GET("/todos", todos.GetAllTodosHandler)
+	r.POST("/todos", todos.CreateTodoHandler)
+	r.POST("/todos/bulk", todos.CreateTodosBulkHandler)
+	r.GET("/todos/:id", todos.GetTodoByIdHandler)
+	r.PUT("/todos/:id", todos.UpdateTodoByIdHandler)
+	r.DELETE("/todos/:id", todos.DeleteTodoByIdHandler)
+	r.DELETE("/todos/trash/:id", todos.PurgeTodoHandler)
+	r.POST("/todos/:id/reminders", todos.AddReminderHandler)
+	r.DELETE("/todos/:id/reminders", todos.RemoveReminderHandler)
+	return r
+}
+
+func serve(t *testing.T, r *gin.Engine, method string, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, out interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+}
+
+func createTodo(t *testing.T, r *gin.Engine, todo map[string]interface{}) *model.Todo {
+	t.Helper()
+
+	w := serve(t, r, http.MethodPost, "/todos", todo)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating %v: got %d %s", todo, w.Code, w.Body.String())
+	}
+	created := &model.Todo{}
+	decode(t, w, created)
+	return created
+}
+
+func TestCreateAndGetTodo(t *testing.T) {
+	r := newTestRouter()
+	created := createTodo(t, r, map[string]interface{}{"text": "  buy   milk ", "tags": []string{"Errands"}})
+	if created.Text != "buy milk" {
+		t.Errorf("text = %q, want it normalized to %q", created.Text, "buy milk")
+	}
+
+	w := serve(t, r, http.MethodGet, "/todos/"+created.ID.Hex(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	got := &model.Todo{}
+	decode(t, w, got)
+	if got.ID != created.ID || len(got.Tags) != 1 || got.Tags[0] != "errands" {
+		t.Errorf("got %+v, want %+v", got, created)
+	}
+}
+
+func TestCreateTodosBulk(t *testing.T) {
+	t.Setenv("REJECT_DUPLICATE_TODOS", "true")
+	r := newTestRouter()
+
+	w := serve(t, r, http.MethodPost, "/todos/bulk", []map[string]interface{}{
+		{"text": "water plants", "reminded_at": "2024-01-01T00:00:00Z", "spent_minutes": 90, "pinned": true},
+		{"text": " Water  plants"},
+		{"text": "call mum", "parent_id": "nope"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	var res BulkCreateResponse
+	decode(t, w, &res)
+
+	statuses := make([]int, len(res.Results))
+	for i, result := range res.Results {
+		statuses[i] = result.Status
+	}
+	want := []int{http.StatusCreated, http.StatusConflict, http.StatusBadRequest}
+	if !slices.Equal(statuses, want) {
+		t.Fatalf("statuses = %v, want %v: %s", statuses, want, w.Body.String())
+	}
+
+	created := res.Results[0].Todo
+	if created.RemindedAt != nil || created.SpentMinutes != 0 || created.Pinned {
+		t.Errorf("created %+v, want the fields the server maintains left unset", created)
+	}
+}
+
+func TestCreateTodoDefaults(t *testing.T) {
+	t.Cleanup(func() { model.Now = time.Now })
+	now := time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC)
+	model.FreezeClock(now)
+
+	todos := NewTodoController(model.NewMemoryRepository(), nil)
+	r := gin.New()
+	r.Use(asOwner("alice"))
+	r.POST("/todos", todos.CreateTodoHandler)
+	r.GET("/me/defaults", todos.GetDefaultsHandler)
+	r.PUT("/me/defaults", todos.SetDefaultsHandler)
+	r.PUT("/me/defaults/projects/:project", todos.SetProjectDefaultsHandler)
+	r.DELETE("/me/defaults/projects/:project", todos.DeleteProjectDefaultsHandler)
+
+	days := func(n int) *time.Time {
+		due := now.AddDate(0, 0, n)
+		return &due
+	}
+	check := func(name string, body map[string]interface{}, project string, tags []string, due *time.Time) {
+		t.Helper()
+		todo := createTodo(t, r, body)
+		if todo.Project != project || !slices.Equal(todo.Tags, tags) ||
+			(due == nil) != (todo.DueDate == nil) || (due != nil && !due.Equal(*todo.DueDate)) {
+			t.Errorf("%s: got project %q, tags %v and due date %v, want %q, %v and %v", name, todo.Project, todo.Tags, todo.DueDate, project, tags, due)
+		}
+	}
+
+	check("hardcoded", map[string]interface{}{"text": "one"}, "", nil, nil)
+
+	if w := serve(t, r, http.MethodPut, "/me/defaults", map[string]interface{}{"tags": []string{"Inbox"}, "due_in_days": 7, "project": "Home"}); w.Code != http.StatusOK {
+		t.Fatalf("setting defaults: got %d %s", w.Code, w.Body.String())
+	}
+	check("user default", map[string]interface{}{"text": "two"}, "home", []string{"inbox"}, days(7))
+	check("request", map[string]interface{}{"text": "three", "project": "errands", "tags": []string{"shop"}, "due_date": now.AddDate(0, 0, 1)}, "errands", []string{"shop"}, days(1))
+
+	if w := serve(t, r, http.MethodPut, "/me/defaults/projects/work", map[string]interface{}{"tags": []string{"office"}, "due_in_days": 2}); w.Code != http.StatusOK {
+		t.Fatalf("setting project defaults: got %d %s", w.Code, w.Body.String())
+	}
+	check("project default", map[string]interface{}{"text": "four", "project": "Work"}, "work", []string{"office"}, days(2))
+	check("request over project default", map[string]interface{}{"text": "five", "project": "work", "tags": []string{}}, "work", nil, days(2))
+
+	// Overrides of the default project apply too, and fields they leave
+	// out fall back to the user's defaults.
+	serve(t, r, http.MethodPut, "/me/defaults/projects/home", map[string]interface{}{"tags": []string{}})
+	check("project default of the user's project", map[string]interface{}{"text": "six"}, "home", nil, days(7))
+
+	if w := serve(t, r, http.MethodDelete, "/me/defaults/projects/work", nil); w.Code != http.StatusNoContent {
+		t.Errorf("removing project defaults: got %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(t, r, http.MethodDelete, "/me/defaults/projects/work", nil); w.Code != http.StatusNotFound {
+		t.Errorf("removing missing project defaults: got %d, want 404", w.Code)
+	}
+	check("user default after removing the project's", map[string]interface{}{"text": "seven", "project": "work"}, "work", []string{"inbox"}, days(7))
+
+	var defaults model.Defaults
+	decode(t, serve(t, r, http.MethodGet, "/me/defaults", nil), &defaults)
+	if defaults.Project != "home" || len(defaults.Projects) != 1 || defaults.Projects[0].Project != "home" {
+		t.Errorf("got defaults %+v", defaults)
+	}
+
+	if w := serve(t, r, http.MethodPut, "/me/defaults", map[string]interface{}{"due_in_days": -1}); w.Code != http.StatusBadRequest {
+		t.Errorf("a negative due date offset: got %d, want 400", w.Code)
+	}
+}
+
+func TestCreateTodoValidation(t *testing.T) {
+	r := newTestRouter()
+
+	w := serve(t, r, http.MethodPost, "/todos", map[string]interface{}{"text": "paint", "color": "ultraviolet"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d %s, want 400", w.Code, w.Body.String())
+	}
+	var res ValidationErrorResponse
+	decode(t, w, &res)
+	if len(res.Errors) != 1 || res.Errors[0].Field != "Color" {
+		t.Errorf("errors = %+v, want one for Color", res.Errors)
+	}
+}
+
+func TestGetMissingTodo(t *testing.T) {
+	r := newTestRouter()
+
+	w := serve(t, r, http.MethodGet, "/todos/66b1f0c2e4b0a1a2b3c4d5e6", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got %d %s, want 404", w.Code, w.Body.String())
+	}
+}
+
+func TestListTodos(t *testing.T) {
+	r := newTestRouter()
+	for _, text := range []string{"one", "two", "three"} {
+		createTodo(t, r, map[string]interface{}{"text": text})
+	}
+	createTodo(t, r, map[string]interface{}{"text": "four", "completed": true})
+
+	w := serve(t, r, http.MethodGet, "/todos?completed=false&limit=2", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	if total := w.Header().Get("X-Total-Count"); total != "3" {
+		t.Errorf("X-Total-Count = %q, want 3", total)
+	}
+	var todos []*model.Todo
+	decode(t, w, &todos)
+	if len(todos) != 2 || todos[0].Text != "one" || todos[1].Text != "two" {
+		t.Errorf("got %d todos, want one and two in creation order", len(todos))
+	}
+
+	w = serve(t, r, http.MethodGet, "/todos?completed=maybe", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("completed=maybe: got %d, want 400", w.Code)
+	}
+	w = serve(t, r, http.MethodGet, "/todos?sort=priority", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("sort=priority: got %d, want 400", w.Code)
+	}
+}
+
+func TestListTodosByCursor(t *testing.T) {
+	r := newTestRouter()
+	for _, text := range []string{"one", "two", "three"} {
+		createTodo(t, r, map[string]interface{}{"text": text})
+	}
+
+	var texts []string
+	cursor := ""
+	for range 3 {
+		w := serve(t, r, http.MethodGet, "/todos?limit=2&after="+cursor, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d %s", w.Code, w.Body.String())
+		}
+		var page struct {
+			Todos      []*model.Todo `json:"todos"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		decode(t, w, &page)
+		for _, todo := range page.Todos {
+			texts = append(texts, todo.Text)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(texts) != 3 {
+		t.Errorf("paged through %v, want all three todos once", texts)
+	}
+}
+
+func TestListTodosSortedByPage(t *testing.T) {
+	r := newTestRouter()
+	for _, text := range []string{"delta", "alpha", "echo", "charlie", "bravo"} {
+		createTodo(t, r, map[string]interface{}{"text": text})
+	}
+
+	for _, tc := range []struct {
+		sort string
+		want []string
+	}{
+		{"text", []string{"alpha", "bravo", "charlie", "delta", "echo"}},
+		{"-text", []string{"echo", "delta", "charlie", "bravo", "alpha"}},
+	} {
+		var texts []string
+		for offset := 0; offset < 6; offset += 2 {
+			path := fmt.Sprintf("/todos?sort=%s&limit=2&offset=%d", tc.sort, offset)
+			w := serve(t, r, http.MethodGet, path, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s: got %d %s", path, w.Code, w.Body.String())
+			}
+			if total := w.Header().Get("X-Total-Count"); total != "5" {
+				t.Errorf("GET %s: X-Total-Count = %q, want 5", path, total)
+			}
+			var todos []*model.Todo
+			decode(t, w, &todos)
+			for _, todo := range todos {
+				texts = append(texts, todo.Text)
+			}
+		}
+		if !slices.Equal(texts, tc.want) {
+			t.Errorf("sort=%s: paged through %v, want %v", tc.sort, texts, tc.want)
+		}
+	}
+}
+
+func TestSlimListPayload(t *testing.T) {
+	todos := NewTodoController(model.NewMemoryRepository(), nil)
+	r := gin.New()
+	r.POST("/todos", todos.CreateTodoHandler)
+	r.GET("/v1/todos", todos.GetAllTodosHandler)
+	r.GET("/v2/todos", todos.GetAllTodosV2Handler)
+
+	due := time.Date(2026, time.March, 1, 17, 0, 0, 0, time.UTC)
+	for i := range 50 {
+		createTodo(t, r, map[string]interface{}{
+			"text":             fmt.Sprintf("review quarterly report section %d", i),
+			"due_date":         due,
+			"tags":             []string{"work", "finance", "q1"},
+			"project":          "reporting",
+			"color":            "blue",
+			"estimate_minutes": 45,
+			"recurrence":       "every 3 months",
+		})
+	}
+
+	size := func(path string) int {
+		t.Helper()
+		w := serve(t, r, http.MethodGet, path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: got %d %s", path, w.Code, w.Body.String())
+		}
+		return w.Body.Len()
+	}
+	v1 := size("/v1/todos")
+	slim := size("/v2/todos")
+	t.Logf("50 todos: %d bytes full, %d bytes slim, %.0f%% smaller", v1, slim, 100*(1-float64(slim)/float64(v1)))
+
+	if slim*2 > v1 {
+		t.Errorf("slim listing is %d bytes, want at most half of the %d byte full listing", slim, v1)
+	}
+	if full := size("/v2/todos?view=full"); full != v1 {
+		t.Errorf("v2 with view=full is %d bytes, want the %d bytes of v1", full, v1)
+	}
+	if expanded := size("/v2/todos?expand=all"); expanded != v1 {
+		t.Errorf("v2 with expand=all is %d bytes, want the %d bytes of v1", expanded, v1)
+	}
+	if optIn := size("/v1/todos?view=slim"); optIn != slim {
+		t.Errorf("v1 with view=slim is %d bytes, want the %d bytes of v2", optIn, slim)
+	}
+
+	w := serve(t, r, http.MethodGet, "/v2/todos?view=compact", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("view=compact: got %d, want 400", w.Code)
+	}
+}
+
+func TestUpdateTodo(t *testing.T) {
+	r := newTestRouter()
+	created := createTodo(t, r, map[string]interface{}{"text": "draft"})
+
+	w := serve(t, r, http.MethodPut, "/todos/"+created.ID.Hex(), map[string]interface{}{"text": "final", "project": "Work"})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+
+	got := &model.Todo{}
+	decode(t, serve(t, r, http.MethodGet, "/todos/"+created.ID.Hex(), nil), got)
+	if got.Text != "final" || got.Project != "work" {
+		t.Errorf("got text %q project %q, want final and work", got.Text, got.Project)
+	}
+}
+
+func TestDeleteTodo(t *testing.T) {
+	r := newTestRouter()
+	parent := createTodo(t, r, map[string]interface{}{"text": "parent"})
+	child := createTodo(t, r, map[string]interface{}{"text": "child", "parent_id": parent.ID})
+
+	w := serve(t, r, http.MethodDelete, "/todos/"+parent.ID.Hex(), nil)
+	if w.Code != http.StatusConflict {
+		t.Errorf("deleting a todo with subtasks: got %d, want 409", w.Code)
+	}
+
+	for _, todo := range []*model.Todo{child, parent} {
+		w = serve(t, r, http.MethodDelete, "/todos/"+todo.ID.Hex(), nil)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("deleting %q: got %d %s", todo.Text, w.Code, w.Body.String())
+		}
+	}
+	w = serve(t, r, http.MethodGet, "/todos/"+parent.ID.Hex(), nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("getting a deleted todo: got %d, want 404", w.Code)
+	}
+	w = serve(t, r, http.MethodPut, "/todos/"+parent.ID.Hex(), map[string]interface{}{"text": "parent", "completed": true})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("updating a deleted todo: got %d, want 404", w.Code)
+	}
+}
+
+func TestUpdateTodoIfMatch(t *testing.T) {
+	t.Cleanup(func() { model.Now = time.Now })
+	model.FreezeClock(time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC))
+	r := newTestRouter()
+	todo := createTodo(t, r, map[string]interface{}{"text": "file taxes"})
+
+	w := serve(t, r, http.MethodGet, "/todos/"+todo.ID.Hex(), nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("got no ETag")
+	}
+
+	update := func(etag string, text string) int {
+		model.FreezeClock(model.Now().Add(time.Second))
+		req := httptest.NewRequest(http.MethodPut, "/todos/"+todo.ID.Hex(), bytes.NewBufferString(`{"text": "`+text+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", etag)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := update(etag, "file taxes early"); code != http.StatusNoContent {
+		t.Fatalf("updating with the current ETag: got %d, want 204", code)
+	}
+	if code := update(etag, "file taxes late"); code != http.StatusPreconditionFailed {
+		t.Errorf("updating with a stale ETag: got %d, want 412", code)
+	}
+	if code := update("*", "file taxes today"); code != http.StatusNoContent {
+		t.Errorf("updating with If-Match *: got %d, want 204", code)
+	}
+}
+
+func TestPurgeTodo(t *testing.T) {
+	r := newTestRouter()
+	todo := createTodo(t, r, map[string]interface{}{"text": "shred letters"})
+
+	if w := serve(t, r, http.MethodDelete, "/todos/trash/"+todo.ID.Hex(), nil); w.Code != http.StatusNotFound {
+		t.Errorf("purging a todo outside the trash: got %d, want 404", w.Code)
+	}
+	serve(t, r, http.MethodDelete, "/todos/"+todo.ID.Hex(), nil)
+	if w := serve(t, r, http.MethodDelete, "/todos/trash/"+todo.ID.Hex(), nil); w.Code != http.StatusNoContent {
+		t.Fatalf("purging a deleted todo: got %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(t, r, http.MethodDelete, "/todos/trash/"+todo.ID.Hex(), nil); w.Code != http.StatusNotFound {
+		t.Errorf("purging a purged todo: got %d, want 404", w.Code)
+	}
+}
+
+func TestReadsExcludeDeletedTodos(t *testing.T) {
+	repo := model.NewMemoryRepository()
+	todos := NewTodoController(repo, nil)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("id", &middleware.User{UserName: c.GetHeader("X-User")})
+		c.Next()
+	})
+	r.POST("/todos", todos.CreateTodoHandler)
+	r.DELETE("/todos/:id", todos.DeleteTodoByIdHandler)
+	r.GET("/todos", todos.GetAllTodosHandler)
+	r.GET("/todos/search", todos.SearchTodosHandler)
+	r.GET("/todos/stats", todos.GetStatsHandler)
+	r.GET("/todos/export", todos.ExportTodosHandler)
+
+	createTodo(t, r, map[string]interface{}{"text": "water the ferns"})
+	deleted := createTodo(t, r, map[string]interface{}{"text": "water the cactus"})
+	serve(t, r, http.MethodDelete, "/todos/"+deleted.ID.Hex(), nil)
+
+	read := func(user string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	// counted returns how many todos a read path returned.
+	counted := func(path string, w *httptest.ResponseRecorder) int {
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %s", path, w.Code, w.Body.String())
+		}
+		endpoint, _, _ := strings.Cut(path, "?")
+		switch endpoint {
+		case "/todos/stats":
+			var stats model.TodoStats
+			decode(t, w, &stats)
+			return int(stats.Total)
+		case "/todos/export":
+			return bytes.Count(w.Body.Bytes(), []byte("\n"))
+		}
+		var list []*model.Todo
+		decode(t, w, &list)
+		return len(list)
+	}
+
+	for _, path := range []string{"/todos", "/todos/search?q=water", "/todos/stats", "/todos/export"} {
+		if n := counted(path, read("alice", path)); n != 1 {
+			t.Errorf("%s: got %d todos, want the deleted one left out", path, n)
+		}
+		if n := counted(path, read("admin", path)); n != 1 {
+			t.Errorf("%s as admin: got %d todos, want the deleted one left out", path, n)
+		}
+
+		included := path + "?include_deleted=true"
+		if strings.Contains(path, "?") {
+			included = path + "&include_deleted=true"
+		}
+		if w := read("alice", included); w.Code != http.StatusForbidden {
+			t.Errorf("%s: got %d, want 403 for a user other than admin", included, w.Code)
+		}
+		if n := counted(included, read("admin", included)); n != 2 {
+			t.Errorf("%s as admin: got %d todos, want the deleted one too", included, n)
+		}
+	}
+}
+
+func TestReminders(t *testing.T) {
+	r := newTestRouter()
+	todo := createTodo(t, r, map[string]interface{}{"text": "call the bank"})
+	path := "/todos/" + todo.ID.Hex() + "/reminders"
+	later := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	if w := serve(t, r, http.MethodPost, path, map[string]interface{}{"at": time.Now().Add(-time.Minute)}); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("adding a reminder in the past: got %d, want 422", w.Code)
+	}
+	for i := range model.MaxReminders {
+		w := serve(t, r, http.MethodPost, path, map[string]interface{}{"at": later.Add(time.Duration(i) * time.Minute)})
+		if w.Code != http.StatusOK {
+			t.Fatalf("adding reminder %d: got %d %s", i, w.Code, w.Body.String())
+		}
+	}
+	if w := serve(t, r, http.MethodPost, path, map[string]interface{}{"at": later.Add(-time.Minute)}); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("adding a reminder over the cap: got %d, want 422", w.Code)
+	}
+
+	var got model.Todo
+	decode(t, serve(t, r, http.MethodDelete, path+"?at="+later.Format(time.RFC3339), nil), &got)
+	if len(got.Reminders) != model.MaxReminders-1 || got.NextReminderAt == nil || !got.NextReminderAt.Equal(later.Add(time.Minute)) {
+		t.Fatalf("after removing the first reminder got %v next at %v", got.Reminders, got.NextReminderAt)
+	}
+	if w := serve(t, r, http.MethodDelete, path+"?at="+later.Format(time.RFC3339), nil); w.Code != http.StatusNotFound {
+		t.Errorf("removing a removed reminder: got %d, want 404", w.Code)
+	}
+
+	if w := serve(t, r, http.MethodPut, "/todos/"+todo.ID.Hex(), map[string]interface{}{"text": "call the bank", "completed": true}); w.Code != http.StatusNoContent {
+		t.Fatalf("completing: got %d %s", w.Code, w.Body.String())
+	}
+	var completed model.Todo
+	decode(t, serve(t, r, http.MethodGet, "/todos/"+todo.ID.Hex(), nil), &completed)
+	if len(completed.Reminders) != 0 || completed.NextReminderAt != nil {
+		t.Errorf("completing kept reminders %v", completed.Reminders)
+	}
+	if w := serve(t, r, http.MethodPost, path, map[string]interface{}{"at": later}); w.Code != http.StatusConflict {
+		t.Errorf("adding a reminder to a completed todo: got %d, want 409", w.Code)
+	}
+}
+
+func TestDeleteParentTodo(t *testing.T) {
+	repo := model.NewMemoryRepository()
+	ctx := context.Background()
+	parent := &model.Todo{ID: primitive.NewObjectID(), Text: "plan trip"}
+	child := &model.Todo{ID: primitive.NewObjectID(), Text: "book flights", ParentID: &parent.ID}
+	for _, todo := range []*model.Todo{parent, child} {
+		if err := repo.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := repo.Delete(ctx, parent.ID.Hex()); !errors.Is(err, model.ErrHasChildren) {
+		t.Fatalf("deleting a parent from the repository: got %v, want ErrHasChildren", err)
+	}
+
+	todos := NewTodoController(repo, nil)
+	r := gin.New()
+	r.DELETE("/todos/:id", todos.DeleteTodoByIdHandler)
+	r.GET("/todos/:id", todos.GetTodoByIdHandler)
+
+	w := serve(t, r, http.MethodDelete, "/todos/"+parent.ID.Hex(), nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("deleting a parent: got %d %s, want 409", w.Code, w.Body.String())
+	}
+	var res ErrorResponse
+	decode(t, w, &res)
+	if res.Error != model.ErrHasChildren.Error() {
+		t.Errorf("got error %q, want %q", res.Error, model.ErrHasChildren)
+	}
+	if w = serve(t, r, http.MethodGet, "/todos/"+parent.ID.Hex(), nil); w.Code != http.StatusOK {
+		t.Errorf("getting the parent after a refused delete: got %d, want 200", w.Code)
+	}
+}
+
+func TestCompleteBlockedTodo(t *testing.T) {
+	r := newTestRouter()
+	blocker := createTodo(t, r, map[string]interface{}{"text": "order parts"})
+	blocked := createTodo(t, r, map[string]interface{}{"text": "assemble", "blocked_by": []string{blocker.ID.Hex()}})
+
+	complete := func(todo *model.Todo) *httptest.ResponseRecorder {
+		return serve(t, r, http.MethodPut, "/todos/"+todo.ID.Hex(), map[string]interface{}{
+			"text":       todo.Text,
+			"completed":  true,
+			"blocked_by": todo.BlockedBy,
+		})
+	}
+
+	w := complete(blocked)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("completing a blocked todo: got %d %s, want 409", w.Code, w.Body.String())
+	}
+
+	for _, todo := range []*model.Todo{blocker, blocked} {
+		w = complete(todo)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("completing %q: got %d %s", todo.Text, w.Code, w.Body.String())
+		}
+		w = serve(t, r, http.MethodGet, "/todos/"+todo.ID.Hex(), nil)
+		completed := &model.Todo{}
+		decode(t, w, completed)
+		if !completed.Completed || completed.CompletedAt == nil {
+			t.Errorf("%q is not completed after completing it", todo.Text)
+		}
+	}
+}
+
+// failingRepository fails the duplicate search of the repository it wraps
+// with err.
+type failingRepository struct {
+	model.TodoRepository
+	err error
+}
+
+func (r failingRepository) Duplicates(ctx context.Context, threshold float64) ([]*model.DuplicateCluster, error) {
+	return nil, r.err
+}
+
+// asOwner scopes every request to the todos of owner, as the JWT middleware
+// does for the logged-in user.
+func asOwner(owner string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(model.WithOwner(c.Request.Context(), owner))
+		c.Next()
+	}
+}
+
+func TestDeleteProject(t *testing.T) {
+	repo := model.NewMemoryRepository()
+	todos := NewTodoController(repo, nil)
+	r := gin.New()
+	r.POST("/todos", todos.CreateTodoHandler)
+	r.GET("/todos", todos.GetAllTodosHandler)
+	r.DELETE("/projects/:name", todos.DeleteProjectHandler)
+
+	listed := func(query string) int {
+		var list []*model.Todo
+		decode(t, serve(t, r, http.MethodGet, "/todos?"+query, nil), &list)
+		return len(list)
+	}
+
+	for _, strategy := range []string{"orphan", "archive", "delete"} {
+		project := "garden-" + strategy
+		parent := createTodo(t, r, map[string]interface{}{"text": "plant bulbs " + strategy, "project": project})
+		createTodo(t, r, map[string]interface{}{"text": "buy bulbs " + strategy, "project": project})
+		createTodo(t, r, map[string]interface{}{"text": "dig beds " + strategy, "parent_id": parent.ID})
+
+		w := serve(t, r, http.MethodDelete, "/projects/"+project, nil)
+		var conflict ProjectInUseResponse
+		decode(t, w, &conflict)
+		if w.Code != http.StatusConflict || conflict.Todos != 2 {
+			t.Fatalf("deleting %q without a strategy: got %d %s, want 409 with 2 todos", project, w.Code, w.Body.String())
+		}
+		if n := listed("project=" + project); n != 2 {
+			t.Fatalf("a refused deletion changed %q: %d todos left", project, n)
+		}
+
+		w = serve(t, r, http.MethodDelete, "/projects/"+project+"?strategy="+strategy, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("strategy=%s: got %d %s", strategy, w.Code, w.Body.String())
+		}
+		var deletion model.ProjectDeletion
+		decode(t, w, &deletion)
+
+		// The parent of a subtask outside the project is kept by delete.
+		want := model.ProjectDeletion{Project: project, Strategy: model.ProjectStrategy(strategy), Todos: 2, Changed: 2}
+		if strategy == "delete" {
+			want.Changed, want.Kept = 1, 1
+		}
+		if deletion != want {
+			t.Errorf("strategy=%s: got %+v, want %+v", strategy, deletion, want)
+		}
+		if n := listed("project=" + project); n != int(want.Kept) {
+			t.Errorf("strategy=%s: %d todos left in the project, want %d", strategy, n, want.Kept)
+		}
+	}
+	if n := listed("include_archived=true"); n != 8 {
+		t.Errorf("got %d todos outside the trash, want 8", n)
+	}
+
+	if w := serve(t, r, http.MethodDelete, "/projects/garden?strategy=shred", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("strategy=shred: got %d, want 400", w.Code)
+	}
+	if w := serve(t, r, http.MethodDelete, "/projects/garden?strategy=orphan", nil); w.Code != http.StatusNotFound {
+		t.Errorf("deleting an unused project: got %d, want 404", w.Code)
+	}
+}
+
+func TestRenameProject(t *testing.T) {
+	repo := model.NewMemoryRepository()
+	todos := NewTodoController(repo, nil)
+	r := gin.New()
+	r.POST("/todos", todos.CreateTodoHandler)
+	r.DELETE("/todos/:id", todos.DeleteTodoByIdHandler)
+	r.GET("/todos", todos.GetAllTodosHandler)
+	r.POST("/projects/rename", todos.RenameProjectHandler)
+
+	createTodo(t, r, map[string]interface{}{"text": "fix the gate", "project": "hmoe"})
+	trashed := createTodo(t, r, map[string]interface{}{"text": "paint the fence", "project": "hmoe"})
+	serve(t, r, http.MethodDelete, "/todos/"+trashed.ID.Hex(), nil)
+	createTodo(t, r, map[string]interface{}{"text": "clean the gutters", "project": "home"})
+
+	for _, tc := range []struct {
+		input map[string]interface{}
+		code  int
+	}{
+		{map[string]interface{}{"from": "hmoe"}, http.StatusBadRequest},
+		{map[string]interface{}{"from": "hmoe", "to": " HMOE "}, http.StatusBadRequest},
+		{map[string]interface{}{"from": "garden", "to": "yard"}, http.StatusNotFound},
+		{map[string]interface{}{"from": "hmoe", "to": "home"}, http.StatusConflict},
+	} {
+		if w := serve(t, r, http.MethodPost, "/projects/rename", tc.input); w.Code != tc.code {
+			t.Errorf("renaming %v: got %d %s, want %d", tc.input, w.Code, w.Body.String(), tc.code)
+		}
+	}
+
+	err := repo.SetDefaults(context.Background(), &model.Defaults{
+		Project:  "hmoe",
+		Projects: []model.ProjectDefaults{{Project: "hmoe", Tags: []string{"house"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(t, r, http.MethodPost, "/projects/rename", map[string]interface{}{"from": "hmoe", "to": "Home", "merge": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("merging: got %d %s", w.Code, w.Body.String())
+	}
+	var rename model.ProjectRename
+	decode(t, w, &rename)
+	if want := (model.ProjectRename{From: "hmoe", To: "home", Renamed: 2, Merged: true}); rename != want {
+		t.Errorf("got %+v, want %+v", rename, want)
+	}
+
+	var list []*model.Todo
+	decode(t, serve(t, r, http.MethodGet, "/todos?project=home", nil), &list)
+	if len(list) != 2 {
+		t.Errorf("got %d todos in home, want both projects' todos outside the trash", len(list))
+	}
+	deleted, err := repo.Deleted(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Project != "home" {
+		t.Errorf("the todo in the trash was not renamed: %+v", deleted)
+	}
+	defaults, err := repo.Defaults(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaults.Project != "home" || defaults.ForProject("hmoe") != nil || defaults.ForProject("home") == nil {
+		t.Errorf("the defaults were not renamed: %+v", defaults)
+	}
+}
+
+func TestWorkload(t *testing.T) {
+	t.Cleanup(func() { model.Now = time.Now })
+	model.FreezeClock(time.Date(2025, 3, 5, 14, 0, 0, 0, time.UTC))
+	t.Setenv("WORKLOAD_CAPACITY_MINUTES", "240")
+	t.Setenv("WORKLOAD_DEFAULT_ESTIMATE_MINUTES", "")
+
+	repo := model.NewMemoryRepository()
+	due := func(s string) *time.Time {
+		at, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &at
+	}
+	for _, todo := range []*model.Todo{
+		{Text: "file the report", DueDate: due("2025-03-01T09:00:00Z"), EstimateMinutes: 60},
+		{Text: "review the budget", DueDate: due("2025-03-05T16:00:00Z"), EstimateMinutes: 200},
+		{Text: "book flights", DueDate: due("2025-03-06T10:00:00Z")},
+		{Text: "call the bank", DueDate: due("2025-03-06T23:30:00Z"), EstimateMinutes: 45},
+		{Text: "renew the lease", DueDate: due("2025-03-20T09:00:00Z"), EstimateMinutes: 90},
+		{Text: "pay the invoice", DueDate: due("2025-03-06T09:00:00Z"), EstimateMinutes: 300, Completed: true},
+	} {
+		todo.ID = primitive.NewObjectID()
+		if err := repo.Create(context.Background(), todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	todos := NewTodoController(repo, nil)
+	r := gin.New()
+	r.GET("/workload", todos.GetWorkloadHandler)
+
+	workload := func(query string) map[string]model.WorkloadDay {
+		w := serve(t, r, http.MethodGet, "/workload?"+query, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %s", query, w.Code, w.Body.String())
+		}
+		var res model.Workload
+		decode(t, w, &res)
+		days := map[string]model.WorkloadDay{}
+		for _, day := range res.Days {
+			days[day.Date] = day
+		}
+		return days
+	}
+
+	days := workload("from=2025-03-05&to=2025-03-07")
+	if len(days) != 3 {
+		t.Fatalf("got %d days, want 3", len(days))
+	}
+	for date, want := range map[string]model.WorkloadDay{
+		// The overdue report counts into today, over the 240 minutes.
+		"2025-03-05": {Date: "2025-03-05", Minutes: 260, Todos: 2, OverCapacity: true},
+		// The flights have no estimate and count 30 minutes.
+		"2025-03-06": {Date: "2025-03-06", Minutes: 75, Todos: 2, Unestimated: 1},
+		"2025-03-07": {Date: "2025-03-07"},
+	} {
+		if days[date] != want {
+			t.Errorf("%s: got %+v, want %+v", date, days[date], want)
+		}
+	}
+
+	days = workload("from=2025-03-05&to=2025-03-07&tz=Europe/Paris&capacity=600&default_estimate=0")
+	if got := days["2025-03-07"]; got.Minutes != 45 || got.Todos != 1 {
+		t.Errorf("the call due before midnight UTC is not on the next day in Paris: %+v", got)
+	}
+	if got := days["2025-03-05"]; got.OverCapacity {
+		t.Errorf("2025-03-05 is over a capacity of 600 minutes: %+v", got)
+	}
+	if got := days["2025-03-06"]; got.Minutes != 0 || got.Unestimated != 1 {
+		t.Errorf("with no default estimate the flights count nothing: %+v", got)
+	}
+
+	for _, query := range []string{
+		"from=2025-03-05&to=2025-06-03",
+		"from=2025-03-05&to=2025-03-04",
+		"from=tomorrow",
+		"tz=Mars/Olympus",
+		"capacity=0",
+	} {
+		if w := serve(t, r, http.MethodGet, "/workload?"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", query, w.Code)
+		}
+	}
+	if days := workload("from=2025-03-05&to=2025-06-02"); len(days) != model.MaxWorkloadDays {
+		t.Errorf("got %d days, want the longest range of %d", len(days), model.MaxWorkloadDays)
+	}
+}
+
+func TestDuplicatesAreScopedToOwner(t *testing.T) {
+	repo := model.NewMemoryRepository()
+	for _, owner := range []string{"alice", "bob"} {
+		ctx := model.WithOwner(context.Background(), owner)
+		for _, text := range []string{"call the plumber about the sink", "call the plumber about the sinks"} {
+			if err := repo.Create(ctx, &model.Todo{ID: primitive.NewObjectID(), Text: text}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	todos := NewTodoController(repo, nil)
+	r := gin.New()
+	r.GET("/todos/duplicates", asOwner("alice"), todos.GetDuplicateTodosHandler)
+
+	w := serve(t, r, http.MethodGet, "/todos/duplicates", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	var clusters []*model.DuplicateCluster
+	decode(t, w, &clusters)
+	if len(clusters) != 1 || len(clusters[0].Todos) != 2 {
+		t.Fatalf("got %d clusters, want one of alice's two todos: %s", len(clusters), w.Body.String())
+	}
+	for _, todo := range clusters[0].Todos {
+		if todo.Owner != "alice" {
+			t.Errorf("cluster holds %q owned by %q", todo.Text, todo.Owner)
+		}
+	}
+}
+
+func TestDuplicatesStoreError(t *testing.T) {
+	todos := NewTodoController(failingRepository{model.NewMemoryRepository(), errors.New("connection reset")}, nil)
+	r := gin.New()
+	r.GET("/todos/duplicates", todos.GetDuplicateTodosHandler)
+
+	w := serve(t, r, http.MethodGet, "/todos/duplicates", nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d %s, want 500", w.Code, w.Body.String())
+	}
+	var res ErrorResponse
+	decode(t, w, &res)
+	if res.Error != "connection reset" {
+		t.Errorf("got error %q, want the store error", res.Error)
+	}
+}
+
+// useUndoStore returns an undo store and response cache on the Redis at
+// TEST_REDIS_ADDR. Tests needing Redis are skipped when it is unset.
+func useUndoStore(t *testing.T) (*model.UndoStore, *model.RedisCache) {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REDIS_HOST", host)
+	t.Setenv("REDIS_PORT", port)
+	t.Setenv("UNDO_WINDOW", "")
+
+	redisCache := model.SetupRedisCache()
+	undo, err := model.NewUndoStore(redisCache.Store.RedisClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return undo, redisCache
+}
+
+// newUndoRouter serves deletion, bulk completion and undo for owner from
+// repo.
+func newUndoRouter(repo model.TodoRepository, undo *model.UndoStore, redisCache *model.RedisCache, owner string) *gin.Engine {
+	todos := NewTodoController(repo, undo)
+	r := gin.New()
+	r.Use(asOwner(owner))
+	r.POST("/todos", todos.CreateTodoHandler)
+	r.GET("/todos/:id", todos.GetTodoByIdHandler)
+	r.DELETE("/todos/:id", todos.DeleteTodoByIdHandler)
+	r.POST("/todos/complete", todos.CompleteTodosHandler(redisCache))
+	r.POST("/undo", todos.UndoHandler)
+	return r
+}
+
+func undoToken(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	token := w.Header().Get("X-Undo-Token")
+	if token == "" {
+		t.Fatalf("got no X-Undo-Token with %d %s", w.Code, w.Body.String())
+	}
+	return token
+}
+
+func TestUndoDelete(t *testing.T) {
+	undo, redisCache := useUndoStore(t)
+	r := newUndoRouter(model.NewMemoryRepository(), undo, redisCache, "alice")
+	todo := createTodo(t, r, map[string]interface{}{"text": "renew passport"})
+
+	token := undoToken(t, serve(t, r, http.MethodDelete, "/todos/"+todo.ID.Hex(), nil))
+	w := serve(t, r, http.MethodPost, "/undo", map[string]string{"token": token})
+	if w.Code != http.StatusOK {
+		t.Fatalf("undoing a deletion: got %d %s", w.Code, w.Body.String())
+	}
+	if w = serve(t, r, http.MethodGet, "/todos/"+todo.ID.Hex(), nil); w.Code != http.StatusOK {
+		t.Errorf("getting an undeleted todo: got %d, want 200", w.Code)
+	}
+
+	w = serve(t, r, http.MethodPost, "/undo", map[string]string{"token": token})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("reusing an undo token: got %d, want 404", w.Code)
+	}
+}
+
+func TestUndoTokenExpires(t *testing.T) {
+	undo, redisCache := useUndoStore(t)
+	undo.Window = 100 * time.Millisecond
+	r := newUndoRouter(model.NewMemoryRepository(), undo, redisCache, "alice")
+	todo := createTodo(t, r, map[string]interface{}{"text": "renew passport"})
+
+	token := undoToken(t, serve(t, r, http.MethodDelete, "/todos/"+todo.ID.Hex(), nil))
+	time.Sleep(3 * undo.Window)
+	w := serve(t, r, http.MethodPost, "/undo", map[string]string{"token": token})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("undoing after the window: got %d %s, want 404", w.Code, w.Body.String())
+	}
+}
+
+func TestUndoTokenBoundToOwner(t *testing.T) {
+	undo, redisCache := useUndoStore(t)
+	repo := model.NewMemoryRepository()
+	alice := newUndoRouter(repo, undo, redisCache, "alice")
+	bob := newUndoRouter(repo, undo, redisCache, "bob")
+	todo := createTodo(t, alice, map[string]interface{}{"text": "renew passport"})
+
+	token := undoToken(t, serve(t, alice, http.MethodDelete, "/todos/"+todo.ID.Hex(), nil))
+	w := serve(t, bob, http.MethodPost, "/undo", map[string]string{"token": token})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("undoing someone else's deletion: got %d %s, want 404", w.Code, w.Body.String())
+	}
+
+	// Trying it does not use the token up for its owner.
+	w = serve(t, alice, http.MethodPost, "/undo", map[string]string{"token": token})
+	if w.Code != http.StatusOK {
+		t.Errorf("undoing after someone else tried: got %d %s, want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestUndoBulkComplete(t *testing.T) {
+	undo, redisCache := useUndoStore(t)
+	r := newUndoRouter(model.NewMemoryRepository(), undo, redisCache, "alice")
+	done := createTodo(t, r, map[string]interface{}{"text": "book venue", "completed": true})
+	first := createTodo(t, r, map[string]interface{}{"text": "send invites"})
+	second := createTodo(t, r, map[string]interface{}{"text": "order cake"})
+
+	ids := []string{done.ID.Hex(), first.ID.Hex(), second.ID.Hex()}
+	w := serve(t, r, http.MethodPost, "/todos/complete", map[string]interface{}{"ids": ids})
+	if w.Code != http.StatusOK {
+		t.Fatalf("completing in bulk: got %d %s", w.Code, w.Body.String())
+	}
+	token := undoToken(t, w)
+
+	w = serve(t, r, http.MethodPost, "/undo", map[string]string{"token": token})
+	if w.Code != http.StatusOK {
+		t.Fatalf("undoing a bulk completion: got %d %s", w.Code, w.Body.String())
+	}
+	var restored []*model.Todo
+	decode(t, w, &restored)
+	if len(restored) != 2 {
+		t.Errorf("undo restored %d todos, want the 2 the request completed", len(restored))
+	}
+
+	for _, tc := range []struct {
+		todo      *model.Todo
+		completed bool
+	}{{done, true}, {first, false}, {second, false}} {
+		w = serve(t, r, http.MethodGet, "/todos/"+tc.todo.ID.Hex(), nil)
+		got := &model.Todo{}
+		decode(t, w, got)
+		if got.Completed != tc.completed {
+			t.Errorf("%q completed = %v after undo, want %v", tc.todo.Text, got.Completed, tc.completed)
+		}
+	}
+}
+
+// spawningRepository creates a next occurrence, as MongoRepository does, for
+// every recurring todo CompleteMany completes.
+type spawningRepository struct {
+	model.TodoRepository
+}
+
+func (r spawningRepository) CompleteMany(ctx context.Context, ids []primitive.ObjectID) (int64, []primitive.ObjectID, error) {
+	todos, err := r.GetByIDs(ctx, ids)
+	if err != nil {
+		return 0, nil, err
+	}
+	completed, _, err := r.TodoRepository.CompleteMany(ctx, ids)
+	if err != nil {
+		return completed, nil, err
+	}
+
+	var spawned []primitive.ObjectID
+	for _, todo := range todos {
+		if todo.Recurrence == "" || todo.Completed {
+			continue
+		}
+		next := &model.Todo{ID: primitive.NewObjectID(), Text: todo.Text, Recurrence: todo.Recurrence}
+		if err := r.Create(ctx, next); err != nil {
+			return completed, spawned, err
+		}
+		spawned = append(spawned, next.ID)
+	}
+	return completed, spawned, nil
+}
+
+func TestUndoBulkCompleteDeletesNextOccurrences(t *testing.T) {
+	undo, redisCache := useUndoStore(t)
+	repo := spawningRepository{model.NewMemoryRepository()}
+	r := newUndoRouter(repo, undo, redisCache, "alice")
+	todo := createTodo(t, r, map[string]interface{}{"text": "water plants", "recurrence": "weekly"})
+
+	w := serve(t, r, http.MethodPost, "/todos/complete", map[string]interface{}{"ids": []string{todo.ID.Hex()}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("completing in bulk: got %d %s", w.Code, w.Body.String())
+	}
+	token := undoToken(t, w)
+
+	w = serve(t, r, http.MethodPost, "/undo", map[string]string{"token": token})
+	if w.Code != http.StatusOK {
+		t.Fatalf("undoing a bulk completion: got %d %s", w.Code, w.Body.String())
+	}
+
+	pending := false
+	todos, total, err := repo.List(model.WithOwner(context.Background(), "alice"), model.TodoQuery{Completed: &pending})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || todos[0].ID != todo.ID {
+		t.Errorf("pending after undo: %d todos, want only the reopened %q", total, todo.Text)
+	}
+}
+
+func TestWritesInvalidateCachedTodos(t *testing.T) {
+	undo, redisCache := useUndoStore(t)
+	todos := NewTodoController(model.NewMemoryRepository(), undo)
+	owner := "cache-" + primitive.NewObjectID().Hex()
+	r := gin.New()
+	r.Use(asOwner(owner), func(c *gin.Context) {
+		c.Set("id", &middleware.User{UserName: owner})
+		c.Next()
+	}, middleware.InvalidateTodosOnWrite(redisCache))
+	r.POST("/todos", todos.CreateTodoHandler)
+	r.GET("/todos/:id", cache.CacheByRequestURI(redisCache.Store, time.Minute, cache.WithCacheStrategyByRequest(middleware.CacheByUserAndRequestURI)), todos.GetTodoByIdHandler)
+	r.PUT("/todos/:id", todos.UpdateTodoByIdHandler)
+	todo := createTodo(t, r, map[string]interface{}{"text": "book dentist"})
+
+	serve(t, r, http.MethodGet, "/todos/"+todo.ID.Hex(), nil)
+	if w := serve(t, r, http.MethodPut, "/todos/"+todo.ID.Hex(), map[string]interface{}{"text": "book dentist", "completed": true}); w.Code != http.StatusNoContent {
+		t.Fatalf("updating: got %d %s", w.Code, w.Body.String())
+	}
+	var cached model.Todo
+	decode(t, serve(t, r, http.MethodGet, "/todos/"+todo.ID.Hex(), nil), &cached)
+	if !cached.Completed {
+		t.Error("cached read after an update returned the stale todo")
+	}
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/middleware"
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary		List deleted todos
+// @ID				get-trash
+// @Tags			Todos
+// @Description	List todos that were deleted and have not been purged yet
+// @Produce		json
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{array}		model.Todo
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/trash [get]
+func (h *TodoController) GetTrashHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	todos, err := h.todos.Deleted(ctx)
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todos)
+}
+
+// @Summary		Restore a deleted todo
+// @ID				restore-todo
+// @Tags			Todos
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Todo
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/{id}/restore [post]
+func (h *TodoController) RestoreTodoHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	todo, err := h.todos.Restore(ctx, c.Param("id"))
+	if errors.Is(err, model.ErrTodoNotInTrash) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, todo)
+}
+
+// @Summary		Purge a deleted todo
+// @ID				purge-todo
+// @Tags			Todos
+// @Description	Permanently remove a todo from the trash, along with its notes and history. It can no longer be restored.
+// @Produce		json
+// @Param			id				path	string	true	"Todo ID"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		204
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		404	{object}	controller.ErrorResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos/trash/{id} [delete]
+func (h *TodoController) PurgeTodoHandler(c *gin.Context) {
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	err := h.todos.PurgeTodo(ctx, c.Param("id"))
+	if errors.Is(err, model.ErrTodoNotInTrash) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteCompletedResponse reports how many todos were permanently removed.
+type DeleteCompletedResponse struct {
+	Deleted int64 `json:"deleted" example:"12"`
+}
+
+// @Summary		Delete completed todos
+// @ID				delete-completed-todos
+// @Tags			Todos
+// @Description	Permanently remove every completed todo, in the trash or not, along with its notes and history. completed=true is required so a bare DELETE never removes anything. Completed todos that still have subtasks are kept. Cached todo responses of the current user are dropped.
+// @Produce		json
+// @Param			completed		query	bool	true	"Must be true"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	controller.DeleteCompletedResponse
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/todos [delete]
+func (h *TodoController) DeleteCompletedHandler(cache *model.RedisCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("completed") != "true" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "completed=true is required to delete completed todos"})
+			return
+		}
+
+		ctx, ok := requestContext(c)
+		if !ok {
+			return
+		}
+
+		deleted, err := h.todos.DeleteCompleted(ctx)
+		if deleted > 0 {
+			if err := cache.InvalidateTodos(ctx, middleware.CurrentUserName(c)); err != nil {
+				_ = c.Error(err)
+			}
+		}
+		if err != nil {
+			c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, DeleteCompletedResponse{Deleted: deleted})
+	}
+}
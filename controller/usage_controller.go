@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/middleware"
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// maxUsageRange bounds the period a usage query can cover.
+const maxUsageRange = 400 * 24 * time.Hour
+
+// usageRange reads the from and to query parameters as YYYY-MM-DD dates,
+// defaulting to the last 30 days.
+func usageRange(c *gin.Context) (from time.Time, to time.Time, ok bool) {
+	to = time.Now().UTC().Truncate(24 * time.Hour)
+	from = to.AddDate(0, 0, -30)
+
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: name + " must be a YYYY-MM-DD date"})
+			return from, to, false
+		}
+		*dst = t
+	}
+	if to.Before(from) || to.Sub(from) > maxUsageRange {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "to must be after from and at most 400 days later"})
+		return from, to, false
+	}
+
+	return from, to, true
+}
+
+func respondUsage(c *gin.Context, usage *model.UsageStore, user string) {
+	from, to, ok := usageRange(c)
+	if !ok {
+		return
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	rows, err := usage.Usage(ctx, user, from, to, c.Query("monthly") == "true")
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+// UsageHandler reports API usage per user and day, or per month with
+// monthly=true, optionally for a single user.
+func UsageHandler(usage *model.UsageStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondUsage(c, usage, c.Query("user"))
+	}
+}
+
+// @Summary		Get my API usage
+// @ID				get-my-usage
+// @Tags			Usage
+// @Description	Requests made and bytes transferred per day, up to the last hourly flush
+// @Produce		json
+// @Param			from			query	string	false	"First day, YYYY-MM-DD, defaults to 30 days ago"
+// @Param			to				query	string	false	"Last day, YYYY-MM-DD, defaults to today"
+// @Param			monthly			query	bool	false	"Return monthly totals instead"
+// @Param			Authorization	header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{array}		model.UsageRow
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/me/usage [get]
+func MyUsageHandler(usage *model.UsageStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondUsage(c, usage, middleware.CurrentUserName(c))
+	}
+}
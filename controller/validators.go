@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidators adds the custom validation tags used by the request
+// types to gin's binding engine. It must run before the first request is
+// bound, as binding a struct with an unknown tag panics.
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin's binding engine is not a go-playground validator")
+	}
+
+	return v.RegisterValidation("todocolor", func(fl validator.FieldLevel) bool {
+		return model.ValidColor(fl.Field().String())
+	})
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// workloadDays is how many days a workload covers when to is not given.
+const workloadDays = 7
+
+// @Summary		Get the workload per day
+// @ID				get-workload
+// @Tags			Todos
+// @Description	Total the estimated minutes of the pending todos due on each day from from to to, both included, and flag the days over the daily capacity. Days are dates in tz, overdue todos count into today and todos without an estimate count with the default estimate. The capacity and default estimate come from WORKLOAD_CAPACITY_MINUTES and WORKLOAD_DEFAULT_ESTIMATE_MINUTES, 480 and 30 unless set, and can be overridden per request. Ranges longer than 90 days are refused.
+// @Produce		json
+// @Param			from				query	string	false	"First day as YYYY-MM-DD, defaults to today"
+// @Param			to					query	string	false	"Last day as YYYY-MM-DD, defaults to 6 days after from"
+// @Param			tz					query	string	false	"IANA time zone of the days, defaults to UTC"
+// @Param			capacity			query	int		false	"Daily capacity in minutes"
+// @Param			default_estimate	query	int		false	"Minutes counted for a todo without an estimate"
+// @Param			Authorization		header	string	false	"Authorization"
+// @Security		JWT
+// @Success		200	{object}	model.Workload
+// @Failure		400	{object}	controller.ErrorResponse
+// @Failure		401	{object}	controller.UnauthorizedResponse
+// @Failure		408	{object}	controller.ErrorResponse
+// @Failure		500	{object}	controller.ErrorResponse
+// @Failure		504	{object}	controller.ErrorResponse
+// @Router			/workload [get]
+func (h *TodoController) GetWorkloadHandler(c *gin.Context) {
+	capacity, estimate, err := model.WorkloadSettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	query := model.WorkloadQuery{Location: time.UTC, CapacityMinutes: capacity, DefaultEstimate: estimate}
+
+	if raw := c.Query("tz"); raw != "" {
+		if query.Location, err = time.LoadLocation(raw); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "tz must be an IANA time zone such as Europe/Paris"})
+			return
+		}
+	}
+	query.From = model.Now().In(query.Location)
+	if raw := c.Query("from"); raw != "" {
+		if query.From, err = time.ParseInLocation(time.DateOnly, raw, query.Location); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "from must be a date as YYYY-MM-DD"})
+			return
+		}
+	}
+	query.To = query.From.AddDate(0, 0, workloadDays-1)
+	if raw := c.Query("to"); raw != "" {
+		if query.To, err = time.ParseInLocation(time.DateOnly, raw, query.Location); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "to must be a date as YYYY-MM-DD"})
+			return
+		}
+	}
+	if raw := c.Query("capacity"); raw != "" {
+		if query.CapacityMinutes, err = strconv.Atoi(raw); err != nil || query.CapacityMinutes < 1 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "capacity must be a positive number of minutes"})
+			return
+		}
+	}
+	if raw := c.Query("default_estimate"); raw != "" {
+		if query.DefaultEstimate, err = strconv.Atoi(raw); err != nil || query.DefaultEstimate < 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "default_estimate must be a number of minutes"})
+			return
+		}
+	}
+
+	ctx, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	workload, err := h.todos.Workload(ctx, query)
+	if errors.Is(err, model.ErrWorkloadRange) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(storeErrorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, workload)
+}
@@ -0,0 +1,13 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// PrivateNoStore marks responses as specific to the authenticated user, so
+// neither browsers nor shared caches such as CDNs keep a copy. It goes on
+// every authenticated route; the server-side response cache is unaffected.
+func PrivateNoStore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "private, no-store")
+		c.Next()
+	}
+}
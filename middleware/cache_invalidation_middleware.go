@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// InvalidateTodosOnWrite drops the todo responses cached for the current
+// user whenever a request other than a read succeeds, so every write is
+// visible on the next cached read. The cache is cleared when the handler
+// sets its status, before any of the response reaches the client.
+func InvalidateTodosOnWrite(cache *model.RedisCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		c.Writer = &invalidatingWriter{ResponseWriter: c.Writer, c: c, cache: cache}
+		c.Next()
+	}
+}
+
+// invalidatingWriter invalidates the cached todos of the request's user
+// the first time a successful status is set.
+type invalidatingWriter struct {
+	gin.ResponseWriter
+	c     *gin.Context
+	cache *model.RedisCache
+	done  bool
+}
+
+func (w *invalidatingWriter) WriteHeader(code int) {
+	w.invalidate(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write covers handlers that write a body without setting a status first.
+func (w *invalidatingWriter) Write(data []byte) (int, error) {
+	w.invalidate(w.Status())
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *invalidatingWriter) WriteString(s string) (int, error) {
+	w.invalidate(w.Status())
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *invalidatingWriter) invalidate(code int) {
+	if w.done || code >= http.StatusBadRequest {
+		return
+	}
+	w.done = true
+	user := CurrentUserName(w.c)
+	if err := w.cache.InvalidateTodos(w.c.Request.Context(), user); err != nil {
+		log.Printf("Invalidating cached todos for %s failed: %v", user, err)
+	}
+}
@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"time"
 
+	"github.com/CharlesPatterson/todos-app/model"
 	jwt "github.com/appleboy/gin-jwt/v2"
+	cache "github.com/chenyahui/gin-cache"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,6 +20,12 @@ type Login struct {
 
 var (
 	identityKey = "id"
+	// passwords holds the users that can log in, until accounts are
+	// persisted.
+	passwords = map[string]string{
+		"admin": "admin",
+		"test":  "test",
+	}
 )
 
 type User struct {
@@ -24,6 +34,60 @@ type User struct {
 	LastName  string
 }
 
+// KnownUser reports whether a user with the given name can log in.
+func KnownUser(name string) bool {
+	_, ok := passwords[name]
+	return ok
+}
+
+// CurrentUserName returns the name of the user the request was
+// authenticated as, or an empty string when it carries no identity.
+func CurrentUserName(c *gin.Context) string {
+	if v, ok := c.Get(identityKey); ok {
+		if user, ok := v.(*User); ok {
+			return user.UserName
+		}
+	}
+	return ""
+}
+
+// ScopeToCurrentUser limits the todos the request can see and change to
+// those owned by the user it was authenticated as.
+func ScopeToCurrentUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := model.WithOwner(c.Request.Context(), CurrentUserName(c))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// CacheByUserAndRequestURI keys cached responses by the authenticated user as
+// well as the request URI, so users are never served each other's todos.
+func CacheByUserAndRequestURI(c *gin.Context) (bool, cache.Strategy) {
+	return true, cache.Strategy{
+		CacheKey: model.TodoCacheKey(CurrentUserName(c), c.Request.RequestURI),
+	}
+}
+
+// IsAdmin reports whether the request was authenticated as the admin user.
+func IsAdmin(c *gin.Context) bool {
+	return CurrentUserName(c) == "admin"
+}
+
+// RequireAdmin rejects requests not authenticated as the admin user.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code":    http.StatusForbidden,
+				"message": "admin access required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
 func HandlerMiddleware(authMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		errInit := authMiddleware.MiddlewareInit()
@@ -33,6 +97,29 @@ func HandlerMiddleware(authMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
 	}
 }
 
+// ValidateJWTConfig reports whether SECRET_KEY is long enough to sign tokens.
+// An empty key is accepted by gin-jwt but produces tokens nobody can trust.
+func ValidateJWTConfig() error {
+	key := os.Getenv("SECRET_KEY")
+	if len(key) < model.MinSecretKeyLength {
+		return fmt.Errorf("SECRET_KEY must be at least %d characters long, got %d", model.MinSecretKeyLength, len(key))
+	}
+
+	return nil
+}
+
+// VerifyTokenRoundTrip signs a token for a probe identity and parses it back,
+// proving the configured key can both issue and validate tokens.
+func VerifyTokenRoundTrip(authMiddleware *jwt.GinJWTMiddleware) error {
+	token, _, err := authMiddleware.TokenGenerator(&User{UserName: "readyz"})
+	if err != nil {
+		return err
+	}
+
+	_, err = authMiddleware.ParseTokenString(token)
+	return err
+}
+
 func InitJWTParams() *jwt.GinJWTMiddleware {
 
 	return &jwt.GinJWTMiddleware{
@@ -84,7 +171,7 @@ func authenticator() func(c *gin.Context) (interface{}, error) {
 		userID := loginVals.Username
 		password := loginVals.Password
 
-		if (userID == "admin" && password == "admin") || (userID == "test" && password == "test") {
+		if expected, ok := passwords[userID]; ok && password == expected {
 			return &User{
 				UserName:  userID,
 				LastName:  "Patterson",
@@ -95,12 +182,12 @@ func authenticator() func(c *gin.Context) (interface{}, error) {
 	}
 }
 
+// authorizator lets any user who can log in through. Routes limited to the
+// admin user add RequireAdmin.
 func authorizator() func(data interface{}, c *gin.Context) bool {
 	return func(data interface{}, c *gin.Context) bool {
-		if v, ok := data.(*User); ok && v.UserName == "admin" {
-			return true
-		}
-		return false
+		v, ok := data.(*User)
+		return ok && KnownUser(v.UserName)
 	}
 }
 
@@ -0,0 +1,115 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CharlesPatterson/todos-app/controller"
+	"github.com/CharlesPatterson/todos-app/middleware"
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// newScopedRouter serves todos from an in-memory repository behind JWT
+// authentication and owner scoping, as runServer does.
+func newScopedRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SECRET_KEY", "0123456789abcdef0123456789abcdef")
+	if err := controller.RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+	auth := middleware.InitJWTParams()
+	if err := auth.MiddlewareInit(); err != nil {
+		t.Fatal(err)
+	}
+
+	todos := controller.NewTodoController(model.NewMemoryRepository(), nil)
+	r := gin.New()
+	r.POST("/login", auth.LoginHandler)
+	admin := r.Group("/admin", auth.MiddlewareFunc())
+	admin.GET("/ping", middleware.RequireAdmin(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	v1 := r.Group("/api/v1", auth.MiddlewareFunc(), middleware.ScopeToCurrentUser())
+	v1.POST("/todos", todos.CreateTodoHandler)
+	v1.GET("/todos/:id", todos.GetTodoByIdHandler)
+	return r
+}
+
+func request(t *testing.T, r *gin.Engine, method string, path string, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func login(t *testing.T, r *gin.Engine, username string) string {
+	t.Helper()
+
+	w := request(t, r, http.MethodPost, "/login", "", middleware.Login{Username: username, Password: username})
+	if w.Code != http.StatusOK {
+		t.Fatalf("logging in as %s: got %d %s", username, w.Code, w.Body.String())
+	}
+	var res struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Token
+}
+
+func TestUsersOnlySeeTheirOwnTodos(t *testing.T) {
+	r := newScopedRouter(t)
+	testToken := login(t, r, "test")
+	adminToken := login(t, r, "admin")
+
+	w := request(t, r, http.MethodPost, "/api/v1/todos", testToken, map[string]string{"text": "renew passport"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating as test: got %d %s", w.Code, w.Body.String())
+	}
+	var created model.Todo
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Owner != "test" {
+		t.Errorf("owner = %q, want test", created.Owner)
+	}
+
+	path := "/api/v1/todos/" + created.ID.Hex()
+	if w := request(t, r, http.MethodGet, path, testToken, nil); w.Code != http.StatusOK {
+		t.Errorf("owner reading their todo: got %d, want 200", w.Code)
+	}
+	if w := request(t, r, http.MethodGet, path, adminToken, nil); w.Code != http.StatusNotFound {
+		t.Errorf("another user reading the todo: got %d, want 404", w.Code)
+	}
+	if w := request(t, r, http.MethodGet, path, "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous read: got %d, want 401", w.Code)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	r := newScopedRouter(t)
+
+	if w := request(t, r, http.MethodGet, "/admin/ping", login(t, r, "test"), nil); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin on an admin route: got %d, want 403", w.Code)
+	}
+	if w := request(t, r, http.MethodGet, "/admin/ping", login(t, r, "admin"), nil); w.Code != http.StatusNoContent {
+		t.Errorf("admin on an admin route: got %d, want 204", w.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultSlowRequestThreshold = 300 * time.Millisecond
+
+var (
+	sloRequests     = expvar.NewMap("slo_requests_total")
+	sloSlowRequests = expvar.NewMap("slo_requests_slow")
+)
+
+// LatencyBudget is the latency a request may take before it counts against
+// the SLO, globally and per "METHOD /route/:pattern".
+type LatencyBudget struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// LatencyBudgetFromEnv reads SLOW_REQUEST_THRESHOLD, a Go duration, and
+// SLOW_REQUEST_ROUTE_THRESHOLDS, a comma-separated list of route=duration
+// overrides such as "POST /api/v1/todos/import=10s".
+func LatencyBudgetFromEnv() (LatencyBudget, error) {
+	budget := LatencyBudget{
+		Default: defaultSlowRequestThreshold,
+		Routes:  map[string]time.Duration{},
+	}
+
+	if raw := os.Getenv("SLOW_REQUEST_THRESHOLD"); raw != "" {
+		threshold, err := time.ParseDuration(raw)
+		if err != nil {
+			return budget, fmt.Errorf("SLOW_REQUEST_THRESHOLD is not a duration: %w", err)
+		}
+		budget.Default = threshold
+	}
+
+	if raw := os.Getenv("SLOW_REQUEST_ROUTE_THRESHOLDS"); raw != "" {
+		for _, pair := range strings.Split(raw, ",") {
+			route, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return budget, fmt.Errorf("SLOW_REQUEST_ROUTE_THRESHOLDS entry %q is not route=duration", pair)
+			}
+			threshold, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil {
+				return budget, fmt.Errorf("SLOW_REQUEST_ROUTE_THRESHOLDS entry %q: %w", pair, err)
+			}
+			budget.Routes[strings.TrimSpace(route)] = threshold
+		}
+	}
+
+	return budget, nil
+}
+
+// LatencyMiddleware counts requests per route, and those exceeding their
+// latency budget, in the slo_requests_total and slo_requests_slow metrics.
+// Slow requests are logged with the time spent in Mongo and Redis.
+func LatencyMiddleware(budget LatencyBudget) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, timings := model.WithTimings(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+
+		start := time.Now()
+		c.Next()
+		elapsed := time.Since(start)
+
+		route := c.Request.Method + " " + c.FullPath()
+		if c.FullPath() == "" {
+			route = c.Request.Method + " unmatched"
+		}
+		sloRequests.Add(route, 1)
+
+		threshold, ok := budget.Routes[route]
+		if !ok {
+			threshold = budget.Default
+		}
+		if elapsed <= threshold {
+			return
+		}
+
+		sloSlowRequests.Add(route, 1)
+		mongo := timings.Mongo()
+		redis := timings.Redis()
+		log.Printf(
+			"slow request route=%q status=%d duration=%s threshold=%s mongo=%s redis=%s handler=%s request_id=%s user=%q",
+			route, c.Writer.Status(), elapsed, threshold, mongo, redis,
+			max(elapsed-mongo-redis, 0), requestid.Get(c), CurrentUserName(c),
+		)
+	}
+}
@@ -1,20 +1,41 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/gin-contrib/timeout"
 	"github.com/gin-gonic/gin"
 )
 
+const requestTimeout = 500 * time.Millisecond
+
 func timeoutResponse(c *gin.Context) {
-	c.String(http.StatusRequestTimeout, "timeout")
+	c.JSON(http.StatusRequestTimeout, gin.H{"error": "request timed out"})
 }
 
-func TimeoutMiddleware() gin.HandlerFunc {
-	return timeout.New(
-		timeout.WithTimeout(500*time.Millisecond),
+// TimeoutMiddleware responds with a timeout once requestTimeout elapses and
+// also bounds the request context, so any store call still in flight is
+// cancelled rather than left running after the response was sent. Requests
+// to excludedPaths, such as streaming responses, are passed through as is.
+func TimeoutMiddleware(excludedPaths ...string) gin.HandlerFunc {
+	handler := timeout.New(
+		timeout.WithTimeout(requestTimeout),
 		timeout.WithResponse(timeoutResponse),
 	)
+
+	return func(c *gin.Context) {
+		if slices.Contains(excludedPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		handler(c)
+	}
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/CharlesPatterson/todos-app/model"
+	"github.com/gin-gonic/gin"
+)
+
+// UsageMiddleware counts each authenticated request, and the bytes it sent
+// and received, against the caller's daily usage. It must run after the JWT
+// middleware so the caller is known.
+func UsageMiddleware(usage *model.UsageStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		user := CurrentUserName(c)
+		if user == "" {
+			return
+		}
+
+		bytes := int64(max(c.Writer.Size(), 0))
+		if c.Request.ContentLength > 0 {
+			bytes += c.Request.ContentLength
+		}
+		if err := usage.Record(c.Request.Context(), user, bytes); err != nil {
+			log.Printf("Recording usage for %s failed: %v", user, err)
+		}
+	}
+}
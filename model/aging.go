@@ -0,0 +1,137 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// maxAgingPreview bounds the ids returned by a dry run.
+	maxAgingPreview = 100
+	// agingRunBudget bounds each scheduled run, which has no request
+	// deadline to inherit.
+	agingRunBudget = 2 * time.Minute
+)
+
+type AgingResult struct {
+	Cutoff  time.Time            `json:"cutoff"`
+	Matched int64                `json:"matched"`
+	Marked  int64                `json:"marked"`
+	DryRun  bool                 `json:"dry_run"`
+	Preview []primitive.ObjectID `json:"preview,omitempty"`
+}
+
+// StaleAfter returns how long a pending todo may go untouched before it is
+// marked stale, read from STALE_AFTER_DAYS. Aging is disabled, and ok false,
+// when the variable is unset.
+func StaleAfter() (after time.Duration, ok bool, err error) {
+	raw := os.Getenv("STALE_AFTER_DAYS")
+	if raw == "" {
+		return 0, false, nil
+	}
+
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 1 {
+		return 0, false, fmt.Errorf("STALE_AFTER_DAYS must be a positive number of days, got %q", raw)
+	}
+
+	return time.Duration(days) * 24 * time.Hour, true, nil
+}
+
+func StaleFilter() bson.D {
+	return bson.D{
+		notDeleted,
+		primitive.E{Key: "completed", Value: false},
+		primitive.E{Key: "stale_since", Value: bson.M{"$exists": true}},
+	}
+}
+
+// MarkStale sets stale_since on every pending todo not updated within
+// olderThan, using a single UpdateMany. A dry run only reports the matches.
+func (r *MongoRepository) MarkStale(ctx context.Context, olderThan time.Duration, dryRun bool) (*AgingResult, error) {
+	now := Now()
+	result := &AgingResult{Cutoff: now.Add(-olderThan), DryRun: dryRun}
+	filter := bson.D{
+		notDeleted,
+		primitive.E{Key: "completed", Value: false},
+		primitive.E{Key: "stale_since", Value: bson.M{"$exists": false}},
+		primitive.E{Key: "updated_at", Value: bson.M{"$lt": result.Cutoff}},
+	}
+
+	if dryRun {
+		matched, err := r.countTodos(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		result.Matched = matched
+
+		opts := findOptions(ctx).SetProjection(bson.M{"_id": 1}).SetLimit(maxAgingPreview)
+		cur, err := r.coll.Find(ctx, filter, opts)
+		if err != nil {
+			return nil, deadlineError(err)
+		}
+		var docs []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cur.All(ctx, &docs); err != nil {
+			return nil, deadlineError(err)
+		}
+		for _, doc := range docs {
+			result.Preview = append(result.Preview, doc.ID)
+		}
+		return result, nil
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	res, err := r.coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"stale_since": now}})
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	result.Matched = res.MatchedCount
+	result.Marked = res.ModifiedCount
+	return result, nil
+}
+
+// RunAging marks the stale todos in todos once per interval until ctx is
+// cancelled.
+func RunAging(ctx context.Context, todos TodoRepository, interval time.Duration, olderThan time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		lock, err := todos.AcquireLock(ctx, BatchLock, LockHolder("aging"))
+		if err != nil {
+			log.Printf("Skipping stale todo marking: %v", err)
+			continue
+		}
+
+		runCtx, cancel := context.WithTimeout(ctx, agingRunBudget)
+		result, err := todos.MarkStale(runCtx, olderThan, false)
+		cancel()
+		if err := lock.Release(context.Background()); err != nil {
+			log.Printf("Releasing %s lock failed: %v", BatchLock, err)
+		}
+		if err != nil {
+			log.Printf("Marking stale todos failed: %v", err)
+			continue
+		}
+		if result.Marked > 0 {
+			log.Printf("Marked %d todos not updated since %s as stale", result.Marked, result.Cutoff.Format(time.RFC3339))
+		}
+	}
+}
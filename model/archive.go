@@ -0,0 +1,39 @@
+package model
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SetArchived archives or unarchives the todo with the given id and returns
+// it as updated. Archived todos are kept out of the default listings
+// without being deleted. A missing todo is mongo.ErrNoDocuments.
+func (r *MongoRepository) SetArchived(ctx context.Context, id string, archived bool) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	set := bson.M{"updated_at": Now()}
+	update := bson.M{"$set": set}
+	if archived {
+		set["archived"] = true
+	} else {
+		update["$unset"] = bson.M{"archived": ""}
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	t := &Todo{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := r.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(t); err != nil {
+		return nil, deadlineError(err)
+	}
+
+	return t, nil
+}
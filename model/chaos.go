@@ -0,0 +1,178 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrChaosInjected is returned by operations failed on purpose by chaos mode.
+var ErrChaosInjected = errors.New("chaos: injected failure")
+
+// FaultSpec describes the faults injected into one kind of store operation.
+// Rates are probabilities between 0 and 1.
+type FaultSpec struct {
+	LatencyMs int     `json:"latency_ms"`
+	ErrorRate float64 `json:"error_rate"`
+	DropRate  float64 `json:"drop_rate"`
+}
+
+// ChaosSpec is the fault-injection configuration for each backing store.
+type ChaosSpec struct {
+	Mongo FaultSpec `json:"mongo"`
+	Redis FaultSpec `json:"redis"`
+}
+
+func (f FaultSpec) validate(name string) error {
+	if f.LatencyMs < 0 {
+		return fmt.Errorf("%s.latency_ms must not be negative", name)
+	}
+	for field, rate := range map[string]float64{"error_rate": f.ErrorRate, "drop_rate": f.DropRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s.%s must be between 0 and 1", name, field)
+		}
+	}
+	return nil
+}
+
+// Validate reports the first out-of-range value in the spec.
+func (s ChaosSpec) Validate() error {
+	if err := s.Mongo.validate("mongo"); err != nil {
+		return err
+	}
+	return s.Redis.validate("redis")
+}
+
+var chaosSpec atomic.Pointer[ChaosSpec]
+
+// ChaosEnabled reports whether fault injection was switched on with
+// CHAOS_MODE=true. It is never enabled in production.
+func ChaosEnabled() bool {
+	return os.Getenv("CHAOS_MODE") == "true" && os.Getenv("ENVIRONMENT") != "production"
+}
+
+// CurrentChaos returns the faults currently being injected.
+func CurrentChaos() ChaosSpec {
+	if s := chaosSpec.Load(); s != nil {
+		return *s
+	}
+	return ChaosSpec{}
+}
+
+// SetChaos replaces the faults being injected. It takes effect on the next
+// store operation.
+func SetChaos(spec ChaosSpec) error {
+	if !ChaosEnabled() {
+		return errors.New("chaos mode is disabled, set CHAOS_MODE=true")
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	chaosSpec.Store(&spec)
+	return nil
+}
+
+// inject sleeps for the configured latency, then decides whether the
+// operation fails and whether its connection should be dropped.
+func (f FaultSpec) inject(ctx context.Context) (fail, drop bool, err error) {
+	if f.LatencyMs > 0 {
+		select {
+		case <-time.After(time.Duration(f.LatencyMs) * time.Millisecond):
+		case <-ctx.Done():
+			return false, false, ctx.Err()
+		}
+	}
+	return rand.Float64() < f.ErrorRate, rand.Float64() < f.DropRate, nil
+}
+
+// chaosRedisHook fails Redis commands according to the redis fault spec.
+// go-redis does not expose the connection to hooks, so drops surface as
+// errors too.
+type chaosRedisHook struct{}
+
+func (chaosRedisHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return ctx, injectRedisFault(ctx)
+}
+
+func (chaosRedisHook) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (chaosRedisHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, injectRedisFault(ctx)
+}
+
+func (chaosRedisHook) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+func injectRedisFault(ctx context.Context) error {
+	fail, drop, err := CurrentChaos().Redis.inject(ctx)
+	if err != nil {
+		return err
+	}
+	if fail || drop {
+		return ErrChaosInjected
+	}
+	return nil
+}
+
+// InjectMongoFault applies the mongo fault spec to one store operation,
+// returning ErrChaosInjected if it should fail. The dialer below calls it for
+// every connection; stores that do not dial, such as an in-memory
+// TodoRepository in tests, can call it per operation instead.
+func InjectMongoFault(ctx context.Context) error {
+	fail, drop, err := CurrentChaos().Mongo.inject(ctx)
+	if err != nil {
+		return err
+	}
+	if fail || drop {
+		return ErrChaosInjected
+	}
+	return nil
+}
+
+// chaosDialer hands the Mongo driver connections that fail according to the
+// mongo fault spec. The driver only sees network errors, the same as it
+// would from a flaky link.
+type chaosDialer struct {
+	net.Dialer
+}
+
+func (d *chaosDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := InjectMongoFault(ctx); err != nil {
+		return nil, err
+	}
+
+	conn, err := d.Dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &chaosConn{Conn: conn}, nil
+}
+
+type chaosConn struct {
+	net.Conn
+}
+
+func (c *chaosConn) Write(b []byte) (int, error) {
+	fail, drop, err := CurrentChaos().Mongo.inject(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	if drop {
+		c.Conn.Close()
+		return 0, net.ErrClosed
+	}
+	if fail {
+		return 0, ErrChaosInjected
+	}
+	return c.Conn.Write(b)
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// Now is the clock used for every timestamp written to todos. It can be
+// replaced, for example with FreezeClock, to make output deterministic.
+var Now = time.Now
+
+// FreezeClock pins Now to t.
+func FreezeClock(t time.Time) {
+	Now = func() time.Time {
+		return t
+	}
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// palette maps the named colors a todo can be given onto the terminal
+// colors the CLI shows them in.
+var palette = map[string]color.Attribute{
+	"red":    color.FgRed,
+	"orange": color.FgHiRed,
+	"yellow": color.FgYellow,
+	"green":  color.FgGreen,
+	"blue":   color.FgBlue,
+	"purple": color.FgMagenta,
+	"pink":   color.FgHiMagenta,
+	"gray":   color.FgHiBlack,
+}
+
+// PaletteNames lists the named colors, in the order they are documented.
+var PaletteNames = []string{"red", "orange", "yellow", "green", "blue", "purple", "pink", "gray"}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// NormalizeColor returns a color in the form it is stored: trimmed and
+// lowercased, so "#FFAA00" and "#ffaa00" are the same color.
+func NormalizeColor(c string) string {
+	return strings.ToLower(strings.TrimSpace(c))
+}
+
+// ValidColor reports whether c, once normalized, is a named color of the
+// palette or a #RRGGBB hex color.
+func ValidColor(c string) bool {
+	c = NormalizeColor(c)
+	_, named := palette[c]
+	return named || hexColor.MatchString(c)
+}
+
+// colorSwatch returns a colored marker for a named color, or "" for hex
+// colors and todos without one, which the terminal cannot show reliably.
+func colorSwatch(c string) string {
+	attr, ok := palette[c]
+	if !ok {
+		return ""
+	}
+	return color.New(attr).Sprint("● ")
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MinSecretKeyLength is the shortest SECRET_KEY accepted for signing tokens,
+// matching the 256 bits an HS256 key should carry.
+const MinSecretKeyLength = 32
+
+// Config is the effective configuration of a running instance. Fields with
+// an env tag are read from that variable; fields tagged secret:"true" are
+// redacted wherever the config is shown, so a new secret only needs the tag
+// to stay out of logs and diagnostics.
+type Config struct {
+	Environment          string   `json:"environment" env:"ENVIRONMENT"`
+	Port                 string   `json:"port" env:"PORT"`
+	Store                string   `json:"store"`
+	Cache                string   `json:"cache"`
+	AuthModes            []string `json:"auth_modes"`
+	DBURI                string   `json:"db_uri" env:"DB_URI" secret:"true"`
+	DBName               string   `json:"db_name" env:"DB_NAME"`
+	DBCollection         string   `json:"db_collection" env:"DB_COLLECTION_NAME"`
+	DBUsername           string   `json:"db_username" env:"DB_USERNAME"`
+	DBPassword           string   `json:"db_password" env:"DB_PASSWORD" secret:"true"`
+	RedisHost            string   `json:"redis_host" env:"REDIS_HOST"`
+	RedisPort            string   `json:"redis_port" env:"REDIS_PORT"`
+	SecretKey            string   `json:"secret_key" env:"SECRET_KEY" secret:"true"`
+	BasicAuthUsername    string   `json:"basicauth_admin_username" env:"BASICAUTH_ADMIN_USERNAME"`
+	BasicAuthPassword    string   `json:"basicauth_admin_password" env:"BASICAUTH_ADMIN_PASSWORD" secret:"true"`
+	StaleAfterDays       string   `json:"stale_after_days" env:"STALE_AFTER_DAYS"`
+	UndoWindow           string   `json:"undo_window" env:"UNDO_WINDOW"`
+	SlowRequestThreshold string   `json:"slow_request_threshold" env:"SLOW_REQUEST_THRESHOLD"`
+	SlowRouteThresholds  string   `json:"slow_request_route_thresholds" env:"SLOW_REQUEST_ROUTE_THRESHOLDS"`
+	MongoOpTimeoutFloor  string   `json:"mongo_op_timeout_floor" env:"MONGO_OP_TIMEOUT_FLOOR"`
+	MongoOpTimeoutCeil   string   `json:"mongo_op_timeout_ceiling" env:"MONGO_OP_TIMEOUT_CEILING"`
+	RejectDuplicates     string   `json:"reject_duplicate_todos" env:"REJECT_DUPLICATE_TODOS"`
+	WorkloadCapacity     string   `json:"workload_capacity_minutes" env:"WORKLOAD_CAPACITY_MINUTES"`
+	WorkloadEstimate     string   `json:"workload_default_estimate_minutes" env:"WORKLOAD_DEFAULT_ESTIMATE_MINUTES"`
+	ChaosMode            bool     `json:"chaos_mode"`
+}
+
+// RedactedValue stands in for a secret in Config.Redacted.
+type RedactedValue struct {
+	Redacted bool `json:"redacted"`
+	Set      bool `json:"set"`
+}
+
+// LoadConfig reads the configuration from the environment.
+func LoadConfig() Config {
+	var cfg Config
+	v := reflect.ValueOf(&cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Tag.Get("env"); name != "" {
+			v.Field(i).SetString(os.Getenv(name))
+		}
+	}
+
+	cfg.Store = "mongodb"
+	cfg.Cache = "redis"
+	cfg.AuthModes = []string{"jwt"}
+	if cfg.Environment != "production" {
+		cfg.AuthModes = append(cfg.AuthModes, "basic (swagger)")
+	}
+	cfg.ChaosMode = ChaosEnabled()
+	return cfg
+}
+
+// Validate reports every setting the server must not start with, joined
+// into one error. In production SECRET_KEY must be long enough to sign
+// tokens; elsewhere the basic-auth credentials guarding the swagger routes
+// must be set.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Environment == "production" {
+		if len(c.SecretKey) < MinSecretKeyLength {
+			errs = append(errs, fmt.Errorf("SECRET_KEY must be at least %d characters long in production, got %d", MinSecretKeyLength, len(c.SecretKey)))
+		}
+	} else {
+		if c.BasicAuthUsername == "" {
+			errs = append(errs, errors.New("BASICAUTH_ADMIN_USERNAME must not be empty"))
+		}
+		if c.BasicAuthPassword == "" {
+			errs = append(errs, errors.New("BASICAUTH_ADMIN_PASSWORD must not be empty"))
+		}
+	}
+	if _, _, err := StaleAfter(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := undoWindow(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := opTimeoutsFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, _, err := WorkloadSettings(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.RejectDuplicates != "" {
+		if _, err := strconv.ParseBool(c.RejectDuplicates); err != nil {
+			errs = append(errs, fmt.Errorf("REJECT_DUPLICATE_TODOS must be true or false, got %q", c.RejectDuplicates))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns the config keyed by JSON name, with every secret field
+// replaced by a RedactedValue.
+func (c Config) Redacted() map[string]interface{} {
+	return redact(c)
+}
+
+// Summary renders the redacted config as a single key=value line, in field
+// order, for the startup log.
+func (c Config) Summary() string {
+	return summarize(c)
+}
+
+// redact returns the fields of the struct cfg keyed by JSON name, with every
+// field tagged secret:"true" replaced by a RedactedValue.
+func redact(cfg interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	v := reflect.ValueOf(cfg)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Tag.Get("secret") == "true" {
+			out[name] = RedactedValue{Redacted: true, Set: !v.Field(i).IsZero()}
+			continue
+		}
+		out[name] = v.Field(i).Interface()
+	}
+	return out
+}
+
+// summarize renders redact(cfg) as key=value pairs in field order.
+func summarize(cfg interface{}) string {
+	redacted := redact(cfg)
+	t := reflect.TypeOf(cfg)
+	parts := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		value := redacted[name]
+		if r, ok := value.(RedactedValue); ok {
+			value = "[redacted]"
+			if !r.Set {
+				value = "[unset]"
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%s=%q", name, fmt.Sprint(value)))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactSecretField(t *testing.T) {
+	type config struct {
+		Region string `json:"region"`
+		APIKey string `json:"api_key" secret:"true"`
+		Token  string `json:"token" secret:"true"`
+	}
+	cfg := config{Region: "eu-west-1", APIKey: "sk-live-1234"}
+
+	redacted := redact(cfg)
+	if redacted["region"] != "eu-west-1" {
+		t.Errorf("region = %v, want it shown", redacted["region"])
+	}
+	if got := redacted["api_key"]; got != (RedactedValue{Redacted: true, Set: true}) {
+		t.Errorf("api_key = %v, want it redacted and set", got)
+	}
+	if got := redacted["token"]; got != (RedactedValue{Redacted: true, Set: false}) {
+		t.Errorf("token = %v, want it redacted and unset", got)
+	}
+
+	summary := summarize(cfg)
+	if strings.Contains(summary, cfg.APIKey) {
+		t.Errorf("summary %q shows the secret", summary)
+	}
+	for _, want := range []string{`region="eu-west-1"`, `api_key="[redacted]"`, `token="[unset]"`} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q does not contain %s", summary, want)
+		}
+	}
+}
+
+func TestConfigRedactsSecrets(t *testing.T) {
+	var cfg Config
+	v := reflect.ValueOf(&cfg).Elem()
+	var secrets []string
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("secret") == "true" {
+			secret := "hunter2-" + v.Type().Field(i).Name
+			v.Field(i).SetString(secret)
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) == 0 {
+		t.Fatal("Config has no secret fields")
+	}
+
+	dump, err := json.Marshal(cfg.Redacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := cfg.Summary()
+	for _, secret := range secrets {
+		if strings.Contains(string(dump), secret) {
+			t.Errorf("Redacted() shows %q: %s", secret, dump)
+		}
+		if strings.Contains(summary, secret) {
+			t.Errorf("Summary() shows %q: %s", secret, summary)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	key := strings.Repeat("k", MinSecretKeyLength)
+	valid := map[string]string{
+		"ENVIRONMENT":                       "development",
+		"SECRET_KEY":                        "",
+		"BASICAUTH_ADMIN_USERNAME":          "admin",
+		"BASICAUTH_ADMIN_PASSWORD":          "hunter2",
+		"STALE_AFTER_DAYS":                  "",
+		"UNDO_WINDOW":                       "",
+		"MONGO_OP_TIMEOUT_FLOOR":            "",
+		"MONGO_OP_TIMEOUT_CEILING":          "",
+		"WORKLOAD_CAPACITY_MINUTES":         "",
+		"WORKLOAD_DEFAULT_ESTIMATE_MINUTES": "",
+		"REJECT_DUPLICATE_TODOS":            "",
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		// want is part of the error, empty for a valid config.
+		want string
+	}{
+		{"defaults", nil, ""},
+		{"production", map[string]string{"ENVIRONMENT": "production", "SECRET_KEY": key, "BASICAUTH_ADMIN_USERNAME": "", "BASICAUTH_ADMIN_PASSWORD": ""}, ""},
+		{"all set", map[string]string{"STALE_AFTER_DAYS": "30", "UNDO_WINDOW": "1m", "MONGO_OP_TIMEOUT_FLOOR": "50ms", "MONGO_OP_TIMEOUT_CEILING": "1m", "WORKLOAD_CAPACITY_MINUTES": "360", "WORKLOAD_DEFAULT_ESTIMATE_MINUTES": "0", "REJECT_DUPLICATE_TODOS": "true"}, ""},
+		{"empty secret in production", map[string]string{"ENVIRONMENT": "production"}, "SECRET_KEY"},
+		{"short secret in production", map[string]string{"ENVIRONMENT": "production", "SECRET_KEY": key[1:]}, "SECRET_KEY"},
+		{"empty basic auth username", map[string]string{"BASICAUTH_ADMIN_USERNAME": ""}, "BASICAUTH_ADMIN_USERNAME"},
+		{"empty basic auth password", map[string]string{"BASICAUTH_ADMIN_PASSWORD": ""}, "BASICAUTH_ADMIN_PASSWORD"},
+		{"zero stale days", map[string]string{"STALE_AFTER_DAYS": "0"}, "STALE_AFTER_DAYS"},
+		{"stale days not a number", map[string]string{"STALE_AFTER_DAYS": "soon"}, "STALE_AFTER_DAYS"},
+		{"undo window without unit", map[string]string{"UNDO_WINDOW": "30"}, "UNDO_WINDOW"},
+		{"negative undo window", map[string]string{"UNDO_WINDOW": "-1s"}, "UNDO_WINDOW"},
+		{"op timeout floor not a duration", map[string]string{"MONGO_OP_TIMEOUT_FLOOR": "fast"}, "MONGO_OP_TIMEOUT_FLOOR"},
+		{"zero op timeout ceiling", map[string]string{"MONGO_OP_TIMEOUT_CEILING": "0s"}, "MONGO_OP_TIMEOUT_CEILING"},
+		{"op timeout floor above ceiling", map[string]string{"MONGO_OP_TIMEOUT_FLOOR": "2s", "MONGO_OP_TIMEOUT_CEILING": "1s"}, "is above MONGO_OP_TIMEOUT_CEILING"},
+		{"zero workload capacity", map[string]string{"WORKLOAD_CAPACITY_MINUTES": "0"}, "WORKLOAD_CAPACITY_MINUTES"},
+		{"negative default estimate", map[string]string{"WORKLOAD_DEFAULT_ESTIMATE_MINUTES": "-5"}, "WORKLOAD_DEFAULT_ESTIMATE_MINUTES"},
+		{"reject duplicates not a boolean", map[string]string{"REJECT_DUPLICATE_TODOS": "maybe"}, "REJECT_DUPLICATE_TODOS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range valid {
+				t.Setenv(name, value)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			err := LoadConfig().Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("Validate() = nil, want an error about %s", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("Validate() = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigReportsEveryError(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+	t.Setenv("SECRET_KEY", "")
+	t.Setenv("STALE_AFTER_DAYS", "-1")
+	t.Setenv("UNDO_WINDOW", "later")
+
+	err := LoadConfig().Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want errors")
+	}
+	for _, want := range []string{"SECRET_KEY", "STALE_AFTER_DAYS", "UNDO_WINDOW"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want it to report %s", err, want)
+		}
+	}
+}
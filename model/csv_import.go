@@ -0,0 +1,386 @@
+package model
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// importBatchSize is the number of parsed rows inserted per InsertMany.
+const importBatchSize = 500
+
+// ImportFields are the todo fields a CSV column can be mapped onto.
+var ImportFields = []string{"text", "completed", "created_at", "due_date", "tags", "project"}
+
+// Imported tags and projects are held to the limits the API binds them to.
+const (
+	maxImportTags       = 20
+	maxImportTagLength  = 32
+	maxImportProjectLen = 64
+)
+
+var importDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"01/02/2006",
+	"2 Jan 2006",
+	"Jan 2, 2006",
+}
+
+type ImportRowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e *ImportRowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+type ImportResult struct {
+	Imported    int               `json:"imported"`
+	Errors      []*ImportRowError `json:"errors"`
+	Interrupted bool              `json:"interrupted,omitempty"`
+}
+
+// CSVReader turns the rows of a CSV file with a header row into todos, one
+// row at a time, according to a mapping of todo fields to column names.
+type CSVReader struct {
+	reader  *csv.Reader
+	header  []string
+	columns map[string]int
+}
+
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty, a header row is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &CSVReader{reader: reader, header: header}, nil
+}
+
+func (r *CSVReader) Header() []string {
+	return r.header
+}
+
+// ParseColumnMapping parses a "field=Column,field=Column" mapping.
+func ParseColumnMapping(spec string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(spec, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		column = strings.TrimSpace(column)
+		if !ok || field == "" || column == "" {
+			return nil, fmt.Errorf("invalid mapping %q, expected field=Column", pair)
+		}
+		mapping[field] = column
+	}
+
+	return mapping, nil
+}
+
+// DefaultColumnMapping maps every import field onto the column of the same
+// name, ignoring case, when there is one.
+func (r *CSVReader) DefaultColumnMapping() map[string]string {
+	mapping := map[string]string{}
+	for _, field := range ImportFields {
+		for _, column := range r.header {
+			if strings.EqualFold(strings.TrimSpace(column), field) {
+				mapping[field] = column
+			}
+		}
+	}
+
+	return mapping
+}
+
+// SetMapping resolves mapping against the header. The text field is
+// required, and every field and column must exist.
+func (r *CSVReader) SetMapping(mapping map[string]string) error {
+	if _, ok := mapping["text"]; !ok {
+		return errors.New("a column must be mapped to text")
+	}
+
+	columns := map[string]int{}
+	for field, column := range mapping {
+		if !isImportField(field) {
+			return fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(ImportFields, ", "))
+		}
+
+		index := -1
+		for i, name := range r.header {
+			if strings.TrimSpace(name) == column {
+				index = i
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("column %q not found in header", column)
+		}
+		columns[field] = index
+	}
+
+	r.columns = columns
+	return nil
+}
+
+// Line returns the line number of the row most recently read by Next.
+func (r *CSVReader) Line() int {
+	line, _ := r.reader.FieldPos(0)
+	return line
+}
+
+// Next returns the todo parsed from the next row and io.EOF once the file is
+// exhausted. Rows that fail validation are reported as an *ImportRowError,
+// after which reading can continue.
+func (r *CSVReader) Next() (*Todo, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &ImportRowError{Line: parseErr.Line, Message: parseErr.Err.Error()}
+		}
+		return nil, err
+	}
+	line := r.Line()
+
+	now := Now()
+	todo := &Todo{
+		ID:        primitive.NewObjectID(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	value := func(field string) (string, bool) {
+		index, ok := r.columns[field]
+		if !ok || index >= len(record) {
+			return "", false
+		}
+		return strings.TrimSpace(record[index]), true
+	}
+
+	text, _ := value("text")
+	todo.Text = NormalizeText(text)
+	if todo.Text == "" {
+		return nil, &ImportRowError{Line: line, Message: "text is empty"}
+	}
+
+	if raw, ok := value("completed"); ok {
+		completed, err := parseImportBool(raw)
+		if err != nil {
+			return nil, &ImportRowError{Line: line, Message: err.Error()}
+		}
+		todo.Completed = completed
+	}
+
+	if raw, ok := value("created_at"); ok && raw != "" {
+		createdAt, err := parseImportDate(raw)
+		if err != nil {
+			return nil, &ImportRowError{Line: line, Message: err.Error()}
+		}
+		todo.CreatedAt = createdAt
+	}
+
+	if raw, ok := value("due_date"); ok && raw != "" {
+		due, err := parseImportDate(raw)
+		if err != nil {
+			return nil, &ImportRowError{Line: line, Message: err.Error()}
+		}
+		todo.DueDate = &due
+	}
+
+	if raw, ok := value("tags"); ok {
+		todo.Tags = NormalizeTags(strings.FieldsFunc(raw, func(r rune) bool {
+			return r == ',' || r == ';'
+		}))
+		if len(todo.Tags) > maxImportTags {
+			return nil, &ImportRowError{Line: line, Message: fmt.Sprintf("%d tags, at most %d are allowed", len(todo.Tags), maxImportTags)}
+		}
+		for _, tag := range todo.Tags {
+			if len(tag) > maxImportTagLength {
+				return nil, &ImportRowError{Line: line, Message: fmt.Sprintf("tag %q is longer than %d characters", tag, maxImportTagLength)}
+			}
+		}
+	}
+
+	if raw, ok := value("project"); ok {
+		todo.Project = NormalizeProject(raw)
+		if len(todo.Project) > maxImportProjectLen {
+			return nil, &ImportRowError{Line: line, Message: fmt.Sprintf("project is longer than %d characters", maxImportProjectLen)}
+		}
+	}
+
+	return todo, nil
+}
+
+type ImportOptions struct {
+	// DryRun validates every row without writing anything.
+	DryRun bool
+	// Concurrency is the number of batches inserted in parallel.
+	Concurrency int
+}
+
+type importBatch struct {
+	todos []*Todo
+	lines []int
+}
+
+// ImportTodos reads every row from r and inserts the valid ones into todos in
+// batches, spread over opts.Concurrency workers, collecting row errors rather than
+// stopping at the first one. Errors are reported in line order.
+//
+// Cancelling ctx stops reading further rows; batches already handed to a
+// worker are still written, and the result is marked Interrupted.
+func ImportTodos(ctx context.Context, todos TodoRepository, r *CSVReader, opts ImportOptions) (*ImportResult, error) {
+	result := &ImportResult{Errors: []*ImportRowError{}}
+	var mu sync.Mutex
+
+	readCtx, fail := context.WithCancelCause(ctx)
+	defer fail(nil)
+	writeCtx := context.WithoutCancel(ctx)
+
+	batches := make(chan importBatch)
+	var wg sync.WaitGroup
+	for range max(opts.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				imported, rowErrs, err := insertImportBatch(writeCtx, todos, b, opts.DryRun)
+				if err != nil {
+					fail(err)
+				}
+
+				mu.Lock()
+				result.Imported += imported
+				result.Errors = append(result.Errors, rowErrs...)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	batch := importBatch{}
+	dispatch := func() bool {
+		if len(batch.todos) == 0 {
+			return true
+		}
+		select {
+		case batches <- batch:
+			batch = importBatch{}
+			return true
+		case <-readCtx.Done():
+			return false
+		}
+	}
+
+	var readErr error
+	for readCtx.Err() == nil {
+		todo, err := r.Next()
+		if err == io.EOF {
+			dispatch()
+			break
+		}
+
+		var rowErr *ImportRowError
+		if errors.As(err, &rowErr) {
+			mu.Lock()
+			result.Errors = append(result.Errors, rowErr)
+			mu.Unlock()
+			continue
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+
+		batch.todos = append(batch.todos, todo)
+		batch.lines = append(batch.lines, r.Line())
+		if len(batch.todos) == importBatchSize && !dispatch() {
+			break
+		}
+	}
+
+	close(batches)
+	wg.Wait()
+
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Line < result.Errors[j].Line
+	})
+	result.Interrupted = ctx.Err() != nil
+
+	if readErr != nil {
+		return result, readErr
+	}
+	if cause := context.Cause(readCtx); cause != nil && !result.Interrupted {
+		return result, cause
+	}
+	return result, nil
+}
+
+// insertImportBatch writes a batch, attributing individual insert failures
+// to the line each todo was read from.
+func insertImportBatch(ctx context.Context, todos TodoRepository, b importBatch, dryRun bool) (int, []*ImportRowError, error) {
+	if dryRun {
+		return len(b.todos), nil, nil
+	}
+
+	err := todos.CreateMany(ctx, b.todos)
+	if err == nil {
+		return len(b.todos), nil, nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return 0, nil, err
+	}
+
+	rowErrs := make([]*ImportRowError, len(bulkErr.WriteErrors))
+	for i, writeErr := range bulkErr.WriteErrors {
+		rowErrs[i] = &ImportRowError{Line: b.lines[writeErr.Index], Message: writeErr.Message}
+	}
+	return len(b.todos) - len(rowErrs), rowErrs, nil
+}
+
+func isImportField(field string) bool {
+	for _, f := range ImportFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func parseImportBool(raw string) (bool, error) {
+	switch strings.ToLower(raw) {
+	case "yes", "y", "x", "true", "1", "done":
+		return true, nil
+	case "no", "n", "false", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("cannot parse %q as a boolean", raw)
+}
+
+func parseImportDate(raw string) (time.Time, error) {
+	for _, layout := range importDateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a date", raw)
+}
@@ -0,0 +1,293 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestCSVReaderFields(t *testing.T) {
+	var tags []string
+	for i := range maxImportTags + 1 {
+		tags = append(tags, fmt.Sprintf("tag%d", i))
+	}
+	manyTags := strings.Join(tags, ",")
+	file := `Task,Done,Due,Labels,Area
+Renew passport,no,2026-03-01,"Travel; Admin",Personal
+File taxes,x,04/15/2026,"finance,admin,finance",
+Paint fence,,,,
+Book flights,maybe,,,
+Water plants,no,next week,,
+Plan trip,no,,"` + manyTags + `",
+Clean garage,no,,` + strings.Repeat("x", maxImportTagLength+1) + `,
+Fix bike,no,,,` + strings.Repeat("x", maxImportProjectLen+1) + `
+,no,2026-03-01,,
+`
+	r, err := NewCSVReader(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := ParseColumnMapping("text=Task,completed=Done,due_date=Due,tags=Labels,project=Area")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetMapping(mapping); err != nil {
+		t.Fatal(err)
+	}
+
+	var todos []*Todo
+	var lines []int
+	for {
+		todo, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *ImportRowError
+		if errors.As(err, &rowErr) {
+			lines = append(lines, rowErr.Line)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		todos = append(todos, todo)
+	}
+
+	if len(todos) != 3 {
+		t.Fatalf("parsed %d todos, want 3", len(todos))
+	}
+	passport, taxes, fence := todos[0], todos[1], todos[2]
+
+	if want := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC); passport.DueDate == nil || !passport.DueDate.Equal(want) {
+		t.Errorf("due date = %v, want %v", passport.DueDate, want)
+	}
+	if want := []string{"travel", "admin"}; !slices.Equal(passport.Tags, want) {
+		t.Errorf("tags = %v, want %v", passport.Tags, want)
+	}
+	if passport.Project != "personal" {
+		t.Errorf("project = %q, want personal", passport.Project)
+	}
+
+	if want := time.Date(2026, time.April, 15, 0, 0, 0, 0, time.UTC); taxes.DueDate == nil || !taxes.DueDate.Equal(want) {
+		t.Errorf("due date = %v, want %v", taxes.DueDate, want)
+	}
+	if want := []string{"finance", "admin"}; !slices.Equal(taxes.Tags, want) {
+		t.Errorf("tags = %v, want %v", taxes.Tags, want)
+	}
+	if !taxes.Completed || taxes.Project != "" {
+		t.Errorf("got completed %v and project %q, want completed and no project", taxes.Completed, taxes.Project)
+	}
+
+	if fence.DueDate != nil || fence.Tags != nil || fence.Project != "" {
+		t.Errorf("empty cells gave due date %v, tags %v and project %q", fence.DueDate, fence.Tags, fence.Project)
+	}
+
+	if want := []int{5, 6, 7, 8, 9, 10}; !slices.Equal(lines, want) {
+		t.Errorf("row errors on lines %v, want %v", lines, want)
+	}
+}
+
+func TestDefaultColumnMapping(t *testing.T) {
+	r, err := NewCSVReader(strings.NewReader("Text,Due_Date,TAGS,Project,Notes\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := r.DefaultColumnMapping()
+	for field, column := range map[string]string{"text": "Text", "due_date": "Due_Date", "tags": "TAGS", "project": "Project"} {
+		if mapping[field] != column {
+			t.Errorf("%s is mapped to %q, want %q", field, mapping[field], column)
+		}
+	}
+	if len(mapping) != 4 {
+		t.Errorf("got mapping %v, want only the four matching columns", mapping)
+	}
+}
+
+// faultyRepository inserts batches into the memory repository it wraps after
+// delay, rejecting the todos whose text contains "reject" as duplicates the
+// way InsertMany reports them, and failing whole batches with err once set.
+// It records how many batches were in flight at once.
+type faultyRepository struct {
+	*MemoryRepository
+	delay   time.Duration
+	onWrite func()
+
+	mu          sync.Mutex
+	err         error
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (r *faultyRepository) CreateMany(ctx context.Context, todos []*Todo) error {
+	n := r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	for {
+		seen := r.maxInFlight.Load()
+		if n <= seen || r.maxInFlight.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	if r.onWrite != nil {
+		r.onWrite()
+	}
+
+	select {
+	case <-time.After(r.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	r.mu.Lock()
+	err := r.err
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var accepted []*Todo
+	var bulkErr mongo.BulkWriteException
+	for i, todo := range todos {
+		if strings.Contains(todo.Text, "reject") {
+			bulkErr.WriteErrors = append(bulkErr.WriteErrors, mongo.BulkWriteError{
+				WriteError: mongo.WriteError{Index: i, Code: duplicateKeyCode, Message: ErrDuplicateTodo.Error()},
+			})
+			continue
+		}
+		accepted = append(accepted, todo)
+	}
+	if err := r.MemoryRepository.CreateMany(ctx, accepted); err != nil {
+		return err
+	}
+	if len(bulkErr.WriteErrors) > 0 {
+		return bulkErr
+	}
+	return nil
+}
+
+func (r *faultyRepository) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// importFile returns a CSV file of rows todos, every rejectEvery-th of them
+// rejected by faultyRepository, along with the lines of those.
+func importFile(rows int, rejectEvery int) (string, []int) {
+	var file strings.Builder
+	var rejected []int
+	file.WriteString("text\n")
+	for i := range rows {
+		if rejectEvery > 0 && i%rejectEvery == 0 {
+			fmt.Fprintf(&file, "reject %d\n", i)
+			rejected = append(rejected, i+2)
+			continue
+		}
+		fmt.Fprintf(&file, "todo %d\n", i)
+	}
+	return file.String(), rejected
+}
+
+func readImport(t *testing.T, file string) *CSVReader {
+	t.Helper()
+
+	r, err := NewCSVReader(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetMapping(r.DefaultColumnMapping()); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// storedTodos counts the todos in repo.
+func storedTodos(t *testing.T, repo TodoRepository) int64 {
+	t.Helper()
+
+	_, total, err := repo.List(context.Background(), TodoQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
+
+func TestImportTodosInParallel(t *testing.T) {
+	const rows = 5*importBatchSize + 120
+	file, rejected := importFile(rows, 97)
+	repo := &faultyRepository{MemoryRepository: NewMemoryRepository(), delay: 50 * time.Millisecond}
+
+	result, err := ImportTodos(context.Background(), repo, readImport(t, file), ImportOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := rows - len(rejected); result.Imported != want || storedTodos(t, repo) != int64(want) {
+		t.Errorf("imported %d, stored %d, want %d", result.Imported, storedTodos(t, repo), want)
+	}
+	lines := make([]int, len(result.Errors))
+	for i, rowErr := range result.Errors {
+		lines[i] = rowErr.Line
+	}
+	if !slices.Equal(lines, rejected) {
+		t.Errorf("errors on lines %v, want %v in order", lines, rejected)
+	}
+	if got := repo.maxInFlight.Load(); got < 2 || got > 3 {
+		t.Errorf("%d batches written at once, want 2 or 3 with a concurrency of 3", got)
+	}
+	if result.Interrupted {
+		t.Error("import marked interrupted, want it complete")
+	}
+}
+
+func TestImportTodosStoreFailure(t *testing.T) {
+	file, _ := importFile(10*importBatchSize, 0)
+	repo := &faultyRepository{MemoryRepository: NewMemoryRepository(), delay: 10 * time.Millisecond}
+	down := errors.New("connection reset")
+	repo.fail(down)
+
+	result, err := ImportTodos(context.Background(), repo, readImport(t, file), ImportOptions{Concurrency: 2})
+	if !errors.Is(err, down) {
+		t.Fatalf("ImportTodos with the store down: got %v, want %v", err, down)
+	}
+	if result.Imported != 0 || storedTodos(t, repo) != 0 {
+		t.Errorf("imported %d with the store down, want none", result.Imported)
+	}
+	if result.Interrupted {
+		t.Error("import with the store down marked interrupted, want only the error")
+	}
+}
+
+func TestImportTodosInterrupted(t *testing.T) {
+	const rows = 10 * importBatchSize
+	file, _ := importFile(rows, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := &faultyRepository{MemoryRepository: NewMemoryRepository(), delay: 50 * time.Millisecond, onWrite: cancel}
+
+	result, err := ImportTodos(ctx, repo, readImport(t, file), ImportOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Interrupted {
+		t.Error("import cancelled midway not marked interrupted")
+	}
+	// Batches handed to a worker before the cancellation are still written
+	// in full; the rest of the file is skipped.
+	if result.Imported == 0 || result.Imported >= rows || result.Imported%importBatchSize != 0 {
+		t.Errorf("imported %d of %d, want the whole batches in flight when cancelled", result.Imported, rows)
+	}
+	if stored := storedTodos(t, repo); stored != int64(result.Imported) {
+		t.Errorf("stored %d todos, want the %d reported imported", stored, result.Imported)
+	}
+}
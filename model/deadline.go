@@ -0,0 +1,128 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrDeadline is returned when a database operation ran out of the time left
+// on its context, whether the driver gave up or the server killed it.
+var ErrDeadline = errors.New("database operation exceeded its deadline")
+
+// maxTimeMSExpired is the server error code for an operation killed by
+// maxTimeMS.
+const maxTimeMSExpired = 50
+
+const (
+	defaultOpTimeoutFloor   = 10 * time.Millisecond
+	defaultOpTimeoutCeiling = 5 * time.Minute
+)
+
+// OpTimeouts bounds the server-side time limit derived from a context
+// deadline. The floor keeps a nearly-expired request from sending a limit
+// the server rounds to "no limit"; the ceiling caps requests with long
+// deadlines.
+type OpTimeouts struct {
+	Floor   time.Duration
+	Ceiling time.Duration
+}
+
+var opTimeouts = OpTimeouts{Floor: defaultOpTimeoutFloor, Ceiling: defaultOpTimeoutCeiling}
+
+// loadOpTimeouts sets the bounds from the environment.
+func loadOpTimeouts() error {
+	t, err := opTimeoutsFromEnv()
+	if err != nil {
+		return err
+	}
+
+	opTimeouts = t
+	return nil
+}
+
+// opTimeoutsFromEnv reads MONGO_OP_TIMEOUT_FLOOR and
+// MONGO_OP_TIMEOUT_CEILING, both Go durations, keeping the defaults for
+// unset variables.
+func opTimeoutsFromEnv() (OpTimeouts, error) {
+	t := OpTimeouts{Floor: defaultOpTimeoutFloor, Ceiling: defaultOpTimeoutCeiling}
+	for name, dst := range map[string]*time.Duration{
+		"MONGO_OP_TIMEOUT_FLOOR":   &t.Floor,
+		"MONGO_OP_TIMEOUT_CEILING": &t.Ceiling,
+	} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return t, fmt.Errorf("%s must be a positive duration, got %q", name, raw)
+		}
+		*dst = d
+	}
+	if t.Floor > t.Ceiling {
+		return t, fmt.Errorf("MONGO_OP_TIMEOUT_FLOOR %s is above MONGO_OP_TIMEOUT_CEILING %s", t.Floor, t.Ceiling)
+	}
+	return t, nil
+}
+
+// opBudget returns the time left on ctx clamped to the configured floor and
+// ceiling. ok is false when ctx has no deadline, in which case operations
+// run unbounded as before.
+func opBudget(ctx context.Context) (budget time.Duration, ok bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+
+	return min(max(time.Until(deadline), opTimeouts.Floor), opTimeouts.Ceiling), true
+}
+
+// findOptions returns find options carrying maxTimeMS for the time left on
+// ctx, so the server stops working on the query when the caller stops
+// waiting for it.
+func findOptions(ctx context.Context) *options.FindOptions {
+	opts := options.Find()
+	if budget, ok := opBudget(ctx); ok {
+		opts.SetMaxTime(budget)
+	}
+	return opts
+}
+
+// aggregateOptions is findOptions for aggregations.
+func aggregateOptions(ctx context.Context) *options.AggregateOptions {
+	opts := options.Aggregate()
+	if budget, ok := opBudget(ctx); ok {
+		opts.SetMaxTime(budget)
+	}
+	return opts
+}
+
+// writeContext bounds a write by the time left on ctx, clamped the same way
+// as reads. Writes have no maxTimeMS of their own in this driver version.
+func writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if budget, ok := opBudget(ctx); ok {
+		return context.WithTimeout(ctx, budget)
+	}
+	return ctx, func() {}
+}
+
+// deadlineError wraps err in ErrDeadline when the operation timed out on
+// either side, and returns it unchanged otherwise.
+func deadlineError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var serverErr mongo.ServerError
+	if mongo.IsTimeout(err) || errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &serverErr) && serverErr.HasErrorCode(maxTimeMSExpired)) {
+		return fmt.Errorf("%w: %v", ErrDeadline, err)
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestFindOptionsCarryDeadline(t *testing.T) {
+	if opts := findOptions(context.Background()); opts.MaxTime != nil {
+		t.Errorf("maxTimeMS without a deadline = %s, want none", *opts.MaxTime)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if opts := findOptions(ctx); opts.MaxTime == nil || *opts.MaxTime > 2*time.Second || *opts.MaxTime < time.Second {
+		t.Errorf("maxTimeMS with 2s left = %v, want about 2s", opts.MaxTime)
+	}
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	if opts := aggregateOptions(expired); opts.MaxTime == nil || *opts.MaxTime != opTimeouts.Floor {
+		t.Errorf("maxTimeMS past the deadline = %v, want the floor %s", opts.MaxTime, opTimeouts.Floor)
+	}
+
+	long, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if opts := findOptions(long); opts.MaxTime == nil || *opts.MaxTime != opTimeouts.Ceiling {
+		t.Errorf("maxTimeMS with an hour left = %v, want the ceiling %s", opts.MaxTime, opTimeouts.Ceiling)
+	}
+}
+
+// runningOps counts the operations on the collection of repo still running
+// a $where filter.
+func runningOps(t *testing.T, repo *MongoRepository) int {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cur, err := repo.Database().Client().Database("admin").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$currentOp", Value: bson.M{}}},
+		{{Key: "$match", Value: bson.M{"ns": repo.Namespace(), "command.filter.$where": bson.M{"$exists": true}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []bson.M
+	if err := cur.All(ctx, &ops); err != nil {
+		t.Fatal(err)
+	}
+	return len(ops)
+}
+
+func TestDeadlineKillsServerOperation(t *testing.T) {
+	repo := useTestDatabase(t)
+	ctx := context.Background()
+
+	todos := make([]*Todo, 100)
+	for i := range todos {
+		todos[i] = &Todo{ID: primitive.NewObjectID(), CreatedAt: Now(), UpdatedAt: Now(), Text: fmt.Sprintf("todo %d", i)}
+	}
+	if err := repo.CreateMany(ctx, todos); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.coll.FindOne(ctx, bson.M{"$where": "sleep(1); return true;"}).Err(); err != nil {
+		t.Skipf("server-side JavaScript is unavailable: %v", err)
+	}
+
+	// Sleeping 100ms per todo keeps the find busy for 10s on the server,
+	// long after the caller gives up at 200ms.
+	reqCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	cur, err := repo.coll.Find(reqCtx, bson.M{"$where": "sleep(100); return true;"}, findOptions(reqCtx))
+	if err == nil {
+		err = cur.All(reqCtx, &[]bson.M{})
+	}
+	if err = deadlineError(err); !errors.Is(err, ErrDeadline) {
+		t.Fatalf("slow find past its deadline: got %v, want ErrDeadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("slow find returned after %s, want about its 200ms deadline", elapsed)
+	}
+
+	// maxTimeMS makes the server kill the find too, rather than leave it
+	// running after the client went away.
+	for deadline := time.Now().Add(2 * time.Second); runningOps(t, repo) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("the find was still running on the server 2s after its deadline")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
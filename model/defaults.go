@@ -0,0 +1,161 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProjectDefaults override the defaults of a user for the new todos of one
+// project. Nil fields fall back to the user's defaults, while empty tags
+// leave the todos untagged.
+type ProjectDefaults struct {
+	Project   string   `json:"project" bson:"project" example:"work"`
+	Tags      []string `json:"tags" bson:"tags" binding:"max=20,dive,max=32" example:"office"`
+	DueInDays *int     `json:"due_in_days,omitempty" bson:"due_in_days,omitempty" binding:"omitempty,gte=0,lte=3650" example:"2"`
+}
+
+// Defaults are the values the new todos of a user take for the fields
+// their request leaves out.
+type Defaults struct {
+	Owner string   `json:"-" bson:"_id"`
+	Tags  []string `json:"tags,omitempty" bson:"tags,omitempty" example:"inbox"`
+	// DueInDays sets the due date that many days after creation.
+	DueInDays *int              `json:"due_in_days,omitempty" bson:"due_in_days,omitempty" example:"7"`
+	Project   string            `json:"project,omitempty" bson:"project,omitempty" example:"work"`
+	Projects  []ProjectDefaults `json:"projects" bson:"projects"`
+	UpdatedAt *time.Time        `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+func (r *MongoRepository) defaultsCollection() *mongo.Collection {
+	return r.coll.Database().Collection("todo_defaults")
+}
+
+func normalizeDefaults(d *Defaults) {
+	d.Tags = NormalizeTags(d.Tags)
+	d.Project = NormalizeProject(d.Project)
+	for i := range d.Projects {
+		d.Projects[i].Project = NormalizeProject(d.Projects[i].Project)
+		if d.Projects[i].Tags != nil {
+			d.Projects[i].Tags = append([]string{}, NormalizeTags(d.Projects[i].Tags)...)
+		}
+	}
+	if d.Projects == nil {
+		d.Projects = []ProjectDefaults{}
+	}
+}
+
+// ForProject returns the overrides of project, nil if it has none.
+func (d *Defaults) ForProject(project string) *ProjectDefaults {
+	i := slices.IndexFunc(d.Projects, func(p ProjectDefaults) bool { return p.Project == NormalizeProject(project) })
+	if i < 0 {
+		return nil
+	}
+	return &d.Projects[i]
+}
+
+// SetProject overrides the defaults of p.Project, replacing any earlier
+// overrides of it.
+func (d *Defaults) SetProject(p ProjectDefaults) {
+	p.Project = NormalizeProject(p.Project)
+	if existing := d.ForProject(p.Project); existing != nil {
+		*existing = p
+		return
+	}
+	d.Projects = append(d.Projects, p)
+}
+
+// RemoveProject drops the overrides of project, reporting whether it had
+// any.
+func (d *Defaults) RemoveProject(project string) bool {
+	n := len(d.Projects)
+	d.Projects = slices.DeleteFunc(d.Projects, func(p ProjectDefaults) bool { return p.Project == NormalizeProject(project) })
+	return len(d.Projects) < n
+}
+
+// Apply fills in the fields of todo its request left out: the project
+// first, then the tags and due date from the overrides of that project or
+// else from the user's defaults. Fields that were given are kept.
+func (d *Defaults) Apply(todo *Todo) {
+	if todo.Project == "" {
+		todo.Project = d.Project
+	}
+	tags, dueInDays := d.Tags, d.DueInDays
+	if p := d.ForProject(todo.Project); p != nil {
+		if p.Tags != nil {
+			tags = p.Tags
+		}
+		if p.DueInDays != nil {
+			dueInDays = p.DueInDays
+		}
+	}
+
+	if todo.Tags == nil && len(tags) > 0 {
+		todo.Tags = slices.Clone(tags)
+	}
+	if todo.DueDate == nil && dueInDays != nil {
+		due := Now().AddDate(0, 0, *dueInDays)
+		todo.DueDate = &due
+	}
+}
+
+// Defaults returns the defaults of the owner ctx is scoped to, empty if
+// none were set.
+func (r *MongoRepository) Defaults(ctx context.Context) (*Defaults, error) {
+	owner, _ := ownerFrom(ctx)
+	d := &Defaults{}
+	err := withRetry(ctx, func() error {
+		return r.defaultsCollection().FindOne(ctx, bson.M{"_id": owner}).Decode(d)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &Defaults{Owner: owner, Projects: []ProjectDefaults{}}, nil
+	}
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	return d, nil
+}
+
+// SetDefaults replaces the defaults of the owner ctx is scoped to with d.
+func (r *MongoRepository) SetDefaults(ctx context.Context, d *Defaults) error {
+	d.Owner, _ = ownerFrom(ctx)
+	now := Now()
+	d.UpdatedAt = &now
+	normalizeDefaults(d)
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	_, err := r.defaultsCollection().ReplaceOne(ctx, bson.M{"_id": d.Owner}, d, options.Replace().SetUpsert(true))
+	return deadlineError(err)
+}
+
+// renameDefaults points the defaults of the owner ctx is scoped to from
+// project from to project to. Overrides of from are dropped when to has
+// its own. The todos are already renamed, so failures are only logged.
+func (r *MongoRepository) renameDefaults(ctx context.Context, from string, to string) {
+	owner, _ := ownerFrom(ctx)
+	_, err := r.defaultsCollection().UpdateOne(ctx,
+		bson.M{"_id": owner, "project": from},
+		bson.M{"$set": bson.M{"project": to}})
+	if err == nil {
+		_, err = r.defaultsCollection().UpdateOne(ctx,
+			bson.M{"_id": owner, "projects": bson.M{"$not": bson.M{"$elemMatch": bson.M{"project": to}}}},
+			bson.M{"$set": bson.M{"projects.$[p].project": to}},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"p.project": from}}}))
+	}
+	if err == nil {
+		_, err = r.defaultsCollection().UpdateOne(ctx,
+			bson.M{"_id": owner},
+			bson.M{"$pull": bson.M{"projects": bson.M{"project": from}}})
+	}
+	if err != nil {
+		log.Printf("Renaming project %q to %q in the defaults of %q failed: %v", from, to, owner, err)
+	}
+}
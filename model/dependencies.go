@@ -0,0 +1,144 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrBlockerNotFound = errors.New("blocking todo not found")
+	ErrDependencyCycle = errors.New("blocking todos would depend on each other in a cycle")
+)
+
+// BlockedError is returned when completing a todo whose blocking todos are
+// not all completed yet.
+type BlockedError struct {
+	BlockedBy []primitive.ObjectID
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("todo is blocked by %d pending todos", len(e.BlockedBy))
+}
+
+// checkBlockers returns ErrBlockerNotFound unless every todo in blockers
+// exists outside the trash, and ErrDependencyCycle if id is one of them or
+// blocks one of them, directly or through other todos. New todos have no
+// todos depending on them, so for those, with a zero id, only existence is
+// checked.
+func (r *MongoRepository) checkBlockers(ctx context.Context, id primitive.ObjectID, blockers []primitive.ObjectID) error {
+	if len(blockers) == 0 {
+		return nil
+	}
+
+	unique := map[primitive.ObjectID]bool{}
+	for _, b := range blockers {
+		if b == id {
+			return ErrDependencyCycle
+		}
+		unique[b] = true
+	}
+	ids := make(bson.A, 0, len(unique))
+	for b := range unique {
+		ids = append(ids, b)
+	}
+
+	n, err := r.coll.CountDocuments(ctx, OwnedBy(ctx, bson.D{
+		primitive.E{Key: "_id", Value: bson.M{"$in": ids}},
+		notDeleted,
+	}))
+	if err != nil {
+		return deadlineError(err)
+	}
+	if n != int64(len(ids)) {
+		return ErrBlockerNotFound
+	}
+	if id.IsZero() {
+		return nil
+	}
+
+	// Follow blocked_by from the new blockers; reaching id means id would
+	// end up blocking itself.
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": bson.M{"$in": ids}}}},
+		{{Key: "$graphLookup", Value: bson.M{
+			"from":             r.coll.Name(),
+			"startWith":        "$blocked_by",
+			"connectFromField": "blocked_by",
+			"connectToField":   "_id",
+			"as":               "chain",
+		}}},
+		{{Key: "$match", Value: bson.M{"chain._id": id}}},
+		{{Key: "$limit", Value: 1}},
+		{{Key: "$project", Value: bson.M{"_id": 1}}},
+	}
+	cur, err := r.coll.Aggregate(ctx, pipeline, aggregateOptions(ctx))
+	if err != nil {
+		return deadlineError(err)
+	}
+	defer cur.Close(ctx)
+	if cur.Next(ctx) {
+		return ErrDependencyCycle
+	}
+	return deadlineError(cur.Err())
+}
+
+// pendingBlockersFilter matches the todos among blockers that still block:
+// those not completed yet. Deleted blockers no longer block.
+func pendingBlockersFilter(blockers []primitive.ObjectID) bson.D {
+	return bson.D{
+		primitive.E{Key: "_id", Value: bson.M{"$in": blockers}},
+		notDeleted,
+		primitive.E{Key: "completed", Value: false},
+	}
+}
+
+// checkUnblocked returns a *BlockedError listing the todos among blockers
+// that are still pending.
+func (r *MongoRepository) checkUnblocked(ctx context.Context, blockers []primitive.ObjectID) error {
+	if len(blockers) == 0 {
+		return nil
+	}
+
+	ids, err := r.coll.Distinct(ctx, "_id", pendingBlockersFilter(blockers))
+	if err != nil {
+		return deadlineError(err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	blocked := &BlockedError{}
+	for _, id := range ids {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			blocked.BlockedBy = append(blocked.BlockedBy, oid)
+		}
+	}
+	return blocked
+}
+
+// Blockers returns the todos blocking the todo with the given id that
+// are still pending. Unlike the other listings an empty result is not an
+// error. A missing todo is mongo.ErrNoDocuments.
+func (r *MongoRepository) Blockers(ctx context.Context, id string) ([]*Todo, error) {
+	todo, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if todo.DeletedAt != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	if len(todo.BlockedBy) == 0 {
+		return []*Todo{}, nil
+	}
+
+	todos, err := r.filterTodos(ctx, OwnedBy(ctx, pendingBlockersFilter(todo.BlockedBy)))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []*Todo{}, nil
+	}
+	return todos, err
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// detailNotesLimit is the number of recent notes included in a detail view.
+const detailNotesLimit = 5
+
+// TodoDetail is a todo together with its related data.
+type TodoDetail struct {
+	Todo     *Todo           `json:"todo"`
+	Subtasks []*Todo         `json:"subtasks,omitempty"`
+	Notes    []*Note         `json:"notes,omitempty"`
+	History  *HistorySummary `json:"history,omitempty"`
+}
+
+// GetTodoDetail assembles the todo with the given id and its related data
+// from todos, with one query per related collection.
+func GetTodoDetail(ctx context.Context, todos TodoRepository, id string) (*TodoDetail, error) {
+	todo, err := todos.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	subtasks, err := todos.Children(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := todos.Notes(ctx, id, 0, detailNotesLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := todos.HistorySummary(ctx, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TodoDetail{Todo: todo, Subtasks: subtasks, Notes: notes, History: history}, nil
+}
+
+// PrintTodoDetail prints every populated section of detail, leaving out
+// optional sections that have nothing to show.
+func PrintTodoDetail(detail *TodoDetail) {
+	t := detail.Todo
+
+	if t.Completed {
+		color.Green("%s\n", t.Text)
+	} else {
+		color.Yellow("%s\n", t.Text)
+	}
+	fmt.Printf("  %-10s %s\n", "ID", t.ID.Hex())
+	status := "pending"
+	if t.Completed {
+		status = "completed"
+	}
+	if t.CompletedAt != nil {
+		status += " " + t.CompletedAt.Local().Format(time.DateTime)
+	}
+	if t.Archived {
+		status += ", archived"
+	}
+	if t.DeletedAt != nil {
+		status += ", deleted " + t.DeletedAt.Local().Format(time.DateTime)
+	}
+	fmt.Printf("  %-10s %s\n", "Status", status)
+	fmt.Printf("  %-10s %s\n", "Created", t.CreatedAt.Local().Format(time.DateTime))
+	fmt.Printf("  %-10s %s\n", "Updated", t.UpdatedAt.Local().Format(time.DateTime))
+	if t.RemindAt != nil {
+		remind := t.RemindAt.Local().Format(time.DateTime)
+		if t.RemindedAt != nil {
+			remind += " (sent)"
+		}
+		fmt.Printf("  %-10s %s\n", "Reminder", remind)
+	}
+	if t.Recurrence != "" {
+		fmt.Printf("  %-10s %s\n", "Repeats", t.Recurrence)
+	}
+	if t.Project != "" {
+		fmt.Printf("  %-10s %s\n", "Project", t.Project)
+	}
+	if len(t.Tags) > 0 {
+		fmt.Printf("  %-10s %s\n", "Tags", strings.Join(t.Tags, ", "))
+	}
+	if t.DueDate != nil {
+		due := t.DueDate.Local().Format(time.DateTime)
+		if !t.Completed && t.DueDate.Before(Now()) {
+			due = color.RedString("%s (overdue)", due)
+		}
+		fmt.Printf("  %-10s %s\n", "Due", due)
+	}
+	if t.StaleSince != nil {
+		fmt.Printf("  %-10s %s\n", "Stale", t.StaleSince.Local().Format(time.DateTime))
+	}
+
+	if len(detail.Subtasks) > 0 {
+		fmt.Println("\nSubtasks")
+		for _, sub := range detail.Subtasks {
+			mark := " "
+			if sub.Completed {
+				mark = "x"
+			}
+			fmt.Printf("  [%s] %s\n", mark, sub.Text)
+		}
+	}
+
+	if len(detail.Notes) > 0 {
+		fmt.Println("\nNotes")
+		for _, note := range detail.Notes {
+			fmt.Printf("  %s %s: %s\n", note.CreatedAt.Local().Format(time.DateTime), note.Author, note.Text)
+		}
+	}
+
+	if h := detail.History; h != nil {
+		actions := make([]string, 0, len(h.ByAction))
+		for action := range h.ByAction {
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+		counts := make([]string, len(actions))
+		for i, action := range actions {
+			counts[i] = fmt.Sprintf("%d %s", h.ByAction[action], action)
+		}
+
+		fmt.Println("\nHistory")
+		fmt.Printf("  %d changes: %s\n", h.Changes, strings.Join(counts, ", "))
+		fmt.Printf("  last %s %s\n", h.LastAction, h.LastChange.Local().Format(time.DateTime))
+	}
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// captureStdout returns what fn prints to standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestPrintTodoDetail(t *testing.T) {
+	todo := &Todo{ID: primitive.NewObjectID(), Text: "plan trip"}
+
+	out := captureStdout(t, func() {
+		PrintTodoDetail(&TodoDetail{Todo: todo})
+	})
+	for _, section := range []string{"Subtasks", "Notes", "History"} {
+		if strings.Contains(out, section) {
+			t.Errorf("printed an empty %s section:\n%s", section, out)
+		}
+	}
+
+	out = captureStdout(t, func() {
+		PrintTodoDetail(&TodoDetail{
+			Todo: todo,
+			Subtasks: []*Todo{
+				{Text: "book flights", Completed: true},
+				{Text: "pack"},
+			},
+			History: &HistorySummary{
+				Changes:    3,
+				ByAction:   map[string]int64{HistoryUpdate: 2, HistoryComplete: 1},
+				LastAction: HistoryComplete,
+				LastChange: time.Date(2026, time.May, 4, 10, 30, 0, 0, time.Local),
+			},
+		})
+	})
+	for _, want := range []string{
+		"Subtasks\n  [x] book flights\n  [ ] pack\n",
+		"History\n  3 changes: 1 complete, 2 update\n  last complete 2026-05-04 10:30:00\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("detail does not contain %q:\n%s", want, out)
+		}
+	}
+}
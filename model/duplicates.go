@@ -0,0 +1,140 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxDuplicateCandidates bounds the pairwise comparison, which is quadratic
+// in the number of pending todos considered.
+const maxDuplicateCandidates = 2000
+
+type DuplicateCluster struct {
+	Todos []*Todo `json:"todos"`
+	// Similarity is the highest pairwise similarity within the cluster.
+	Similarity float64 `json:"similarity"`
+}
+
+// Duplicates groups pending todos whose normalized texts have a trigram
+// similarity of at least threshold. It stops early with ctx's error when the
+// context is done.
+func (r *MongoRepository) Duplicates(ctx context.Context, threshold float64) ([]*DuplicateCluster, error) {
+	opts := options.Find().
+		SetSort(bson.D{primitive.E{Key: "_id", Value: 1}}).
+		SetLimit(maxDuplicateCandidates)
+	todos, err := r.filterTodos(ctx, OwnedBy(ctx, PendingFilter()), opts)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []*DuplicateCluster{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return clusterDuplicates(ctx, todos, threshold)
+}
+
+// clusterDuplicates groups todos whose normalized texts have a trigram
+// similarity of at least threshold, in the order of todos.
+func clusterDuplicates(ctx context.Context, todos []*Todo, threshold float64) ([]*DuplicateCluster, error) {
+	grams := make([][]uint32, len(todos))
+	for i, t := range todos {
+		grams[i] = trigrams(MatchKey(t.Text))
+	}
+
+	parent := make([]int, len(todos))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	best := make([]float64, len(todos))
+	for i := range todos {
+		if err := ctx.Err(); err != nil {
+			return nil, deadlineError(err)
+		}
+
+		for j := i + 1; j < len(todos); j++ {
+			similarity := jaccard(grams[i], grams[j])
+			if similarity < threshold {
+				continue
+			}
+
+			parent[find(j)] = find(i)
+			best[i] = max(best[i], similarity)
+			best[j] = max(best[j], similarity)
+		}
+	}
+
+	clusters := map[int]*DuplicateCluster{}
+	var ordered []*DuplicateCluster
+	for i, t := range todos {
+		if best[i] == 0 {
+			continue
+		}
+
+		root := find(i)
+		cluster, ok := clusters[root]
+		if !ok {
+			cluster = &DuplicateCluster{}
+			clusters[root] = cluster
+			ordered = append(ordered, cluster)
+		}
+		cluster.Todos = append(cluster.Todos, t)
+		cluster.Similarity = max(cluster.Similarity, best[i])
+	}
+
+	if ordered == nil {
+		ordered = []*DuplicateCluster{}
+	}
+	return ordered, nil
+}
+
+// trigrams returns the sorted, distinct hashes of the rune trigrams of text,
+// padded so that short texts still produce some.
+func trigrams(text string) []uint32 {
+	runes := []rune("  " + text + " ")
+	var grams []uint32
+	for i := 0; i+3 <= len(runes); i++ {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(string(runes[i : i+3])))
+		grams = append(grams, h.Sum32())
+	}
+
+	slices.Sort(grams)
+	return slices.Compact(grams)
+}
+
+// jaccard returns the Jaccard similarity of two sorted sets.
+func jaccard(a, b []uint32) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	var shared int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			shared++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// expandFuncs is the complete set of functions available to expanded text,
+// on top of text/template's builtins. None of them have side effects.
+var expandFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// ExpandText resolves template variables in text, such as
+// "Prepare invoice for {{.Month}}", at the given time. Besides vars, the
+// variables Date, Day, Month, Year, Weekday and Week are available, and the
+// date function formats the time with a Go layout. Undefined variables are
+// an error rather than being replaced with "<no value>".
+func ExpandText(text string, vars map[string]string, now time.Time) (string, error) {
+	funcs := template.FuncMap{
+		"date": now.Format,
+	}
+	for name, fn := range expandFuncs {
+		funcs[name] = fn
+	}
+
+	tmpl, err := template.New("todo").Option("missingkey=error").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+
+	_, week := now.ISOWeek()
+	data := map[string]interface{}{
+		"Date":    now.Format("2006-01-02"),
+		"Day":     now.Day(),
+		"Month":   now.Month().String(),
+		"Year":    now.Year(),
+		"Weekday": now.Weekday().String(),
+		"Week":    week,
+	}
+	for name, value := range vars {
+		data[name] = value
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("cannot expand template: %w", err)
+	}
+
+	return out.String(), nil
+}
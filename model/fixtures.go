@@ -0,0 +1,92 @@
+package model
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//go:embed fixtures/*.json
+var fixtureFiles embed.FS
+
+type fixtureNote struct {
+	Author  string `json:"author"`
+	Text    string `json:"text"`
+	DaysAgo int    `json:"days_ago"`
+}
+
+type fixtureTodo struct {
+	Text           string        `json:"text"`
+	Completed      bool          `json:"completed"`
+	CreatedDaysAgo int           `json:"created_days_ago"`
+	UpdatedDaysAgo int           `json:"updated_days_ago"`
+	Notes          []fixtureNote `json:"notes"`
+}
+
+// FixtureNames lists the fixture sets embedded in the binary.
+func FixtureNames() []string {
+	entries, _ := fixtureFiles.ReadDir("fixtures")
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	return names
+}
+
+// LoadFixture inserts the named fixture set into todos. Timestamps are relative to Now, so the data always looks fresh,
+// and fully deterministic when the clock is frozen.
+func LoadFixture(ctx context.Context, todos TodoRepository, name string) (int, error) {
+	raw, err := fixtureFiles.ReadFile(path.Join("fixtures", name+".json"))
+	if err != nil {
+		return 0, fmt.Errorf("unknown fixture %q, expected one of %s", name, strings.Join(FixtureNames(), ", "))
+	}
+
+	var fixtures []fixtureTodo
+	if err := json.Unmarshal(raw, &fixtures); err != nil {
+		return 0, fmt.Errorf("fixture %q is invalid: %w", name, err)
+	}
+
+	now := Now()
+	daysAgo := func(days int) time.Time {
+		return now.AddDate(0, 0, -days)
+	}
+
+	created := make([]*Todo, len(fixtures))
+	for i, f := range fixtures {
+		created[i] = &Todo{
+			ID:        primitive.NewObjectID(),
+			CreatedAt: daysAgo(f.CreatedDaysAgo),
+			UpdatedAt: daysAgo(f.UpdatedDaysAgo),
+			Text:      f.Text,
+			Completed: f.Completed,
+		}
+	}
+	if err := todos.CreateMany(ctx, created); err != nil {
+		return 0, err
+	}
+
+	var notes []*Note
+	for i, f := range fixtures {
+		for _, n := range f.Notes {
+			notes = append(notes, &Note{
+				ID:        primitive.NewObjectID(),
+				TodoID:    created[i].ID,
+				Author:    n.Author,
+				Text:      n.Text,
+				CreatedAt: daysAgo(n.DaysAgo),
+			})
+		}
+	}
+	if err := todos.CreateNotes(ctx, notes); err != nil {
+		return len(created), err
+	}
+
+	return len(created), nil
+}
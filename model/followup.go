@@ -0,0 +1,158 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrAlreadyCompleted = errors.New("todo is already completed")
+
+// supportsTransactions reports whether the deployment is a replica set or
+// sharded cluster, the topologies multi-document transactions need.
+func (r *MongoRepository) supportsTransactions(ctx context.Context) bool {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := r.coll.Database().RunCommand(ctx, bson.D{primitive.E{Key: "hello", Value: 1}}).Decode(&hello)
+	return err == nil && (hello.SetName != "" || hello.Msg == "isdbgrid")
+}
+
+// CompleteWithFollowup completes the todo with the given id and creates
+// followup as the todo to do next, linked to it through followup_of. The
+// follow-up takes the project and tags of the completed todo unless it sets
+// its own. Both happen in a transaction where the deployment supports one;
+// otherwise the follow-up is inserted first and deleted again if completing
+// fails. A missing todo is mongo.ErrNoDocuments, a completed one
+// ErrAlreadyCompleted and one with pending blocking todos a *BlockedError.
+func (r *MongoRepository) CompleteWithFollowup(ctx context.Context, id string, followup *Todo) (*Todo, *Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	original := &Todo{}
+	if err := r.coll.FindOne(ctx, filter).Decode(original); err != nil {
+		return nil, nil, deadlineError(err)
+	}
+	if original.Completed {
+		return nil, nil, ErrAlreadyCompleted
+	}
+	if err := r.checkUnblocked(ctx, original.BlockedBy); err != nil {
+		return nil, nil, err
+	}
+
+	if followup.Project == "" {
+		followup.Project = original.Project
+	}
+	if followup.Tags == nil {
+		followup.Tags = original.Tags
+	}
+	followup.ID = primitive.NewObjectID()
+	followup.CreatedAt = Now()
+	followup.UpdatedAt = followup.CreatedAt
+	followup.Completed = false
+	followup.FollowupOf = &original.ID
+	followup.Owner = original.Owner
+	followup.Text = NormalizeText(followup.Text)
+	followup.NormalizedText = MatchKey(followup.Text)
+	followup.Tags = NormalizeTags(followup.Tags)
+	followup.Project = NormalizeProject(followup.Project)
+	followup.Position = initialPosition(followup)
+	followup.PendingText = ""
+	if RejectDuplicates() {
+		followup.PendingText = followup.NormalizedText
+	}
+	if err := r.Validate(ctx, followup); err != nil {
+		return nil, nil, err
+	}
+
+	now := Now()
+	pending := append(bson.D{primitive.E{Key: "completed", Value: false}}, filter...)
+	complete := func(ctx context.Context) error {
+		res, err := r.coll.UpdateOne(ctx, pending,
+			bson.M{
+				"$set":   bson.M{"completed": true, "completed_at": now, "updated_at": now},
+				"$unset": withoutReminders(bson.M{"pending_text": ""}),
+			})
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			return ErrAlreadyCompleted
+		}
+		return nil
+	}
+	insert := func(ctx context.Context) error {
+		_, err := r.coll.InsertOne(ctx, followup)
+		return duplicateError(err)
+	}
+
+	if r.supportsTransactions(ctx) {
+		err = r.completeWithFollowupInTransaction(ctx, complete, insert)
+	} else {
+		err = r.completeWithFollowupCompensated(ctx, followup.ID, complete, insert)
+	}
+	if err != nil {
+		return nil, nil, deadlineError(err)
+	}
+
+	r.recordFollowupHistory(ctx, original, followup.ID)
+	if original.Recurrence != "" {
+		if _, err := r.spawnNextOccurrence(ctx, original); err != nil {
+			log.Printf("Creating the next occurrence of todo %s failed: %v", original.ID.Hex(), err)
+		}
+	}
+
+	completed := *original
+	completed.Completed = true
+	completed.CompletedAt = &now
+	completed.UpdatedAt = now
+	completed.PendingText = ""
+	completed.Reminders = nil
+	completed.NextReminderAt = nil
+	return &completed, followup, nil
+}
+
+func (r *MongoRepository) completeWithFollowupInTransaction(ctx context.Context, complete, insert func(context.Context) error) error {
+	session, err := r.coll.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if err := complete(sc); err != nil {
+			return nil, err
+		}
+		return nil, insert(sc)
+	})
+	return err
+}
+
+func (r *MongoRepository) completeWithFollowupCompensated(ctx context.Context, followupId primitive.ObjectID, complete, insert func(context.Context) error) error {
+	if err := insert(ctx); err != nil {
+		return err
+	}
+	if err := complete(ctx); err != nil {
+		// Completing failed, so the follow-up must not outlive it. Deleting
+		// uses a fresh context in case ctx is what ran out.
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, delErr := r.coll.DeleteOne(cleanupCtx, bson.M{"_id": followupId}); delErr != nil {
+			log.Printf("Deleting follow-up %s after a failed completion failed: %v", followupId.Hex(), delErr)
+		}
+		return err
+	}
+	return nil
+}
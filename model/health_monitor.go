@@ -0,0 +1,104 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const probeTimeout = 5 * time.Second
+
+type ProbeResult struct {
+	CheckedAt time.Time `json:"checked_at"`
+	LatencyMS float64   `json:"latency_ms"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// HealthMonitor periodically probes the dependencies it was given and keeps
+// the most recent results for each one in a fixed-size ring buffer.
+type HealthMonitor struct {
+	mu        sync.Mutex
+	size      int
+	startedAt time.Time
+	probes    map[string]func(context.Context) error
+	history   map[string][]ProbeResult
+}
+
+func NewHealthMonitor(size int) *HealthMonitor {
+	return &HealthMonitor{
+		size:      size,
+		startedAt: time.Now(),
+		probes:    map[string]func(context.Context) error{},
+		history:   map[string][]ProbeResult{},
+	}
+}
+
+// AddProbe registers a dependency check. It must be called before Run.
+func (m *HealthMonitor) AddProbe(name string, probe func(context.Context) error) {
+	m.probes[name] = probe
+}
+
+// Run probes every dependency immediately and then once per interval until
+// ctx is cancelled.
+func (m *HealthMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.probeAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *HealthMonitor) probeAll(ctx context.Context) {
+	for name, probe := range m.probes {
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		start := time.Now()
+		err := probe(probeCtx)
+		cancel()
+
+		result := ProbeResult{
+			CheckedAt: start,
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			Healthy:   err == nil,
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		m.record(name, result)
+	}
+}
+
+func (m *HealthMonitor) record(name string, result ProbeResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := m.history[name]
+	if len(results) >= m.size {
+		results = results[1:]
+	}
+	m.history[name] = append(results, result)
+}
+
+// History returns a copy of the recorded results per dependency, oldest
+// first.
+func (m *HealthMonitor) History() map[string][]ProbeResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := make(map[string][]ProbeResult, len(m.history))
+	for name, results := range m.history {
+		history[name] = append([]ProbeResult(nil), results...)
+	}
+	return history
+}
+
+func (m *HealthMonitor) Uptime() time.Duration {
+	return time.Since(m.startedAt)
+}
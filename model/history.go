@@ -0,0 +1,217 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Actions recorded in a todo's history.
+const (
+	HistoryUpdate   = "update"
+	HistoryComplete = "complete"
+	HistoryDelete   = "delete"
+	HistoryReopen   = "reopen"
+	// HistoryCompleteWithFollowup is a completion that created a follow-up,
+	// named by the entry's followup.
+	HistoryCompleteWithFollowup = "complete_with_followup"
+)
+
+// HistoryEntry records one change to a todo along with the todo as it was
+// before the change.
+type HistoryEntry struct {
+	ID        primitive.ObjectID  `json:"_id" bson:"_id"`
+	TodoID    primitive.ObjectID  `json:"todo_id" bson:"todo_id"`
+	Action    string              `json:"action" bson:"action" example:"update"`
+	Changed   []string            `json:"changed,omitempty" bson:"changed,omitempty"`
+	Previous  *Todo               `json:"previous" bson:"previous"`
+	Followup  *primitive.ObjectID `json:"followup,omitempty" bson:"followup,omitempty"`
+	CreatedAt time.Time           `json:"created_at" bson:"created_at"`
+}
+
+func (r *MongoRepository) historyCollection() *mongo.Collection {
+	return r.coll.Database().Collection("todo_history")
+}
+
+// recordHistory appends an entry for a change already written to the todo.
+// The change stands even if the entry cannot be written, so failures are
+// logged rather than returned.
+func (r *MongoRepository) recordHistory(ctx context.Context, action string, previous *Todo, changed []string) {
+	r.insertHistory(ctx, &HistoryEntry{
+		ID:        primitive.NewObjectID(),
+		TodoID:    previous.ID,
+		Action:    action,
+		Changed:   changed,
+		Previous:  previous,
+		CreatedAt: Now(),
+	})
+}
+
+// recordFollowupHistory records the completion of previous along with the
+// follow-up it created.
+func (r *MongoRepository) recordFollowupHistory(ctx context.Context, previous *Todo, followupId primitive.ObjectID) {
+	r.insertHistory(ctx, &HistoryEntry{
+		ID:        primitive.NewObjectID(),
+		TodoID:    previous.ID,
+		Action:    HistoryCompleteWithFollowup,
+		Changed:   []string{"completed", "completed_at"},
+		Previous:  previous,
+		Followup:  &followupId,
+		CreatedAt: Now(),
+	})
+}
+
+// recordHistoryMany appends an entry for each of the todos a bulk change
+// already wrote to, with a single insert.
+func (r *MongoRepository) recordHistoryMany(ctx context.Context, action string, previous []*Todo, changed []string) {
+	if len(previous) == 0 {
+		return
+	}
+	now := Now()
+	entries := make([]interface{}, len(previous))
+	for i, t := range previous {
+		entries[i] = &HistoryEntry{
+			ID:        primitive.NewObjectID(),
+			TodoID:    t.ID,
+			Action:    action,
+			Changed:   changed,
+			Previous:  t,
+			CreatedAt: now,
+		}
+	}
+	if _, err := r.historyCollection().InsertMany(ctx, entries, options.InsertMany().SetOrdered(false)); err != nil {
+		log.Printf("Recording %s of %d todos in their history failed: %v", action, len(previous), err)
+	}
+}
+
+func (r *MongoRepository) insertHistory(ctx context.Context, entry *HistoryEntry) {
+	if _, err := r.historyCollection().InsertOne(ctx, entry); err != nil {
+		log.Printf("Recording %s of todo %s in its history failed: %v", entry.Action, entry.TodoID.Hex(), err)
+	}
+}
+
+// historyIgnored are bookkeeping fields left out of a change's field list.
+var historyIgnored = map[string]bool{
+	"updated_at":       true,
+	"normalized_text":  true,
+	"pending_text":     true,
+	"next_reminder_at": true,
+}
+
+// changedFields returns, in name order, the fields an update with set and
+// unset actually changes on previous. Bookkeeping fields are left out.
+func changedFields(previous *Todo, set bson.M, unset bson.M) ([]string, error) {
+	raw, err := bson.Marshal(previous)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for field, value := range set {
+		if historyIgnored[field] {
+			continue
+		}
+		_, data, err := bson.MarshalValue(value)
+		if err != nil {
+			return nil, err
+		}
+		old, err := bson.Raw(raw).LookupErr(field)
+		if err != nil || !bytes.Equal(old.Value, data) {
+			changed = append(changed, field)
+		}
+	}
+	for field := range unset {
+		if historyIgnored[field] {
+			continue
+		}
+		if _, err := bson.Raw(raw).LookupErr(field); err == nil {
+			changed = append(changed, field)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// History returns a page of the changes made to the todo with the given
+// id, newest first. A missing todo is mongo.ErrNoDocuments.
+func (r *MongoRepository) History(ctx context.Context, todoId string, offset int64, limit int64) ([]*HistoryEntry, error) {
+	todo, err := r.GetByID(ctx, todoId)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := findOptions(ctx).
+		SetSort(bson.D{
+			primitive.E{Key: "created_at", Value: -1},
+			primitive.E{Key: "_id", Value: -1},
+		}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cur, err := r.historyCollection().Find(ctx, bson.M{"todo_id": todo.ID}, opts)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	entries := []*HistoryEntry{}
+	if err := cur.All(ctx, &entries); err != nil {
+		return nil, deadlineError(err)
+	}
+
+	return entries, nil
+}
+
+// HistorySummary counts the changes recorded in a todo's history.
+type HistorySummary struct {
+	Changes    int64            `json:"changes"`
+	ByAction   map[string]int64 `json:"by_action"`
+	LastAction string           `json:"last_action"`
+	LastChange time.Time        `json:"last_change"`
+}
+
+// HistorySummary summarizes the history of the todo with id in a single
+// aggregation. It returns nil if nothing was recorded.
+func (r *MongoRepository) HistorySummary(ctx context.Context, id primitive.ObjectID) (*HistorySummary, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{primitive.E{Key: "$match", Value: bson.M{"todo_id": id}}},
+		bson.D{primitive.E{Key: "$group", Value: bson.M{
+			"_id":   "$action",
+			"count": bson.M{"$sum": 1},
+			"last":  bson.M{"$max": "$created_at"},
+		}}},
+	}
+	cur, err := r.historyCollection().Aggregate(ctx, pipeline, aggregateOptions(ctx))
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	var groups []struct {
+		Action string    `bson:"_id"`
+		Count  int64     `bson:"count"`
+		Last   time.Time `bson:"last"`
+	}
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, deadlineError(err)
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	summary := &HistorySummary{ByAction: map[string]int64{}}
+	for _, g := range groups {
+		summary.Changes += g.Count
+		summary.ByAction[g.Action] = g.Count
+		if g.Last.After(summary.LastChange) {
+			summary.LastAction = g.Action
+			summary.LastChange = g.Last
+		}
+	}
+	return summary, nil
+}
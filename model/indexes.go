@@ -0,0 +1,86 @@
+package model
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the queries in this package rely on.
+// Creating an index that already exists is a no-op.
+func (r *MongoRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.notesCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "todo_id", Value: 1},
+			primitive.E{Key: "created_at", Value: -1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.historyCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "todo_id", Value: 1},
+			primitive.E{Key: "created_at", Value: -1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.templatesCollection().Indexes().CreateOne(ctx, templateNameIndex)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "tags", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "parent_id", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "owner", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "project", Value: 1}}},
+		pendingTextIndex,
+		textIndex,
+		{Keys: bson.D{
+			primitive.E{Key: "owner", Value: 1},
+			primitive.E{Key: "position", Value: 1},
+		}},
+		{Keys: bson.D{
+			primitive.E{Key: "owner", Value: 1},
+			primitive.E{Key: "pinned", Value: -1},
+			primitive.E{Key: "position", Value: 1},
+		}},
+		{Keys: bson.D{
+			primitive.E{Key: "reminded_at", Value: 1},
+			primitive.E{Key: "remind_at", Value: 1},
+		}},
+		{Keys: bson.D{primitive.E{Key: "next_reminder_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	usageKeys := bson.D{
+		primitive.E{Key: "user", Value: 1},
+		primitive.E{Key: "period", Value: 1},
+	}
+	_, err = dailyUsageCollection(r.coll.Database()).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: usageKeys, Options: options.Index().SetUnique(true)},
+		{
+			Keys:    bson.D{primitive.E{Key: "period", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(usageRetention.Seconds())),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = monthlyUsageCollection(r.coll.Database()).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    usageKeys,
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// lazyMigrationInterval spaces out write-backs so a burst of legacy
+	// reads never turns into a burst of writes.
+	lazyMigrationInterval = 20 * time.Millisecond
+	lazyMigrationQueue    = 1000
+)
+
+// lazyMigration computes the current-schema default of a field for
+// documents written before the field existed. requires lists the fields the
+// computation reads, so projected reads lacking them are left alone.
+type lazyMigration struct {
+	field    string
+	requires []string
+	compute  func(*Todo) interface{}
+}
+
+var lazyMigrations = []lazyMigration{
+	{
+		field:    "normalized_text",
+		requires: []string{"text"},
+		compute: func(t *Todo) interface{} {
+			t.NormalizedText = MatchKey(t.Text)
+			return t.NormalizedText
+		},
+	},
+	{
+		field:    "position",
+		requires: []string{"created_at"},
+		compute: func(t *Todo) interface{} {
+			t.Position = initialPosition(t)
+			return t.Position
+		},
+	},
+}
+
+var (
+	lazyMigrationsQueued  = expvar.NewInt("lazy_migrations_queued")
+	lazyMigrationsWritten = expvar.NewInt("lazy_migrations_written")
+	lazyMigrationsDropped = expvar.NewInt("lazy_migrations_dropped")
+)
+
+type lazyWrite struct {
+	coll *mongo.Collection
+	id   primitive.ObjectID
+	set  bson.M
+}
+
+var (
+	lazyWrites     = make(chan lazyWrite, lazyMigrationQueue)
+	lazyWriterOnce sync.Once
+)
+
+// decodeTodo decodes a todo document, filling in and queueing a write-back
+// for any field the document predates.
+func (r *MongoRepository) decodeTodo(raw bson.Raw) (*Todo, error) {
+	t, set, err := migrateTodo(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(set) > 0 {
+		queueLazyWrite(lazyWrite{coll: r.coll, id: t.ID, set: set})
+	}
+
+	return t, nil
+}
+
+// migrateTodo decodes a todo document and fills in the fields it predates,
+// returning them as the $set that writes them back.
+func migrateTodo(raw bson.Raw) (*Todo, bson.M, error) {
+	t := &Todo{}
+	if err := bson.Unmarshal(raw, t); err != nil {
+		return nil, nil, err
+	}
+
+	set := bson.M{}
+	for _, m := range lazyMigrations {
+		if _, err := raw.LookupErr(m.field); err == nil || !hasFields(raw, m.requires) {
+			continue
+		}
+		set[m.field] = m.compute(t)
+	}
+	return t, set, nil
+}
+
+func hasFields(raw bson.Raw, fields []string) bool {
+	for _, field := range fields {
+		if _, err := raw.LookupErr(field); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// queueLazyWrite hands the write to the background writer. When the queue is
+// full the write is dropped; the next read of the document queues it again.
+func queueLazyWrite(w lazyWrite) {
+	lazyWriterOnce.Do(func() {
+		go runLazyWriter()
+	})
+
+	select {
+	case lazyWrites <- w:
+		lazyMigrationsQueued.Add(1)
+	default:
+		lazyMigrationsDropped.Add(1)
+	}
+}
+
+func runLazyWriter() {
+	ticker := time.NewTicker(lazyMigrationInterval)
+	defer ticker.Stop()
+
+	for w := range lazyWrites {
+		<-ticker.C
+
+		// Only fill fields still missing, never overwrite a concurrent write.
+		filter := bson.M{"_id": w.id}
+		for field := range w.set {
+			filter[field] = bson.M{"$exists": false}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := w.coll.UpdateOne(ctx, filter, bson.M{"$set": w.set})
+		cancel()
+		if err != nil {
+			log.Printf("Lazy migration of todo %s failed: %v", w.id.Hex(), err)
+			continue
+		}
+		lazyMigrationsWritten.Add(1)
+	}
+}
+
+// LazyMigrationStatus counts, per lazily migrated field, the documents that
+// still lack it.
+func (r *MongoRepository) LazyMigrationStatus(ctx context.Context) (map[string]int64, error) {
+	status := map[string]int64{}
+	for _, m := range lazyMigrations {
+		n, err := r.coll.CountDocuments(ctx, bson.M{m.field: bson.M{"$exists": false}})
+		if err != nil {
+			return nil, err
+		}
+		status[m.field] = n
+	}
+
+	return status, nil
+}
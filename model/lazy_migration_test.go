@@ -0,0 +1,103 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// readSchemaFixture reads a todo document written by an earlier schema from
+// testdata/schema as extended JSON.
+func readSchemaFixture(t *testing.T, name string) bson.Raw {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "schema", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw bson.Raw
+	if err := bson.UnmarshalExtJSON(data, false, &raw); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return raw
+}
+
+func TestMigrateTodoBySchemaGeneration(t *testing.T) {
+	created := time.Date(2021, 3, 4, 5, 6, 7, 89_000_000, time.UTC)
+
+	tests := []struct {
+		name    string
+		fixture string
+		// wantSet is what is written back, empty when the document is
+		// current.
+		wantSet        bson.M
+		wantNormalized string
+		wantPosition   float64
+		check          func(t *testing.T, todo *Todo)
+	}{
+		{
+			name:    "before normalized text",
+			fixture: "v1.json",
+			wantSet: bson.M{
+				"normalized_text": "call zo\u00eb about the \"launch\"",
+				"position":        float64(created.UnixMilli()),
+			},
+			wantNormalized: "call zo\u00eb about the \"launch\"",
+			wantPosition:   float64(created.UnixMilli()),
+			check: func(t *testing.T, todo *Todo) {
+				if !todo.CreatedAt.Equal(created) || len(todo.Tags) != 1 || todo.DueDate == nil {
+					t.Errorf("decoded %+v, want its created_at, tags and due date kept", todo)
+				}
+			},
+		},
+		{
+			name:           "before normalized text, projected without created_at",
+			fixture:        "v1-projected.json",
+			wantSet:        bson.M{"normalized_text": "call zo\u00eb about the \"launch\""},
+			wantNormalized: "call zo\u00eb about the \"launch\"",
+		},
+		{
+			name:           "before position",
+			fixture:        "v2.json",
+			wantSet:        bson.M{"position": float64(created.UnixMilli())},
+			wantNormalized: "renew the passport",
+			wantPosition:   float64(created.UnixMilli()),
+			check: func(t *testing.T, todo *Todo) {
+				if !todo.Completed || todo.CompletedAt == nil {
+					t.Errorf("decoded %+v, want it completed with its completion time", todo)
+				}
+			},
+		},
+		{
+			name:           "current",
+			fixture:        "v3.json",
+			wantSet:        bson.M{},
+			wantNormalized: "water the plants",
+			wantPosition:   1500.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo, set, err := migrateTodo(readSchemaFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(set, tt.wantSet) {
+				t.Errorf("write-back = %v, want %v", set, tt.wantSet)
+			}
+			if todo.NormalizedText != tt.wantNormalized {
+				t.Errorf("normalized text = %q, want %q", todo.NormalizedText, tt.wantNormalized)
+			}
+			if todo.Position != tt.wantPosition {
+				t.Errorf("position = %v, want %v", todo.Position, tt.wantPosition)
+			}
+			if tt.check != nil {
+				tt.check(t, todo)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// slimFields are always part of a slim listing. normalized_text is never
+// sent to clients but is projected so reads do not queue lazy migrations.
+var slimFields = []string{"_id", "text", "completed", "due_date", "normalized_text"}
+
+// expandGroups are the optional field groups a slim listing can opt into.
+var expandGroups = map[string][]string{
+	"tags":     {"tags"},
+	"project":  {"project"},
+	"metadata": {"created_at", "updated_at", "stale_since"},
+}
+
+// TodoSummary is the slim representation of a todo used by list views.
+// Fields outside the requested groups are left out entirely.
+type TodoSummary struct {
+	ID         primitive.ObjectID `json:"_id"`
+	Text       string             `json:"text"`
+	Completed  bool               `json:"completed"`
+	DueDate    *time.Time         `json:"due_date,omitempty"`
+	Tags       []string           `json:"tags,omitempty"`
+	Project    string             `json:"project,omitempty"`
+	CreatedAt  *time.Time         `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time         `json:"updated_at,omitempty"`
+	StaleSince *time.Time         `json:"stale_since,omitempty"`
+	LastNote   *Note              `json:"last_note,omitempty"`
+}
+
+// ListView selects the fields of a slim listing.
+type ListView struct {
+	expand map[string]bool
+}
+
+// ParseListView parses a comma-separated list of groups to expand, such as
+// "tags,metadata". It returns nil for "all", meaning full documents.
+func ParseListView(expand string) (*ListView, error) {
+	view := &ListView{expand: map[string]bool{}}
+	for _, group := range strings.Split(expand, ",") {
+		group = strings.TrimSpace(group)
+		switch {
+		case group == "":
+		case group == "all":
+			return nil, nil
+		case expandGroups[group] != nil:
+			view.expand[group] = true
+		default:
+			return nil, fmt.Errorf("cannot expand %q, expected one of %s or all", group, strings.Join(ExpandGroups(), ", "))
+		}
+	}
+	return view, nil
+}
+
+// ExpandGroups returns the names of the groups a listing can expand.
+func ExpandGroups() []string {
+	names := make([]string, 0, len(expandGroups))
+	for name := range expandGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindOptions projects the query onto the view's fields, so the database
+// never sends the rest. A nil view, meaning full documents, adds nothing.
+func (v *ListView) FindOptions() []*options.FindOptions {
+	if v == nil {
+		return nil
+	}
+
+	projection := bson.M{}
+	for _, field := range slimFields {
+		projection[field] = 1
+	}
+	for group := range v.expand {
+		for _, field := range expandGroups[group] {
+			projection[field] = 1
+		}
+	}
+	return []*options.FindOptions{options.Find().SetProjection(projection)}
+}
+
+// Summarize returns the view's representation of t.
+func (v *ListView) Summarize(t *Todo) *TodoSummary {
+	s := &TodoSummary{
+		ID:        t.ID,
+		Text:      t.Text,
+		Completed: t.Completed,
+		DueDate:   t.DueDate,
+		LastNote:  t.LastNote,
+	}
+	if v.expand["tags"] {
+		s.Tags = t.Tags
+	}
+	if v.expand["project"] {
+		s.Project = t.Project
+	}
+	if v.expand["metadata"] {
+		s.CreatedAt = &t.CreatedAt
+		s.UpdatedAt = &t.UpdatedAt
+		s.StaleSince = t.StaleSince
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BatchLock is the advisory lock taken by jobs that rewrite many todos at
+// once, such as imports, migrations and aging, so they never interleave.
+const BatchLock = "batch"
+
+var (
+	// lockTTL is how long a lock outlives its last heartbeat, so a crashed
+	// holder blocks others for at most this long.
+	lockTTL = 30 * time.Second
+	// lockPollInterval spaces out attempts while waiting for a lock.
+	lockPollInterval = time.Second
+)
+
+// LockHeldError reports the process holding a lock someone else wanted.
+type LockHeldError struct {
+	Name      string
+	Holder    string
+	ExpiresAt time.Time
+}
+
+func (e *LockHeldError) Error() string {
+	return fmt.Sprintf("%s lock is held by %s until at least %s", e.Name, e.Holder, e.ExpiresAt.Local().Format(time.DateTime))
+}
+
+type lockDoc struct {
+	Name       string    `bson:"_id"`
+	Holder     string    `bson:"holder"`
+	AcquiredAt time.Time `bson:"acquired_at"`
+	ExpiresAt  time.Time `bson:"expires_at"`
+}
+
+// Lock is a held advisory lock. It is kept alive by a heartbeat until
+// Release is called.
+type Lock struct {
+	name   string
+	holder string
+	stop   context.CancelFunc
+	done   chan struct{}
+	// release frees the lock once the heartbeat has stopped.
+	release func(ctx context.Context) error
+}
+
+func (r *MongoRepository) locksCollection() *mongo.Collection {
+	return r.coll.Database().Collection("locks")
+}
+
+// LockHolder describes the current process for lock diagnostics, e.g.
+// "todos-app import on host (pid 1234)".
+func LockHolder(job string) string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s %s on %s (pid %d)", filepath.Base(os.Args[0]), job, host, os.Getpid())
+}
+
+// AcquireLock takes the named lock for holder, or returns a *LockHeldError
+// naming whoever has it. Expired locks are taken over. Lock times use the
+// wall clock, not Now, so a frozen clock cannot keep a lock alive.
+func (r *MongoRepository) AcquireLock(ctx context.Context, name string, holder string) (*Lock, error) {
+	now := time.Now()
+	filter := bson.M{"_id": name, "expires_at": bson.M{"$lt": now}}
+	update := bson.M{"$set": bson.M{
+		"holder":      holder,
+		"acquired_at": now,
+		"expires_at":  now.Add(lockTTL),
+	}}
+
+	_, err := r.locksCollection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		var held lockDoc
+		if err := r.locksCollection().FindOne(ctx, bson.M{"_id": name}).Decode(&held); err != nil {
+			return nil, fmt.Errorf("%s lock is held: %w", name, err)
+		}
+		return nil, &LockHeldError{Name: name, Holder: held.Holder, ExpiresAt: held.ExpiresAt}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeatCtx, stop := context.WithCancel(context.Background())
+	lock := &Lock{name: name, holder: holder, stop: stop, done: make(chan struct{})}
+	lock.release = func(ctx context.Context) error {
+		_, err := r.locksCollection().DeleteOne(ctx, bson.M{"_id": name, "holder": holder})
+		return err
+	}
+	go lock.heartbeat(heartbeatCtx, r.locksCollection())
+	return lock, nil
+}
+
+// WaitForLock is the AcquireLock of locks retried until the lock is free or
+// ctx is done. onWait is called with the holder the first time the lock is
+// found taken.
+func WaitForLock(ctx context.Context, locks TodoRepository, name string, holder string, onWait func(*LockHeldError)) (*Lock, error) {
+	waiting := false
+	for {
+		lock, err := locks.AcquireLock(ctx, name, holder)
+		var held *LockHeldError
+		if !errors.As(err, &held) {
+			return lock, err
+		}
+		if !waiting && onWait != nil {
+			onWait(held)
+		}
+		waiting = true
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (l *Lock) heartbeat(ctx context.Context, locks *mongo.Collection) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		filter := bson.M{"_id": l.name, "holder": l.holder}
+		update := bson.M{"$set": bson.M{"expires_at": time.Now().Add(lockTTL)}}
+		res, err := locks.UpdateOne(ctx, filter, update)
+		if err != nil {
+			log.Printf("Extending %s lock failed: %v", l.name, err)
+			continue
+		}
+		if res.MatchedCount == 0 {
+			log.Printf("Lost %s lock, it expired and was taken over", l.name)
+			return
+		}
+	}
+}
+
+// Release stops the heartbeat and frees the lock if it is still ours.
+func (l *Lock) Release(ctx context.Context) error {
+	l.stop()
+	<-l.done
+
+	return l.release(ctx)
+}
@@ -0,0 +1,187 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// useTestDatabase returns a repository on a scratch database on the MongoDB
+// at TEST_MONGO_URI, and drops the database when the test ends. Tests
+// needing MongoDB are skipped when TEST_MONGO_URI is unset.
+func useTestDatabase(t testing.TB) *MongoRepository {
+	t.Helper()
+
+	return useDatabaseAt(t, "TEST_MONGO_URI")
+}
+
+// useDatabaseAt is useTestDatabase for the MongoDB at the URI in the
+// environment variable env.
+func useDatabaseAt(t testing.TB, env string) *MongoRepository {
+	t.Helper()
+
+	uri := os.Getenv(env)
+	if uri == "" {
+		t.Skip(env + " is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database("todos_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := db.Drop(ctx); err != nil {
+			t.Error(err)
+		}
+		if err := client.Disconnect(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+	return NewMongoRepository(db.Collection("todos"))
+}
+
+// shortenLocks makes locks expire and waiters retry quickly for the rest of
+// the test.
+func shortenLocks(t *testing.T) {
+	ttl, poll := lockTTL, lockPollInterval
+	lockTTL, lockPollInterval = 300*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() {
+		lockTTL, lockPollInterval = ttl, poll
+	})
+}
+
+func TestLockContention(t *testing.T) {
+	repo := useTestDatabase(t)
+	shortenLocks(t)
+	ctx := context.Background()
+
+	first, err := repo.AcquireLock(ctx, BatchLock, "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.AcquireLock(ctx, BatchLock, "migrate")
+	var held *LockHeldError
+	if !errors.As(err, &held) || held.Holder != "import" {
+		t.Fatalf("acquiring a held lock: got %v, want it held by import", err)
+	}
+
+	// The heartbeat keeps the lock held well past its TTL.
+	waitCtx, cancel := context.WithTimeout(ctx, 3*lockTTL)
+	defer cancel()
+	if lock, err := WaitForLock(waitCtx, repo, BatchLock, "migrate", nil); err == nil {
+		lock.Release(ctx)
+		t.Fatal("got a lock whose holder is still heartbeating")
+	}
+
+	waited := make(chan *LockHeldError, 1)
+	acquired := make(chan error, 1)
+	go func() {
+		lock, err := WaitForLock(ctx, repo, BatchLock, "migrate", func(held *LockHeldError) {
+			waited <- held
+		})
+		if err == nil {
+			err = lock.Release(ctx)
+		}
+		acquired <- err
+	}()
+	if held := <-waited; held.Holder != "import" {
+		t.Errorf("waiting on %q, want import", held.Holder)
+	}
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the waiter did not get the released lock")
+	}
+}
+
+func TestLockAcquiredOnce(t *testing.T) {
+	repo := useTestDatabase(t)
+	shortenLocks(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	locks := make(chan *Lock, 8)
+	for i := range cap(locks) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := repo.AcquireLock(ctx, BatchLock, fmt.Sprintf("job %d", i))
+			var held *LockHeldError
+			if err != nil && !errors.As(err, &held) {
+				t.Error(err)
+				return
+			}
+			if err == nil {
+				locks <- lock
+			}
+		}()
+	}
+	wg.Wait()
+	close(locks)
+
+	var holders int
+	for lock := range locks {
+		holders++
+		if err := lock.Release(ctx); err != nil {
+			t.Error(err)
+		}
+	}
+	if holders != 1 {
+		t.Errorf("%d concurrent jobs got the lock, want 1", holders)
+	}
+}
+
+func TestLockTakeoverAfterExpiry(t *testing.T) {
+	repo := useTestDatabase(t)
+	shortenLocks(t)
+	ctx := context.Background()
+
+	crashed, err := repo.AcquireLock(ctx, BatchLock, "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A crashed holder stops heartbeating without releasing.
+	crashed.stop()
+	<-crashed.done
+
+	time.Sleep(2 * lockTTL)
+	next, err := repo.AcquireLock(ctx, BatchLock, "migrate")
+	if err != nil {
+		t.Fatalf("acquiring an expired lock: %v", err)
+	}
+	defer func() {
+		if err := next.Release(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// The old holder coming back must not free the lock it lost.
+	if err := crashed.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.AcquireLock(ctx, BatchLock, "fsck")
+	var held *LockHeldError
+	if !errors.As(err, &held) || held.Holder != "migrate" {
+		t.Fatalf("acquiring a taken-over lock: got %v, want it held by migrate", err)
+	}
+}
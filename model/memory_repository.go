@@ -0,0 +1,1560 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// duplicateKeyCode is the server error code for a unique index violation.
+const duplicateKeyCode = 11000
+
+// MemoryRepository is a TodoRepository keeping todos in memory, for tests
+// that should not need MongoDB. It makes the same checks as
+// MongoRepository except for dependency cycles, and neither records history
+// nor spawns the next occurrence of recurring todos. Locks are only shared
+// by users of the same MemoryRepository. Todos are stored and returned as
+// copies, so callers can modify what they pass and get freely.
+type MemoryRepository struct {
+	mu        sync.Mutex
+	todos     map[primitive.ObjectID]*Todo
+	notes     []*Note
+	templates map[primitive.ObjectID]*Template
+	defaults  map[string]*Defaults
+	locks     map[string]string
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		todos:     map[primitive.ObjectID]*Todo{},
+		templates: map[primitive.ObjectID]*Template{},
+		defaults:  map[string]*Defaults{},
+		locks:     map[string]string{},
+	}
+}
+
+// find returns the todo with the given id outside the trash, if the owner of
+// ctx may see it.
+func (r *MemoryRepository) find(ctx context.Context, id primitive.ObjectID) (*Todo, bool) {
+	t, ok := r.todos[id]
+	if !ok || t.DeletedAt != nil || !ownedBy(ctx, t) {
+		return nil, false
+	}
+	return t, true
+}
+
+func ownedBy(ctx context.Context, t *Todo) bool {
+	owner, ok := ownerFrom(ctx)
+	return !ok || t.Owner == owner
+}
+
+// pendingBlockers returns the todos among blockers that are still pending.
+func (r *MemoryRepository) pendingBlockers(blockers []primitive.ObjectID) error {
+	blocked := &BlockedError{}
+	for _, id := range blockers {
+		if b, ok := r.todos[id]; ok && b.DeletedAt == nil && !b.Completed {
+			blocked.BlockedBy = append(blocked.BlockedBy, id)
+		}
+	}
+	if len(blocked.BlockedBy) > 0 {
+		return blocked
+	}
+	return nil
+}
+
+func (r *MemoryRepository) checkBlockersExist(ctx context.Context, id primitive.ObjectID, blockers []primitive.ObjectID) error {
+	for _, b := range blockers {
+		if b == id {
+			return ErrDependencyCycle
+		}
+		if _, ok := r.find(ctx, b); !ok {
+			return ErrBlockerNotFound
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) Create(ctx context.Context, todo *Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prepareNewTodo(ctx, todo)
+
+	if err := r.validate(ctx, todo); err != nil {
+		return err
+	}
+	if _, ok := r.todos[todo.ID]; ok {
+		return ErrDuplicateTodo
+	}
+	if err := r.checkDuplicate(todo.ID, todo.Owner, todo.PendingText); err != nil {
+		return err
+	}
+
+	stored := *todo
+	r.todos[todo.ID] = &stored
+	return nil
+}
+
+// validate makes the checks of MongoRepository.Validate.
+func (r *MemoryRepository) validate(ctx context.Context, todo *Todo) error {
+	if todo.Recurrence != "" {
+		if _, err := ParseRecurrence(todo.Recurrence); err != nil {
+			return err
+		}
+	}
+	if todo.ParentID != nil {
+		if _, ok := r.find(ctx, *todo.ParentID); !ok {
+			return ErrParentNotFound
+		}
+	}
+	if err := r.checkBlockersExist(ctx, todo.ID, todo.BlockedBy); err != nil {
+		return err
+	}
+	if todo.Completed {
+		return r.pendingBlockers(todo.BlockedBy)
+	}
+	return nil
+}
+
+// checkDuplicate returns ErrDuplicateTodo if a todo of owner other than id
+// holds pendingText, as the unique index on pending_text does.
+func (r *MemoryRepository) checkDuplicate(id primitive.ObjectID, owner string, pendingText string) error {
+	if pendingText == "" {
+		return nil
+	}
+	for _, t := range r.todos {
+		if t.ID != id && t.PendingText == pendingText && t.Owner == owner {
+			return ErrDuplicateTodo
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) Validate(ctx context.Context, todo *Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.validate(ctx, todo)
+}
+
+func (r *MemoryRepository) CreateMany(ctx context.Context, todos []*Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var bulkErr mongo.BulkWriteException
+	for i, todo := range todos {
+		prepareNewTodo(ctx, todo)
+
+		_, taken := r.todos[todo.ID]
+		if taken || r.checkDuplicate(todo.ID, todo.Owner, todo.PendingText) != nil {
+			bulkErr.WriteErrors = append(bulkErr.WriteErrors, mongo.BulkWriteError{
+				WriteError: mongo.WriteError{Index: i, Code: duplicateKeyCode, Message: ErrDuplicateTodo.Error()},
+			})
+			continue
+		}
+		stored := *todo
+		r.todos[todo.ID] = &stored
+	}
+	if len(bulkErr.WriteErrors) > 0 {
+		return bulkErr
+	}
+	return nil
+}
+
+// all returns the stored todos in id order, the order MongoDB returns them
+// in without a sort.
+func (r *MemoryRepository) all() []*Todo {
+	todos := make([]*Todo, 0, len(r.todos))
+	for _, t := range r.todos {
+		todos = append(todos, t)
+	}
+	slices.SortFunc(todos, compareBySort(IDSort()))
+	return todos
+}
+
+// listed reports whether t is outside the archive, and the trash unless ctx
+// includes deleted todos, and the owner of ctx may see it.
+func listed(ctx context.Context, t *Todo) bool {
+	return (t.DeletedAt == nil || deletedIncluded(ctx)) && !t.Archived && ownedBy(ctx, t)
+}
+
+// copies returns copies of todos, never nil.
+func copies(todos []*Todo) []*Todo {
+	out := make([]*Todo, len(todos))
+	for i, t := range todos {
+		c := *t
+		out[i] = &c
+	}
+	return out
+}
+
+func (r *MemoryRepository) GetByText(ctx context.Context, text string) (*Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.all() {
+		if t.DeletedAt == nil && ownedBy(ctx, t) && (t.NormalizedText == MatchKey(text) || t.Text == text) {
+			found := *t
+			return &found, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (r *MemoryRepository) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todos := []*Todo{}
+	for _, id := range ids {
+		if t, ok := r.find(ctx, id); ok {
+			todos = append(todos, t)
+		}
+	}
+	return copies(todos), nil
+}
+
+func (r *MemoryRepository) GetByID(ctx context.Context, id string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.find(ctx, objectId)
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	found := *t
+	return &found, nil
+}
+
+// matches reports whether t is listed for query, leaving out the cursor.
+func (query TodoQuery) matches(t *Todo) bool {
+	switch {
+	case query.Finished:
+		if !t.Completed {
+			return false
+		}
+	case query.Overdue:
+		if t.Completed || t.DueDate == nil || !t.DueDate.Before(Now()) {
+			return false
+		}
+	case query.Stale:
+		if t.Completed || t.StaleSince == nil {
+			return false
+		}
+	default:
+		if t.Archived && !query.IncludeArchived {
+			return false
+		}
+	}
+	if query.Awake && t.SnoozedUntil != nil && t.SnoozedUntil.After(Now()) {
+		return false
+	}
+	if query.Completed != nil && t.Completed != *query.Completed {
+		return false
+	}
+	if query.CreatedAfter != nil && t.CreatedAt.Before(*query.CreatedAfter) {
+		return false
+	}
+	if query.CreatedBefore != nil && t.CreatedAt.After(*query.CreatedBefore) {
+		return false
+	}
+	if query.UpdatedAfter != nil && t.UpdatedAt.Before(*query.UpdatedAfter) {
+		return false
+	}
+	if query.Tag != "" && !slices.Contains(t.Tags, strings.ToLower(NormalizeText(query.Tag))) {
+		return false
+	}
+	if query.Project != "" && t.Project != NormalizeProject(query.Project) {
+		return false
+	}
+	return true
+}
+
+func (r *MemoryRepository) List(ctx context.Context, query TodoQuery) ([]*Todo, int64, error) {
+	sort := PinnedSort()
+	if query.Finished {
+		sort = FinishedSort()
+	}
+	if query.ByCursor {
+		sort = IDSort()
+	} else if query.Sort != "" {
+		var err error
+		if sort, err = ParseSort(query.Sort); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var todos []*Todo
+	var total int64
+	for _, t := range r.todos {
+		if (t.DeletedAt != nil && !deletedIncluded(ctx)) || !ownedBy(ctx, t) || !query.matches(t) {
+			continue
+		}
+		total++
+		if query.ByCursor && !query.After.IsZero() && t.ID.Hex() <= query.After.Hex() {
+			continue
+		}
+		listed := *t
+		todos = append(todos, &listed)
+	}
+	slices.SortFunc(todos, compareBySort(sort))
+
+	if !query.ByCursor {
+		todos = todos[min(query.Offset, len(todos)):]
+	}
+	if query.Limit > 0 && len(todos) > query.Limit {
+		todos = todos[:query.Limit]
+	}
+	if todos == nil {
+		todos = []*Todo{}
+	}
+	return todos, total, nil
+}
+
+func (r *MemoryRepository) Update(ctx context.Context, id string, todo *Todo) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	if todo.Recurrence != "" {
+		if _, err := ParseRecurrence(todo.Recurrence); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.find(ctx, objectId)
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+	if version, conditional := versionFrom(ctx); conditional && !sameVersion(t.UpdatedAt, version) {
+		return ErrVersionMismatch
+	}
+	if !slices.Equal(t.BlockedBy, todo.BlockedBy) {
+		if err := r.checkBlockersExist(ctx, objectId, todo.BlockedBy); err != nil {
+			return err
+		}
+	}
+	if todo.Completed && !t.Completed {
+		if err := r.pendingBlockers(todo.BlockedBy); err != nil {
+			return err
+		}
+		now := Now()
+		t.CompletedAt = &now
+	} else if !todo.Completed {
+		t.CompletedAt = nil
+	}
+	if todo.Completed {
+		t.PendingText = ""
+		cancelReminders(t)
+	} else if t.PendingText != "" {
+		t.PendingText = MatchKey(todo.Text)
+	}
+
+	t.Completed = todo.Completed
+	t.Text = NormalizeText(todo.Text)
+	t.NormalizedText = MatchKey(todo.Text)
+	t.UpdatedAt = Now()
+	t.StaleSince = nil
+	t.DueDate = todo.DueDate
+	t.Tags = NormalizeTags(todo.Tags)
+	t.Project = NormalizeProject(todo.Project)
+	t.BlockedBy = todo.BlockedBy
+	t.EstimateMinutes = max(todo.EstimateMinutes, 0)
+	t.Color = NormalizeColor(todo.Color)
+	t.Recurrence = todo.Recurrence
+	if todo.RemindAt == nil || t.RemindAt == nil || !t.RemindAt.Equal(*todo.RemindAt) {
+		t.RemindedAt = nil
+	}
+	t.RemindAt = todo.RemindAt
+	return nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, id string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.find(ctx, objectId)
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	for _, child := range r.todos {
+		if child.ParentID != nil && *child.ParentID == objectId && child.DeletedAt == nil {
+			return nil, ErrHasChildren
+		}
+	}
+
+	deleted := *t
+	now := Now()
+	t.DeletedAt = &now
+	t.PendingText = ""
+	cancelReminders(t)
+	return &deleted, nil
+}
+
+func (r *MemoryRepository) Complete(ctx context.Context, id string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.find(ctx, objectId)
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	if !t.Completed {
+		if err := r.pendingBlockers(t.BlockedBy); err != nil {
+			return nil, err
+		}
+		complete(t)
+	}
+
+	completed := *t
+	return &completed, nil
+}
+
+// complete marks t completed now.
+func complete(t *Todo) {
+	now := Now()
+	t.Completed = true
+	t.CompletedAt = &now
+	t.UpdatedAt = now
+	t.PendingText = ""
+	cancelReminders(t)
+}
+
+// cancelReminders drops the reminders of t that have not fired yet.
+func cancelReminders(t *Todo) {
+	t.Reminders = nil
+	t.NextReminderAt = nil
+}
+
+func (r *MemoryRepository) Stream(ctx context.Context, fn func(*Todo) error) (int64, error) {
+	r.mu.Lock()
+	var todos []*Todo
+	for _, t := range r.all() {
+		if listed(ctx, t) {
+			todos = append(todos, t)
+		}
+	}
+	todos = copies(todos)
+	r.mu.Unlock()
+
+	var count int64
+	for _, t := range todos {
+		if err := fn(t); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Search matches the words of query against the normalized texts, the way
+// the text index does but without stemming, and returns the matches in id
+// order rather than by relevance.
+func (r *MemoryRepository) Search(ctx context.Context, query string, completed *bool) ([]*Todo, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+	words := strings.Fields(MatchKey(query))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var todos []*Todo
+	for _, t := range r.all() {
+		if !listed(ctx, t) || (completed != nil && t.Completed != *completed) {
+			continue
+		}
+		if slices.ContainsFunc(words, func(word string) bool { return strings.Contains(t.NormalizedText, word) }) {
+			todos = append(todos, t)
+		}
+	}
+	return copies(todos), nil
+}
+
+func (r *MemoryRepository) Children(ctx context.Context, id string) ([]*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var todos []*Todo
+	for _, t := range r.all() {
+		if t.ParentID != nil && *t.ParentID == objectId && t.DeletedAt == nil && ownedBy(ctx, t) {
+			todos = append(todos, t)
+		}
+	}
+	return copies(todos), nil
+}
+
+func (r *MemoryRepository) Blockers(ctx context.Context, id string) ([]*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.find(ctx, objectId)
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	var todos []*Todo
+	for _, t := range r.all() {
+		if slices.Contains(todo.BlockedBy, t.ID) && t.DeletedAt == nil && !t.Completed && ownedBy(ctx, t) {
+			todos = append(todos, t)
+		}
+	}
+	return copies(todos), nil
+}
+
+func (r *MemoryRepository) Duplicates(ctx context.Context, threshold float64) ([]*DuplicateCluster, error) {
+	r.mu.Lock()
+	var todos []*Todo
+	for _, t := range r.all() {
+		if listed(ctx, t) && !t.Completed && len(todos) < maxDuplicateCandidates {
+			todos = append(todos, t)
+		}
+	}
+	todos = copies(todos)
+	r.mu.Unlock()
+
+	return clusterDuplicates(ctx, todos, threshold)
+}
+
+func (r *MemoryRepository) Stats(ctx context.Context) (*TodoStats, error) {
+	w := currentStatsWindow()
+	stats, days, weeks := w.empty()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.todos {
+		if !listed(ctx, t) {
+			continue
+		}
+
+		stats.Total++
+		if t.Completed {
+			stats.Completed++
+		} else if t.DueDate != nil && t.DueDate.Before(w.now) {
+			stats.Overdue++
+		}
+		stats.EstimateMinutes += int64(t.EstimateMinutes)
+		stats.SpentMinutes += int64(t.SpentMinutes)
+		if day, ok := days[t.CreatedAt.UTC().Format(time.DateOnly)]; ok {
+			day.Created++
+		}
+		if t.CompletedAt == nil {
+			continue
+		}
+		if day, ok := days[t.CompletedAt.UTC().Format(time.DateOnly)]; ok {
+			day.Completed++
+		}
+		if week, ok := weeks[isoWeek(t.CompletedAt.UTC())]; ok {
+			week.EstimateMinutes += int64(t.EstimateMinutes)
+			week.SpentMinutes += int64(t.SpentMinutes)
+		}
+	}
+	stats.Pending = stats.Total - stats.Completed
+	return stats, nil
+}
+
+func (r *MemoryRepository) Workload(ctx context.Context, query WorkloadQuery) (*Workload, error) {
+	w, err := newWorkloadWindow(query)
+	if err != nil {
+		return nil, err
+	}
+	workload, days := w.empty()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.todos {
+		if !listed(ctx, t) || t.Completed || t.DueDate == nil || !t.DueDate.Before(w.end) {
+			continue
+		}
+		if t.EstimateMinutes > 0 {
+			w.add(days, w.dueDay(*t.DueDate), int64(t.EstimateMinutes), 1, 0)
+		} else {
+			w.add(days, w.dueDay(*t.DueDate), int64(w.query.DefaultEstimate), 1, 1)
+		}
+	}
+	return workload, nil
+}
+
+func (r *MemoryRepository) Projects(ctx context.Context) ([]*ProjectSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := map[string]int64{}
+	for _, t := range r.todos {
+		if !listed(ctx, t) || t.Project == "" {
+			continue
+		}
+		pending[t.Project] += 0
+		if !t.Completed {
+			pending[t.Project]++
+		}
+	}
+
+	projects := make([]*ProjectSummary, 0, len(pending))
+	for name, n := range pending {
+		projects = append(projects, &ProjectSummary{Name: name, Pending: n})
+	}
+	slices.SortFunc(projects, func(a *ProjectSummary, b *ProjectSummary) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return projects, nil
+}
+
+func (r *MemoryRepository) DeleteProject(ctx context.Context, project string, strategy ProjectStrategy, dryRun bool) (*ProjectDeletion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	project = NormalizeProject(project)
+	result := &ProjectDeletion{Project: project, Strategy: strategy, DryRun: dryRun}
+	var todos []*Todo
+	for _, t := range r.all() {
+		if t.Project == project && t.DeletedAt == nil && ownedBy(ctx, t) {
+			todos = append(todos, t)
+		}
+	}
+	if len(todos) == 0 {
+		return nil, ErrProjectNotFound
+	}
+	result.Todos = int64(len(todos))
+	if dryRun {
+		return result, nil
+	}
+	if _, err := ParseProjectStrategy(string(strategy)); err != nil {
+		return nil, err
+	}
+
+	parents := map[primitive.ObjectID]bool{}
+	for _, t := range r.todos {
+		if t.ParentID != nil && t.DeletedAt == nil && t.Project != project {
+			parents[*t.ParentID] = true
+		}
+	}
+	now := Now()
+	for _, t := range todos {
+		switch {
+		case strategy == ProjectOrphan:
+			t.Project = ""
+			t.UpdatedAt = now
+		case strategy == ProjectArchive && !t.Archived:
+			t.Archived = true
+			t.UpdatedAt = now
+		case strategy == ProjectTrash && parents[t.ID]:
+			result.Kept++
+			continue
+		case strategy == ProjectTrash:
+			t.DeletedAt = &now
+			t.PendingText = ""
+			cancelReminders(t)
+		default:
+			continue
+		}
+		result.Changed++
+	}
+	return result, nil
+}
+
+func (r *MemoryRepository) RenameProject(ctx context.Context, from string, to string, merge bool) (*ProjectRename, error) {
+	from, to = NormalizeProject(from), NormalizeProject(to)
+	if to == "" {
+		return nil, ErrEmptyProject
+	}
+	if from == to {
+		return nil, ErrSameProject
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &ProjectRename{From: from, To: to}
+	for _, t := range r.todos {
+		if t.Project == to && t.DeletedAt == nil && ownedBy(ctx, t) {
+			result.Merged = true
+		}
+	}
+	if result.Merged && !merge {
+		return nil, ErrProjectExists
+	}
+
+	now := Now()
+	for _, t := range r.todos {
+		if t.Project == from && ownedBy(ctx, t) {
+			t.Project = to
+			t.UpdatedAt = now
+			result.Renamed++
+		}
+	}
+	if result.Renamed == 0 {
+		return nil, ErrProjectNotFound
+	}
+
+	owner, _ := ownerFrom(ctx)
+	if d, ok := r.defaults[owner]; ok {
+		if d.Project == from {
+			d.Project = to
+		}
+		if p := d.ForProject(from); p != nil && d.ForProject(to) == nil {
+			p.Project = to
+		}
+		d.RemoveProject(from)
+	}
+	return result, nil
+}
+
+// CompleteMany never creates next occurrences, so it returns none.
+func (r *MemoryRepository) CompleteMany(ctx context.Context, ids []primitive.ObjectID) (int64, []primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var completed int64
+	for _, id := range ids {
+		t, ok := r.find(ctx, id)
+		if !ok || t.Completed || r.pendingBlockers(t.BlockedBy) != nil {
+			continue
+		}
+		complete(t)
+		completed++
+	}
+	return completed, nil, nil
+}
+
+func (r *MemoryRepository) CompleteWithFollowup(ctx context.Context, id string, followup *Todo) (*Todo, *Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	original, ok := r.find(ctx, objectId)
+	if !ok {
+		return nil, nil, mongo.ErrNoDocuments
+	}
+	if original.Completed {
+		return nil, nil, ErrAlreadyCompleted
+	}
+	if err := r.pendingBlockers(original.BlockedBy); err != nil {
+		return nil, nil, err
+	}
+
+	if followup.Project == "" {
+		followup.Project = original.Project
+	}
+	if followup.Tags == nil {
+		followup.Tags = original.Tags
+	}
+	followup.ID = primitive.NewObjectID()
+	followup.CreatedAt = Now()
+	followup.UpdatedAt = followup.CreatedAt
+	followup.Completed = false
+	followup.FollowupOf = &original.ID
+	followup.Owner = original.Owner
+	followup.Text = NormalizeText(followup.Text)
+	followup.NormalizedText = MatchKey(followup.Text)
+	followup.Tags = NormalizeTags(followup.Tags)
+	followup.Project = NormalizeProject(followup.Project)
+	followup.Position = initialPosition(followup)
+	followup.PendingText = ""
+	if RejectDuplicates() {
+		followup.PendingText = followup.NormalizedText
+	}
+	if err := r.validate(ctx, followup); err != nil {
+		return nil, nil, err
+	}
+	if err := r.checkDuplicate(followup.ID, followup.Owner, followup.PendingText); err != nil {
+		return nil, nil, err
+	}
+
+	complete(original)
+	stored := *followup
+	r.todos[followup.ID] = &stored
+	completed := *original
+	return &completed, followup, nil
+}
+
+func (r *MemoryRepository) Reopen(ctx context.Context, id string) (*Todo, error) {
+	return r.modify(ctx, id, func(t *Todo) error {
+		if !t.Completed {
+			return nil
+		}
+		if RejectDuplicates() {
+			if err := r.checkDuplicate(t.ID, t.Owner, MatchKey(t.Text)); err != nil {
+				return err
+			}
+			t.PendingText = MatchKey(t.Text)
+		}
+		t.Completed = false
+		t.CompletedAt = nil
+		t.UpdatedAt = Now()
+		return nil
+	})
+}
+
+// modify applies fn to the todo with the given id outside the trash and
+// returns a copy of it as updated. A missing todo is mongo.ErrNoDocuments.
+func (r *MemoryRepository) modify(ctx context.Context, id string, fn func(t *Todo) error) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.find(ctx, objectId)
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	if err := fn(t); err != nil {
+		return nil, err
+	}
+	updated := *t
+	return &updated, nil
+}
+
+func (r *MemoryRepository) SetArchived(ctx context.Context, id string, archived bool) (*Todo, error) {
+	return r.modify(ctx, id, func(t *Todo) error {
+		t.Archived = archived
+		t.UpdatedAt = Now()
+		return nil
+	})
+}
+
+func (r *MemoryRepository) SetPinned(ctx context.Context, id string, pinned bool) (*Todo, error) {
+	return r.modify(ctx, id, func(t *Todo) error {
+		t.Pinned = pinned
+		t.UpdatedAt = Now()
+		return nil
+	})
+}
+
+func (r *MemoryRepository) SetPosition(ctx context.Context, id string, position float64) (*Todo, error) {
+	return r.modify(ctx, id, func(t *Todo) error {
+		t.Position = position
+		t.UpdatedAt = Now()
+		return nil
+	})
+}
+
+func (r *MemoryRepository) MoveAfter(ctx context.Context, id string, after string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	afterId, err := primitive.ObjectIDFromHex(after)
+	if err != nil {
+		return nil, err
+	}
+	if objectId == afterId {
+		return nil, ErrMoveAfterSelf
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for rebalanced := false; ; rebalanced = true {
+		position, ok, err := r.positionAfter(ctx, objectId, afterId)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			t, found := r.find(ctx, objectId)
+			if !found {
+				return nil, mongo.ErrNoDocuments
+			}
+			t.Position = position
+			t.UpdatedAt = Now()
+			moved := *t
+			return &moved, nil
+		}
+		if rebalanced {
+			return nil, fmt.Errorf("no room to move todo %s after %s", id, after)
+		}
+		r.rebalancePositions(ctx)
+	}
+}
+
+// positionAfter is the in-memory positionAfter.
+func (r *MemoryRepository) positionAfter(ctx context.Context, moving primitive.ObjectID, afterId primitive.ObjectID) (float64, bool, error) {
+	anchor, ok := r.find(ctx, afterId)
+	if !ok {
+		return 0, false, mongo.ErrNoDocuments
+	}
+
+	byPosition := compareBySort(PositionSort())
+	var next *Todo
+	for _, t := range r.todos {
+		if t.ID == moving || t.ID == afterId || t.DeletedAt != nil || !ownedBy(ctx, t) {
+			continue
+		}
+		if t.Position == anchor.Position {
+			return 0, false, nil
+		}
+		if t.Position > anchor.Position && (next == nil || byPosition(t, next) < 0) {
+			next = t
+		}
+	}
+	if next == nil {
+		return anchor.Position + positionGap, true, nil
+	}
+
+	position := anchor.Position + (next.Position-anchor.Position)/2
+	return position, position > anchor.Position && position < next.Position, nil
+}
+
+// rebalancePositions is the in-memory rebalancePositions.
+func (r *MemoryRepository) rebalancePositions(ctx context.Context) {
+	var todos []*Todo
+	for _, t := range r.todos {
+		if t.DeletedAt == nil && ownedBy(ctx, t) {
+			todos = append(todos, t)
+		}
+	}
+	slices.SortFunc(todos, compareBySort(PositionSort()))
+	for i, t := range todos {
+		t.Position = float64(i+1) * positionGap
+	}
+}
+
+func (r *MemoryRepository) LogTime(ctx context.Context, id string, minutes int) (*Todo, error) {
+	if minutes <= 0 {
+		return nil, ErrInvalidMinutes
+	}
+	return r.modify(ctx, id, func(t *Todo) error {
+		t.SpentMinutes += minutes
+		t.UpdatedAt = Now()
+		return nil
+	})
+}
+
+func (r *MemoryRepository) Snooze(ctx context.Context, id string, until time.Time) (*Todo, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	if !until.After(Now()) {
+		return nil, ErrSnoozeInPast
+	}
+	return r.modify(ctx, id, func(t *Todo) error {
+		if t.Completed {
+			return ErrAlreadyCompleted
+		}
+		t.SnoozedUntil = &until
+		t.UpdatedAt = Now()
+		return nil
+	})
+}
+
+func (r *MemoryRepository) AddReminder(ctx context.Context, id string, at time.Time) (*Todo, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	at, err := reminderTime(at)
+	if err != nil {
+		return nil, err
+	}
+	return r.modify(ctx, id, func(t *Todo) error {
+		if t.Completed || len(t.Reminders) >= MaxReminders || slices.ContainsFunc(t.Reminders, at.Equal) {
+			return reminderRefused(t, at)
+		}
+		reminders := append(slices.Clone(t.Reminders), at)
+		slices.SortFunc(reminders, time.Time.Compare)
+		t.Reminders = reminders
+		t.NextReminderAt = &reminders[0]
+		t.UpdatedAt = Now()
+		return nil
+	})
+}
+
+func (r *MemoryRepository) RemoveReminder(ctx context.Context, id string, at time.Time) (*Todo, error) {
+	at = at.Truncate(time.Millisecond)
+	return r.modify(ctx, id, func(t *Todo) error {
+		i := slices.IndexFunc(t.Reminders, at.Equal)
+		if i < 0 {
+			return ErrReminderNotFound
+		}
+		t.Reminders = slices.Delete(slices.Clone(t.Reminders), i, i+1)
+		t.NextReminderAt = nil
+		if len(t.Reminders) > 0 {
+			t.NextReminderAt = &t.Reminders[0]
+		}
+		t.UpdatedAt = Now()
+		return nil
+	})
+}
+
+func (r *MemoryRepository) Deleted(ctx context.Context) ([]*Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var todos []*Todo
+	for _, t := range r.all() {
+		if t.DeletedAt != nil && ownedBy(ctx, t) {
+			todos = append(todos, t)
+		}
+	}
+	return copies(todos), nil
+}
+
+func (r *MemoryRepository) Restore(ctx context.Context, id string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.todos[objectId]
+	if !ok || t.DeletedAt == nil || !ownedBy(ctx, t) {
+		return nil, ErrTodoNotInTrash
+	}
+	t.DeletedAt = nil
+	t.UpdatedAt = Now()
+	restored := *t
+	return &restored, nil
+}
+
+func (r *MemoryRepository) Replace(ctx context.Context, todo *Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.todos[todo.ID]; ok && !ownedBy(ctx, t) {
+		return ErrDuplicateTodo
+	}
+	stored := *todo
+	r.todos[todo.ID] = &stored
+	return nil
+}
+
+func (r *MemoryRepository) DeleteCompleted(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	parents := map[primitive.ObjectID]bool{}
+	for _, t := range r.todos {
+		if t.ParentID != nil && t.DeletedAt == nil {
+			parents[*t.ParentID] = true
+		}
+	}
+
+	var deleted int64
+	for id, t := range r.todos {
+		if t.Completed && ownedBy(ctx, t) && !parents[id] {
+			delete(r.todos, id)
+			r.dropNotes(id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryRepository) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := Now().Add(-olderThan)
+	var purged int64
+	for id, t := range r.todos {
+		if t.DeletedAt != nil && t.DeletedAt.Before(cutoff) {
+			delete(r.todos, id)
+			r.dropNotes(id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *MemoryRepository) PurgeTodo(ctx context.Context, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.todos[objectId]
+	if !ok || t.DeletedAt == nil || !ownedBy(ctx, t) {
+		return ErrTodoNotInTrash
+	}
+	delete(r.todos, objectId)
+	r.dropNotes(objectId)
+	return nil
+}
+
+func (r *MemoryRepository) Reassign(ctx context.Context, from string, to string, query TodoQuery, dryRun bool) (*ReassignResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &ReassignResult{From: from, To: to, DryRun: dryRun}
+	tag := strings.ToLower(NormalizeText(query.Tag))
+	for _, t := range r.todos {
+		if t.Owner != from ||
+			(query.Project != "" && t.Project != NormalizeProject(query.Project)) ||
+			(query.Tag != "" && !slices.Contains(t.Tags, tag)) {
+			continue
+		}
+		result.Matched++
+		if dryRun {
+			continue
+		}
+		t.Owner = to
+		t.UpdatedAt = Now()
+		result.Reassigned++
+	}
+	return result, nil
+}
+
+func (r *MemoryRepository) MarkStale(ctx context.Context, olderThan time.Duration, dryRun bool) (*AgingResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := Now()
+	result := &AgingResult{Cutoff: now.Add(-olderThan), DryRun: dryRun}
+	for _, t := range r.all() {
+		if t.DeletedAt != nil || t.Completed || t.StaleSince != nil || !t.UpdatedAt.Before(result.Cutoff) {
+			continue
+		}
+		result.Matched++
+		if dryRun {
+			if len(result.Preview) < maxAgingPreview {
+				result.Preview = append(result.Preview, t.ID)
+			}
+			continue
+		}
+		t.StaleSince = &now
+		result.Marked++
+	}
+	return result, nil
+}
+
+func (r *MemoryRepository) findByHex(ctx context.Context, id string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := r.find(ctx, objectId)
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return t, nil
+}
+
+// page returns the items of s from offset on, at most limit of them unless
+// limit is 0.
+func page[T any](s []T, offset int64, limit int64) []T {
+	if offset >= int64(len(s)) {
+		return []T{}
+	}
+	s = s[offset:]
+	if limit > 0 && limit < int64(len(s)) {
+		s = s[:limit]
+	}
+	return s
+}
+
+// dropNotes removes the notes of the todo with the given id.
+func (r *MemoryRepository) dropNotes(id primitive.ObjectID) {
+	r.notes = slices.DeleteFunc(r.notes, func(n *Note) bool {
+		return n.TodoID == id
+	})
+}
+
+func (r *MemoryRepository) AddNote(ctx context.Context, todoId string, author string, text string) (*Note, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, err := r.findByHex(ctx, todoId)
+	if err != nil {
+		return nil, err
+	}
+	note := &Note{
+		ID:        primitive.NewObjectID(),
+		TodoID:    todo.ID,
+		Author:    author,
+		Text:      NormalizeText(text),
+		CreatedAt: Now(),
+	}
+	stored := *note
+	r.notes = append(r.notes, &stored)
+	return note, nil
+}
+
+func (r *MemoryRepository) CreateNotes(ctx context.Context, notes []*Note) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, note := range notes {
+		stored := *note
+		r.notes = append(r.notes, &stored)
+	}
+	return nil
+}
+
+// newestNotes returns the notes of the todo with the given id, newest first.
+func (r *MemoryRepository) newestNotes(id primitive.ObjectID) []*Note {
+	var notes []*Note
+	for _, note := range r.notes {
+		if note.TodoID == id {
+			copied := *note
+			notes = append(notes, &copied)
+		}
+	}
+	slices.SortStableFunc(notes, func(a *Note, b *Note) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return notes
+}
+
+func (r *MemoryRepository) Notes(ctx context.Context, todoId string, offset int64, limit int64) ([]*Note, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, err := r.findByHex(ctx, todoId)
+	if err != nil {
+		return nil, err
+	}
+	return page(r.newestNotes(todo.ID), offset, limit), nil
+}
+
+func (r *MemoryRepository) LatestNotes(ctx context.Context, todoIds []primitive.ObjectID) (map[primitive.ObjectID]*Note, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	latest := map[primitive.ObjectID]*Note{}
+	for _, id := range todoIds {
+		if notes := r.newestNotes(id); len(notes) > 0 {
+			latest[id] = notes[0]
+		}
+	}
+	return latest, nil
+}
+
+func (r *MemoryRepository) DeleteNote(ctx context.Context, todoId string, noteId string) error {
+	todoObjectId, err := primitive.ObjectIDFromHex(todoId)
+	if err != nil {
+		return err
+	}
+	noteObjectId, err := primitive.ObjectIDFromHex(noteId)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.notes, func(n *Note) bool {
+		return n.ID == noteObjectId && n.TodoID == todoObjectId
+	})
+	if i < 0 {
+		return ErrNoteNotFound
+	}
+	r.notes = slices.Delete(r.notes, i, i+1)
+	return nil
+}
+
+// History is always empty, as MemoryRepository records none.
+func (r *MemoryRepository) History(ctx context.Context, todoId string, offset int64, limit int64) ([]*HistoryEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.findByHex(ctx, todoId); err != nil {
+		return nil, err
+	}
+	return []*HistoryEntry{}, nil
+}
+
+func (r *MemoryRepository) HistorySummary(ctx context.Context, id primitive.ObjectID) (*HistorySummary, error) {
+	return nil, nil
+}
+
+func (r *MemoryRepository) Defaults(ctx context.Context) (*Defaults, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	owner, _ := ownerFrom(ctx)
+	d, ok := r.defaults[owner]
+	if !ok {
+		return &Defaults{Owner: owner, Projects: []ProjectDefaults{}}, nil
+	}
+	copied := *d
+	copied.Projects = slices.Clone(d.Projects)
+	return &copied, nil
+}
+
+func (r *MemoryRepository) SetDefaults(ctx context.Context, d *Defaults) error {
+	d.Owner, _ = ownerFrom(ctx)
+	now := Now()
+	d.UpdatedAt = &now
+	normalizeDefaults(d)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *d
+	stored.Projects = slices.Clone(d.Projects)
+	r.defaults[d.Owner] = &stored
+	return nil
+}
+
+func (r *MemoryRepository) CreateTemplate(ctx context.Context, t *Template) error {
+	t.ID = primitive.NewObjectID()
+	t.CreatedAt = Now()
+	t.UpdatedAt = t.CreatedAt
+	t.Owner, _ = ownerFrom(ctx)
+	normalizeTemplate(t)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.templateNamed(t.Owner, t.Name, t.ID) != nil {
+		return ErrDuplicateTemplate
+	}
+	stored := *t
+	r.templates[t.ID] = &stored
+	return nil
+}
+
+// templateNamed returns the template of owner with the given name other
+// than the one with id, if there is one.
+func (r *MemoryRepository) templateNamed(owner string, name string, id primitive.ObjectID) *Template {
+	for _, t := range r.templates {
+		if t.Owner == owner && t.Name == name && t.ID != id {
+			return t
+		}
+	}
+	return nil
+}
+
+func templateOwnedBy(ctx context.Context, t *Template) bool {
+	owner, ok := ownerFrom(ctx)
+	return !ok || t.Owner == owner
+}
+
+func (r *MemoryRepository) Templates(ctx context.Context) ([]*Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	templates := []*Template{}
+	for _, t := range r.templates {
+		if templateOwnedBy(ctx, t) {
+			copied := *t
+			templates = append(templates, &copied)
+		}
+	}
+	slices.SortFunc(templates, func(a *Template, b *Template) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return templates, nil
+}
+
+func (r *MemoryRepository) GetTemplateByID(ctx context.Context, id string) (*Template, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.templates[objectId]
+	if !ok || !templateOwnedBy(ctx, t) {
+		return nil, mongo.ErrNoDocuments
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (r *MemoryRepository) GetTemplateByName(ctx context.Context, name string) (*Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	owner, _ := ownerFrom(ctx)
+	t := r.templateNamed(owner, NormalizeText(name), primitive.NilObjectID)
+	if t == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (r *MemoryRepository) UpdateTemplate(ctx context.Context, id string, t *Template) (*Template, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	normalizeTemplate(t)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.templates[objectId]
+	if !ok || !templateOwnedBy(ctx, stored) {
+		return nil, mongo.ErrNoDocuments
+	}
+	if r.templateNamed(stored.Owner, t.Name, stored.ID) != nil {
+		return nil, ErrDuplicateTemplate
+	}
+	stored.Name = t.Name
+	stored.Items = t.Items
+	stored.UpdatedAt = Now()
+	updated := *stored
+	return &updated, nil
+}
+
+func (r *MemoryRepository) DeleteTemplate(ctx context.Context, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.templates[objectId]
+	if !ok || !templateOwnedBy(ctx, t) {
+		return mongo.ErrNoDocuments
+	}
+	delete(r.templates, objectId)
+	return nil
+}
+
+// AcquireLock takes the named lock for holder. Locks held in memory never
+// expire, so there is no heartbeat.
+func (r *MemoryRepository) AcquireLock(ctx context.Context, name string, holder string) (*Lock, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if held, ok := r.locks[name]; ok {
+		return nil, &LockHeldError{Name: name, Holder: held}
+	}
+	r.locks[name] = holder
+
+	done := make(chan struct{})
+	close(done)
+	lock := &Lock{name: name, holder: holder, stop: func() {}, done: done}
+	lock.release = func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.locks[name] == holder {
+			delete(r.locks, name)
+		}
+		return nil
+	}
+	return lock, nil
+}
+
+// sortValue returns the value of the field a bson sort key names, for
+// ordering todos in memory. Unknown fields compare as equal.
+func sortValue(t *Todo, field string) interface{} {
+	switch field {
+	case "_id":
+		return t.ID.Hex()
+	case "created_at":
+		return t.CreatedAt
+	case "updated_at":
+		return t.UpdatedAt
+	case "completed_at":
+		if t.CompletedAt == nil {
+			return time.Time{}
+		}
+		return *t.CompletedAt
+	case "text":
+		return t.Text
+	case "position":
+		return t.Position
+	case "pinned":
+		return t.Pinned
+	}
+	return nil
+}
+
+// compareSortValues compares two values returned by sortValue.
+func compareSortValues(a interface{}, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		b := b.(string)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case float64:
+		b := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case bool:
+		b := b.(bool)
+		switch {
+		case !a && b:
+			return -1
+		case a && !b:
+			return 1
+		}
+	}
+	return 0
+}
+
+// compareBySort orders todos the way sort orders them in MongoDB.
+func compareBySort(sort bson.D) func(a *Todo, b *Todo) int {
+	return func(a *Todo, b *Todo) int {
+		for _, e := range sort {
+			c := compareSortValues(sortValue(a, e.Key), sortValue(b, e.Key))
+			if order, ok := e.Value.(int); ok && order < 0 {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+const (
+	retryAttempts  = 3
+	retryBaseDelay = 50 * time.Millisecond
+)
+
+// transientCodes are server errors raised while a replica set changes
+// primary, which succeed when retried against the new one.
+var transientCodes = []int{
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+var (
+	mongoRetries          = expvar.NewInt("mongo_retries")
+	mongoFailovers        = expvar.NewInt("mongo_failovers")
+	mongoFailoverSeconds  = expvar.NewFloat("mongo_last_failover_seconds")
+	mongoWritableVar      = expvar.NewInt("mongo_writable")
+	errNoWritableMongoYet = errors.New("no writable MongoDB server seen yet")
+)
+
+// isTransient reports whether err is worth retrying: a network error or a
+// server error caused by a primary change.
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if mongo.IsNetworkError(err) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		if serverErr.HasErrorLabel("RetryableWriteError") {
+			return true
+		}
+		for _, code := range transientCodes {
+			if serverErr.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withRetry runs op, retrying transient failures up to retryAttempts times
+// with jittered exponential backoff. It is only used around idempotent
+// operations; the driver's own retryable reads and writes cover a single
+// retry, this rides out the rest of an election.
+func withRetry(ctx context.Context, op func() error) error {
+	err := op()
+	for attempt := 1; attempt < retryAttempts && isTransient(err); attempt++ {
+		mongoRetries.Add(1)
+		delay := retryBaseDelay << (attempt - 1)
+		delay += rand.N(delay)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		err = op()
+	}
+	return err
+}
+
+// mongoSupervisor tracks whether the topology has a server that accepts
+// writes, from the driver's topology events.
+type mongoSupervisor struct {
+	mu            sync.Mutex
+	writable      bool
+	degradedSince time.Time
+}
+
+var supervisor = &mongoSupervisor{degradedSince: time.Now()}
+
+func (s *mongoSupervisor) topologyChanged(e *event.TopologyDescriptionChangedEvent) {
+	writable := false
+	for _, server := range e.NewDescription.Servers {
+		switch server.Kind {
+		case description.RSPrimary, description.Standalone, description.Mongos, description.LoadBalancer:
+			writable = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if writable == s.writable {
+		return
+	}
+	s.writable = writable
+	if writable {
+		mongoWritableVar.Set(1)
+		if !s.degradedSince.IsZero() && mongoFailovers.Value() > 0 {
+			seconds := time.Since(s.degradedSince).Seconds()
+			mongoFailoverSeconds.Set(seconds)
+			log.Printf("MongoDB has a writable primary again after %.1fs", seconds)
+		}
+		s.degradedSince = time.Time{}
+		return
+	}
+
+	mongoWritableVar.Set(0)
+	mongoFailovers.Add(1)
+	s.degradedSince = time.Now()
+	log.Print("MongoDB has no writable primary, waiting for an election")
+}
+
+// MongoDegraded returns an error describing how long MongoDB has had no
+// writable server, or nil when it has one.
+func MongoDegraded() error {
+	supervisor.mu.Lock()
+	defer supervisor.mu.Unlock()
+
+	if supervisor.writable {
+		return nil
+	}
+	if mongoFailovers.Value() == 0 {
+		return errNoWritableMongoYet
+	}
+	return errors.New("MongoDB has had no writable primary for " + time.Since(supervisor.degradedSince).Round(time.Second).String())
+}
+
+var mongoServerMonitor = &event.ServerMonitor{
+	TopologyDescriptionChanged: supervisor.topologyChanged,
+}
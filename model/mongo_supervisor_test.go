@@ -0,0 +1,135 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestWithRetry(t *testing.T) {
+	steppedDown := &mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"}
+	notPrimary := &mongo.CommandError{Code: 10107, Name: "NotWritablePrimary"}
+	network := &mongo.CommandError{Labels: []string{"NetworkError"}}
+	badValue := &mongo.CommandError{Code: 2, Name: "BadValue"}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", []error{nil}, 1, nil},
+		{"election", []error{steppedDown, notPrimary, nil}, 3, nil},
+		{"network error", []error{network, nil}, 2, nil},
+		{"election outlasting the retries", []error{notPrimary, notPrimary, notPrimary, nil}, retryAttempts, notPrimary},
+		{"not transient", []error{badValue, nil}, 1, badValue},
+		{"deadline", []error{context.DeadlineExceeded, nil}, 1, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := withRetry(context.Background(), func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("made %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := withRetry(ctx, func() error {
+		calls++
+		cancel()
+		return &mongo.CommandError{Code: 189}
+	})
+	if calls != 1 || err == nil {
+		t.Errorf("made %d calls returning %v, want one failing call once cancelled", calls, err)
+	}
+}
+
+// TestReadsRideOutStepdown steps the primary of the replica set at
+// TEST_MONGO_REPLSET_URI down while reading, and expects every read to
+// succeed against the new primary. The set needs a second electable member.
+func TestReadsRideOutStepdown(t *testing.T) {
+	repo := useDatabaseAt(t, "TEST_MONGO_REPLSET_URI")
+	admin := repo.Database().Client().Database("admin")
+	ctx := context.Background()
+
+	var hello struct {
+		SetName string   `bson:"setName"`
+		Hosts   []string `bson:"hosts"`
+	}
+	if err := admin.RunCommand(ctx, bson.M{"hello": 1}).Decode(&hello); err != nil {
+		t.Fatal(err)
+	}
+	if hello.SetName == "" || len(hello.Hosts) < 2 {
+		t.Skipf("TEST_MONGO_REPLSET_URI must be a replica set with two electable members, got %q with %v", hello.SetName, hello.Hosts)
+	}
+
+	todo := &Todo{ID: primitive.NewObjectID(), CreatedAt: Now(), UpdatedAt: Now(), Text: "water plants"}
+	if err := repo.Create(ctx, todo); err != nil {
+		t.Fatal(err)
+	}
+
+	var reads atomic.Int64
+	var mu sync.Mutex
+	var failures []error
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			if _, err := repo.GetByID(ctx, todo.ID.Hex()); err != nil {
+				mu.Lock()
+				failures = append(failures, err)
+				mu.Unlock()
+			}
+			reads.Add(1)
+		}
+	}()
+
+	retries := mongoRetries.Value()
+	err := admin.RunCommand(ctx, bson.D{
+		{Key: "replSetStepDown", Value: 10},
+		{Key: "secondaryCatchUpPeriodSecs", Value: 5},
+	}).Err()
+	if err != nil && !mongo.IsNetworkError(err) {
+		t.Fatalf("stepping the primary down: %v", err)
+	}
+
+	electCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := repo.Database().Client().Ping(electCtx, readpref.Primary()); err != nil {
+		t.Fatalf("no new primary elected: %v", err)
+	}
+	// Keep reading from the new primary for a moment.
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	<-done
+
+	t.Logf("%d reads across the stepdown, %d retried", reads.Load(), mongoRetries.Value()-retries)
+	if len(failures) > 0 {
+		t.Errorf("%d of %d reads failed across the stepdown, first: %v", len(failures), reads.Load(), failures[0])
+	}
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// invisibleRunes are characters chat apps commonly paste into text that
+// render as nothing. The zero-width joiner is deliberately absent because
+// emoji sequences depend on it.
+var invisibleRunes = map[rune]bool{
+	'\u200b': true, // zero width space
+	'\u2060': true, // word joiner
+	'\ufeff': true, // zero width no-break space / byte order mark
+}
+
+// quoteReplacer folds typographic quotes onto their ASCII counterparts so
+// that "it’s" and "it's" match.
+var quoteReplacer = strings.NewReplacer(
+	"\u2018", "'", "\u2019", "'", "\u201a", "'", "\u201b", "'",
+	"\u201c", "\"", "\u201d", "\"", "\u201e", "\"", "\u201f", "\"",
+)
+
+// NormalizeText returns text in the form it is stored: NFC composed, with
+// invisible and control characters removed, internal whitespace collapsed to
+// single spaces and surrounding whitespace trimmed.
+func NormalizeText(text string) string {
+	text = norm.NFC.String(text)
+	text = strings.Map(func(r rune) rune {
+		if invisibleRunes[r] {
+			return -1
+		}
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, text)
+
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// MatchKey returns the lowercase shadow of text used for exact-text lookups
+// and duplicate detection.
+func MatchKey(text string) string {
+	return strings.ToLower(quoteReplacer.Replace(NormalizeText(text)))
+}
@@ -0,0 +1,72 @@
+package model
+
+import "testing"
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "water plants", "water plants"},
+		{"surrounding and inner whitespace", "  water\t\n plants  ", "water plants"},
+		{"no-break space", "water\u00a0plants", "water plants"},
+		{"control characters", "water\u0007 plants\u0000", "water plants"},
+		{"zero width space", "water\u200b plants", "water plants"},
+		{"byte order mark", "\ufeffwater plants", "water plants"},
+		{"NFD composed", "cafe\u0301 au lait", "caf\u00e9 au lait"},
+		{"NFC kept", "caf\u00e9 au lait", "caf\u00e9 au lait"},
+		{"NFD hangul composed", "\u1112\u1161\u11ab", "\ud55c"},
+		{"emoji", "\U0001f331 water plants", "\U0001f331 water plants"},
+		{"emoji zero width joiner kept", "\U0001f469\u200d\U0001f4bb fix the build", "\U0001f469\u200d\U0001f4bb fix the build"},
+		{"emoji skin tone kept", "\U0001f44d\U0001f3fd review", "\U0001f44d\U0001f3fd review"},
+		{"emoji variation selector kept", "\u2600\ufe0f picnic", "\u2600\ufe0f picnic"},
+		{"flag", "\U0001f1eb\U0001f1f7 book the train", "\U0001f1eb\U0001f1f7 book the train"},
+		{"arabic", "  \u0627\u0634\u062a\u0631   \u0627\u0644\u062d\u0644\u064a\u0628 ", "\u0627\u0634\u062a\u0631 \u0627\u0644\u062d\u0644\u064a\u0628"},
+		{"hebrew", "\u05dc\u05e7\u05e0\u05d5\u05ea\t\u05d7\u05dc\u05d1", "\u05dc\u05e7\u05e0\u05d5\u05ea \u05d7\u05dc\u05d1"},
+		{"right-to-left mark kept", "\u200f\u05d7\u05dc\u05d1", "\u200f\u05d7\u05dc\u05d1"},
+		{"mixed direction", "call \u05d3\u05e0\u05d4  at 5", "call \u05d3\u05e0\u05d4 at 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormalizeText(tt.text)
+			if got != tt.want {
+				t.Errorf("NormalizeText(%+q) = %+q, want %+q", tt.text, got, tt.want)
+			}
+			if again := NormalizeText(got); again != got {
+				t.Errorf("NormalizeText(%+q) = %+q, want it unchanged", got, again)
+			}
+		})
+	}
+}
+
+func TestMatchKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{"case", "Water Plants", "water plants", true},
+		{"typographic quotes", "It\u2019s \u201cdone\u201d", "it's \"done\"", true},
+		{"NFC and NFD", "Caf\u00e9", "cafe\u0301", true},
+		{"NFD uppercase", "A\u0308pfel", "\u00e4pfel", true},
+		{"invisible characters", "water\u200b plants", "water plants", true},
+		{"accents still differ", "cafe", "caf\u00e9", false},
+		{"emoji", "\U0001f331 Water plants", "\U0001f331 water plants", true},
+		{"different emoji", "\U0001f331 water plants", "\U0001f335 water plants", false},
+		{"emoji sequence and its parts", "\U0001f469\u200d\U0001f4bb", "\U0001f469\U0001f4bb", false},
+		{"skin tones", "\U0001f44d\U0001f3fd", "\U0001f44d\U0001f3ff", false},
+		{"arabic", "\u0627\u0634\u062a\u0631  \u0627\u0644\u062d\u0644\u064a\u0628", "\u0627\u0634\u062a\u0631 \u0627\u0644\u062d\u0644\u064a\u0628", true},
+		{"hebrew and latin", "Call \u05d3\u05e0\u05d4", "call \u05d3\u05e0\u05d4", true},
+		{"right-to-left mark", "\u200f\u05d7\u05dc\u05d1", "\u05d7\u05dc\u05d1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchKey(tt.a) == MatchKey(tt.b); got != tt.want {
+				t.Errorf("MatchKey(%+q) == MatchKey(%+q) is %v, want %v (%+q, %+q)",
+					tt.a, tt.b, got, tt.want, MatchKey(tt.a), MatchKey(tt.b))
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrNoteNotFound = errors.New("note not found")
+
+// Note is an immutable, timestamped log entry appended to a todo.
+type Note struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	TodoID    primitive.ObjectID `json:"todo_id" bson:"todo_id"`
+	Author    string             `json:"author" bson:"author"`
+	Text      string             `json:"text" bson:"text"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+func (r *MongoRepository) notesCollection() *mongo.Collection {
+	return r.coll.Database().Collection("todo_notes")
+}
+
+// AddNote appends a note to the todo with the given id.
+func (r *MongoRepository) AddNote(ctx context.Context, todoId string, author string, text string) (*Note, error) {
+	todo, err := r.GetByID(ctx, todoId)
+	if err != nil {
+		return nil, err
+	}
+
+	note := &Note{
+		ID:        primitive.NewObjectID(),
+		TodoID:    todo.ID,
+		Author:    author,
+		Text:      NormalizeText(text),
+		CreatedAt: Now(),
+	}
+	_, err = r.notesCollection().InsertOne(ctx, note)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	return note, nil
+}
+
+// CreateNotes inserts notes as they are, without checking their todos
+// exist. It is meant for fixtures, which set the timestamps themselves.
+func (r *MongoRepository) CreateNotes(ctx context.Context, notes []*Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(notes))
+	for i, note := range notes {
+		docs[i] = note
+	}
+	_, err := r.notesCollection().InsertMany(ctx, docs)
+	return deadlineError(err)
+}
+
+// Notes returns a page of the todo's notes, newest first. The notes of a
+// todo the owner of ctx cannot see are mongo.ErrNoDocuments, like the todo.
+func (r *MongoRepository) Notes(ctx context.Context, todoId string, offset int64, limit int64) ([]*Note, error) {
+	todo, err := r.GetByID(ctx, todoId)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := findOptions(ctx).
+		SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cur, err := r.notesCollection().Find(ctx, bson.M{"todo_id": todo.ID}, opts)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	notes := []*Note{}
+	if err := cur.All(ctx, &notes); err != nil {
+		return nil, deadlineError(err)
+	}
+
+	return notes, nil
+}
+
+// LatestNotes returns the newest note of each of the given todos that
+// has any, keyed by todo id.
+func (r *MongoRepository) LatestNotes(ctx context.Context, todoIds []primitive.ObjectID) (map[primitive.ObjectID]*Note, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"todo_id": bson.M{"$in": todoIds}}}},
+		{{Key: "$sort", Value: bson.D{primitive.E{Key: "created_at", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$todo_id", "note": bson.M{"$first": "$$ROOT"}}}},
+	}
+	cur, err := r.notesCollection().Aggregate(ctx, pipeline, aggregateOptions(ctx))
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	var groups []struct {
+		Note *Note `bson:"note"`
+	}
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, deadlineError(err)
+	}
+
+	latest := make(map[primitive.ObjectID]*Note, len(groups))
+	for _, group := range groups {
+		latest[group.Note.TodoID] = group.Note
+	}
+	return latest, nil
+}
+
+// DeleteNote removes a note. Notes are otherwise immutable; this exists for
+// administrators with a legal obligation to remove content.
+func (r *MongoRepository) DeleteNote(ctx context.Context, todoId string, noteId string) error {
+	todoObjectId, err := primitive.ObjectIDFromHex(todoId)
+	if err != nil {
+		return err
+	}
+	noteObjectId, err := primitive.ObjectIDFromHex(noteId)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.notesCollection().DeleteOne(ctx, bson.M{"_id": noteObjectId, "todo_id": todoObjectId})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNoteNotFound
+	}
+
+	return nil
+}
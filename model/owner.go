@@ -0,0 +1,103 @@
+package model
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ownerKey struct{}
+
+// WithOwner returns a context that scopes the todos read and written with it
+// to those owned by owner. Calls made without one, as from the CLI, see
+// every todo.
+func WithOwner(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, owner)
+}
+
+func ownerFrom(ctx context.Context) (string, bool) {
+	owner, ok := ctx.Value(ownerKey{}).(string)
+	return owner, ok
+}
+
+// OwnedBy narrows filter to the todos of the owner ctx is scoped to, if any.
+// Todos of other owners are then indistinguishable from missing ones.
+func OwnedBy(ctx context.Context, filter bson.D) bson.D {
+	owner, ok := ownerFrom(ctx)
+	if !ok {
+		return filter
+	}
+
+	scoped := make(bson.D, 0, len(filter)+1)
+	scoped = append(scoped, filter...)
+	return append(scoped, primitive.E{Key: "owner", Value: owner})
+}
+
+// AssignUnowned gives the todos created before ownership was recorded to
+// owner, so they show up for that user in the API again, and returns how
+// many were assigned.
+func (r *MongoRepository) AssignUnowned(ctx context.Context, owner string) (int64, error) {
+	filter := bson.M{"owner": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"owner": owner}}
+
+	res, err := r.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, deadlineError(err)
+	}
+	return res.ModifiedCount, nil
+}
+
+// ReassignResult reports what a reassignment changed, or would change on a
+// dry run.
+type ReassignResult struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Matched    int64  `json:"matched"`
+	Reassigned int64  `json:"reassigned"`
+	DryRun     bool   `json:"dry_run"`
+}
+
+// Reassign gives the todos of from to to, narrowed only by the Tag and
+// Project of query.
+func (r *MongoRepository) Reassign(ctx context.Context, from string, to string, query TodoQuery, dryRun bool) (*ReassignResult, error) {
+	filter := bson.D{}
+	if query.Project != "" {
+		filter = ProjectFilter(filter, query.Project)
+	}
+	if query.Tag != "" {
+		filter = TagFilter(filter, query.Tag)
+	}
+	return r.reassign(ctx, from, to, filter, dryRun)
+}
+
+// reassign gives the todos of from, including archived and deleted
+// ones, to to using a single UpdateMany. filter narrows which todos move,
+// for example with ProjectFilter. A dry run only counts the matches.
+func (r *MongoRepository) reassign(ctx context.Context, from string, to string, filter bson.D, dryRun bool) (*ReassignResult, error) {
+	result := &ReassignResult{From: from, To: to, DryRun: dryRun}
+	scoped := make(bson.D, 0, len(filter)+1)
+	scoped = append(scoped, primitive.E{Key: "owner", Value: from})
+	scoped = append(scoped, filter...)
+
+	if dryRun {
+		matched, err := r.countTodos(ctx, scoped)
+		if err != nil {
+			return nil, err
+		}
+		result.Matched = matched
+		return result, nil
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"owner": to, "updated_at": Now()}}
+	res, err := r.coll.UpdateMany(ctx, scoped, update)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	result.Matched = res.MatchedCount
+	result.Reassigned = res.ModifiedCount
+	return result, nil
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IDSort orders todos by id, which is roughly the order they were created
+// in. It is the order keyset pagination with AfterFilter pages through.
+func IDSort() bson.D {
+	return bson.D{primitive.E{Key: "_id", Value: 1}}
+}
+
+// sortFields are the fields listings can be sorted by with ParseSort.
+var sortFields = []string{"created_at", "updated_at", "text"}
+
+// SortKeys lists the values ParseSort accepts.
+func SortKeys() []string {
+	keys := make([]string, 0, 2*len(sortFields))
+	for _, field := range sortFields {
+		keys = append(keys, field, "-"+field)
+	}
+	return keys
+}
+
+// ParseSort turns a sort key such as created_at, or -created_at for
+// descending order, into a sort with ties broken by id so pages do not
+// overlap.
+func ParseSort(key string) (bson.D, error) {
+	field, order := key, 1
+	if rest, ok := strings.CutPrefix(key, "-"); ok {
+		field, order = rest, -1
+	}
+	for _, allowed := range sortFields {
+		if field == allowed {
+			return bson.D{
+				primitive.E{Key: field, Value: order},
+				primitive.E{Key: "_id", Value: 1},
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("sort must be one of %s", strings.Join(SortKeys(), ", "))
+}
+
+// AfterFilter narrows filter to the todos whose id sorts after after, the
+// last todo of the previous page. Unlike skipping an offset it stays cheap
+// however deep the page, as it seeks straight to after in the _id index.
+func AfterFilter(filter bson.D, after primitive.ObjectID) bson.D {
+	return append(filter, primitive.E{Key: "_id", Value: bson.M{"$gt": after}})
+}
+
+// CompletedFilter narrows filter to the completed todos, or to the pending
+// ones if completed is false.
+func CompletedFilter(filter bson.D, completed bool) bson.D {
+	return append(filter, primitive.E{Key: "completed", Value: completed})
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrDuplicateTodo is returned when creating a todo while the same owner
+// already has a pending todo with the same text, and REJECT_DUPLICATE_TODOS
+// is set.
+var ErrDuplicateTodo = errors.New("a pending todo with the same text already exists")
+
+// RejectDuplicates reports whether REJECT_DUPLICATE_TODOS is set, making
+// Create refuse todos that duplicate a pending one.
+func RejectDuplicates() bool {
+	reject, _ := strconv.ParseBool(os.Getenv("REJECT_DUPLICATE_TODOS"))
+	return reject
+}
+
+// pendingTextIndex makes pending_text unique per owner. pending_text is the
+// match key of a todo's text, present only while the todo is pending and
+// outside the trash, so the index leaves every other todo alone. It is only
+// written when duplicates are rejected; completing or deleting a todo
+// removes it.
+var pendingTextIndex = mongo.IndexModel{
+	Keys: bson.D{
+		primitive.E{Key: "owner", Value: 1},
+		primitive.E{Key: "pending_text", Value: 1},
+	},
+	Options: options.Index().
+		SetUnique(true).
+		SetPartialFilterExpression(bson.M{"pending_text": bson.M{"$exists": true}}),
+}
+
+// duplicateError turns a violation of pendingTextIndex into
+// ErrDuplicateTodo.
+func duplicateError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateTodo
+	}
+	return err
+}
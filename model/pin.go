@@ -0,0 +1,46 @@
+package model
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PinnedSort orders pinned todos before the others, each in their manual
+// order. Unpinning removes the field rather than storing false, so every
+// unpinned todo sorts alike.
+func PinnedSort() bson.D {
+	return append(bson.D{primitive.E{Key: "pinned", Value: -1}}, PositionSort()...)
+}
+
+// SetPinned pins or unpins the todo with the given id and returns it as
+// updated. Pinned todos are listed first. A missing todo is
+// mongo.ErrNoDocuments.
+func (r *MongoRepository) SetPinned(ctx context.Context, id string, pinned bool) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	set := bson.M{"updated_at": Now()}
+	update := bson.M{"$set": set}
+	if pinned {
+		set["pinned"] = true
+	} else {
+		update["$unset"] = bson.M{"pinned": ""}
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	t := &Todo{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := r.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(t); err != nil {
+		return nil, deadlineError(err)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// positionGap is the distance between neighbouring todos after a rebalance,
+// and between the last todo and one moved to the end.
+const positionGap = 1024
+
+var ErrMoveAfterSelf = errors.New("cannot move a todo after itself")
+
+// initialPosition is the position of a newly created todo: its creation time
+// in milliseconds, which places it after every existing todo without a
+// lookup. Todos created in the same millisecond tie and are ordered by id.
+func initialPosition(t *Todo) float64 {
+	return float64(t.CreatedAt.UnixMilli())
+}
+
+// PositionSort orders todos manually, by position with ties broken by id.
+func PositionSort() bson.D {
+	return bson.D{
+		primitive.E{Key: "position", Value: 1},
+		primitive.E{Key: "_id", Value: 1},
+	}
+}
+
+// SetPosition moves the todo with the given id to position and returns it as
+// updated. A missing todo is mongo.ErrNoDocuments.
+func (r *MongoRepository) SetPosition(ctx context.Context, id string, position float64) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	return r.setPosition(ctx, objectId, position)
+}
+
+// MoveAfter moves the todo with the given id to directly after the todo
+// with id after, halfway to the todo that followed it. When there is no room
+// left between the two, or after shares its position with another todo,
+// positions are rebalanced first. A missing todo is mongo.ErrNoDocuments.
+func (r *MongoRepository) MoveAfter(ctx context.Context, id string, after string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	afterId, err := primitive.ObjectIDFromHex(after)
+	if err != nil {
+		return nil, err
+	}
+	if objectId == afterId {
+		return nil, ErrMoveAfterSelf
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	for rebalanced := false; ; rebalanced = true {
+		position, ok, err := r.positionAfter(ctx, objectId, afterId)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return r.setPosition(ctx, objectId, position)
+		}
+		if rebalanced {
+			return nil, fmt.Errorf("no room to move todo %s after %s", id, after)
+		}
+		if err := r.rebalancePositions(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// positionAfter returns the position halfway between the todo afterId and
+// the todo following it, skipping the todo being moved. ok is false when
+// that position would collide with either of them.
+func (r *MongoRepository) positionAfter(ctx context.Context, moving primitive.ObjectID, afterId primitive.ObjectID) (float64, bool, error) {
+	anchor := &Todo{}
+	err := r.coll.FindOne(ctx, OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: afterId}, notDeleted})).Decode(anchor)
+	if err != nil {
+		return 0, false, deadlineError(err)
+	}
+
+	others := bson.A{moving, afterId}
+	ties, err := r.coll.CountDocuments(ctx, OwnedBy(ctx, bson.D{
+		primitive.E{Key: "_id", Value: bson.M{"$nin": others}},
+		notDeleted,
+		primitive.E{Key: "position", Value: anchor.Position},
+	}))
+	if err != nil {
+		return 0, false, deadlineError(err)
+	}
+	if ties > 0 {
+		return 0, false, nil
+	}
+
+	next := &Todo{}
+	opts := options.FindOne().SetSort(PositionSort())
+	err = r.coll.FindOne(ctx, OwnedBy(ctx, bson.D{
+		primitive.E{Key: "_id", Value: bson.M{"$nin": others}},
+		notDeleted,
+		primitive.E{Key: "position", Value: bson.M{"$gt": anchor.Position}},
+	}), opts).Decode(next)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return anchor.Position + positionGap, true, nil
+	}
+	if err != nil {
+		return 0, false, deadlineError(err)
+	}
+
+	position := anchor.Position + (next.Position-anchor.Position)/2
+	return position, position > anchor.Position && position < next.Position, nil
+}
+
+func (r *MongoRepository) setPosition(ctx context.Context, id primitive.ObjectID, position float64) (*Todo, error) {
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: id}, notDeleted})
+	update := bson.M{"$set": bson.M{"position": position, "updated_at": Now()}}
+
+	t := &Todo{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := r.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(t); err != nil {
+		return nil, deadlineError(err)
+	}
+
+	return t, nil
+}
+
+// rebalancePositions renumbers the todos ctx can see positionGap apart,
+// keeping their order, in a single ordered bulk write.
+func (r *MongoRepository) rebalancePositions(ctx context.Context) error {
+	opts := options.Find().SetSort(PositionSort()).SetProjection(bson.M{"_id": 1})
+	cur, err := r.coll.Find(ctx, OwnedBy(ctx, bson.D{notDeleted}), opts)
+	if err != nil {
+		return deadlineError(err)
+	}
+
+	var ids []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &ids); err != nil {
+		return deadlineError(err)
+	}
+
+	models := make([]mongo.WriteModel, len(ids))
+	for i, doc := range ids {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": doc.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"position": float64(i+1) * positionGap}})
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	_, err = r.coll.BulkWrite(ctx, models)
+	return deadlineError(err)
+}
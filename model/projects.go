@@ -0,0 +1,243 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NormalizeProject returns a project name in the form it is stored:
+// normalized and lowercased, so "Home" and "home " are the same project.
+func NormalizeProject(project string) string {
+	return strings.ToLower(NormalizeText(project))
+}
+
+// ProjectFilter narrows filter to todos in project. A project nobody uses
+// simply matches nothing.
+func ProjectFilter(filter bson.D, project string) bson.D {
+	return append(filter, primitive.E{Key: "project", Value: NormalizeProject(project)})
+}
+
+// ProjectSummary is a project and how many of its todos are pending.
+type ProjectSummary struct {
+	Name    string `json:"name" bson:"_id" example:"home"`
+	Pending int64  `json:"pending" bson:"pending" example:"3"`
+}
+
+// Projects returns the projects in use, in name order, with their
+// pending counts. Projects whose todos are all completed have a count of 0.
+func (r *MongoRepository) Projects(ctx context.Context) ([]*ProjectSummary, error) {
+	match := OwnedBy(ctx, bson.D{
+		notDeleted,
+		notArchived,
+		primitive.E{Key: "project", Value: bson.M{"$exists": true}},
+	})
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id": "$project",
+			"pending": bson.M{"$sum": bson.M{
+				"$cond": bson.A{"$completed", 0, 1},
+			}},
+		}}},
+		{{Key: "$sort", Value: bson.D{primitive.E{Key: "_id", Value: 1}}}},
+	}
+
+	var cur *mongo.Cursor
+	err := withRetry(ctx, func() (err error) {
+		cur, err = r.coll.Aggregate(ctx, pipeline, aggregateOptions(ctx))
+		return err
+	})
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	projects := []*ProjectSummary{}
+	if err := cur.All(ctx, &projects); err != nil {
+		return nil, deadlineError(err)
+	}
+	return projects, nil
+}
+
+// ErrProjectNotFound is returned for a project none of the todos outside
+// the trash are in.
+var ErrProjectNotFound = errors.New("project not found")
+
+// ErrProjectExists is returned when renaming a project onto one that is
+// in use, unless the two are merged.
+var ErrProjectExists = errors.New("project already exists, merge to combine them")
+
+// ErrSameProject is returned when renaming a project to its own name.
+var ErrSameProject = errors.New("old and new names are the same project")
+
+// ErrEmptyProject is returned when renaming a project to a blank name.
+var ErrEmptyProject = errors.New("project name is empty")
+
+var errProjectStrategy = errors.New("strategy must be orphan, archive or delete")
+
+// ProjectStrategy is what deleting a project does to the todos in it.
+type ProjectStrategy string
+
+const (
+	// ProjectOrphan takes the todos out of the project.
+	ProjectOrphan ProjectStrategy = "orphan"
+	// ProjectArchive archives the todos.
+	ProjectArchive ProjectStrategy = "archive"
+	// ProjectTrash moves the todos to the trash.
+	ProjectTrash ProjectStrategy = "delete"
+)
+
+// ParseProjectStrategy returns the strategy named s.
+func ParseProjectStrategy(s string) (ProjectStrategy, error) {
+	switch strategy := ProjectStrategy(s); strategy {
+	case ProjectOrphan, ProjectArchive, ProjectTrash:
+		return strategy, nil
+	}
+	return "", errProjectStrategy
+}
+
+// ProjectDeletion reports what deleting a project changed, or would change
+// on a dry run.
+type ProjectDeletion struct {
+	Project  string          `json:"project" example:"home"`
+	Strategy ProjectStrategy `json:"strategy,omitempty" example:"archive"`
+	// Todos counts the todos in the project outside the trash, archived
+	// ones included.
+	Todos int64 `json:"todos" example:"12"`
+	// Changed counts the todos the strategy changed. Archiving skips the
+	// todos already archived.
+	Changed int64 `json:"changed" example:"11"`
+	// Kept counts the todos left in the project by the delete strategy
+	// because they have subtasks outside it.
+	Kept   int64 `json:"kept" example:"1"`
+	DryRun bool  `json:"dry_run"`
+}
+
+// DeleteProject applies strategy to every todo in project outside the
+// trash with a single UpdateMany. A dry run only counts the todos, and
+// needs no strategy. A project no todo is in is ErrProjectNotFound.
+func (r *MongoRepository) DeleteProject(ctx context.Context, project string, strategy ProjectStrategy, dryRun bool) (*ProjectDeletion, error) {
+	project = NormalizeProject(project)
+	result := &ProjectDeletion{Project: project, Strategy: strategy, DryRun: dryRun}
+	inProject := primitive.E{Key: "project", Value: project}
+
+	todos, err := r.countTodos(ctx, OwnedBy(ctx, bson.D{inProject, notDeleted}))
+	if err != nil {
+		return nil, err
+	}
+	if todos == 0 {
+		return nil, ErrProjectNotFound
+	}
+	result.Todos = todos
+	if dryRun {
+		return result, nil
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	now := Now()
+	var filter bson.D
+	var update bson.M
+	switch strategy {
+	case ProjectOrphan:
+		filter = OwnedBy(ctx, bson.D{inProject, notDeleted})
+		update = bson.M{"$set": bson.M{"updated_at": now}, "$unset": bson.M{"project": ""}}
+	case ProjectArchive:
+		filter = OwnedBy(ctx, bson.D{inProject, notDeleted, notArchived})
+		update = bson.M{"$set": bson.M{"archived": true, "updated_at": now}}
+	case ProjectTrash:
+		// Todos with live subtasks in other projects stay, as Delete
+		// refuses to leave subtasks without their parent.
+		ids, err := r.coll.Distinct(ctx, "_id", OwnedBy(ctx, bson.D{inProject, notDeleted}))
+		if err != nil {
+			return nil, deadlineError(err)
+		}
+		parents, err := r.coll.Distinct(ctx, "parent_id", bson.D{
+			notDeleted,
+			primitive.E{Key: "parent_id", Value: bson.M{"$in": ids}},
+			primitive.E{Key: "project", Value: bson.M{"$ne": project}},
+		})
+		if err != nil {
+			return nil, deadlineError(err)
+		}
+		result.Kept = int64(len(parents))
+
+		filter = bson.D{primitive.E{Key: "_id", Value: bson.M{"$in": ids, "$nin": parents}}, notDeleted}
+		update = bson.M{
+			"$set":   bson.M{"deleted_at": now},
+			"$unset": withoutReminders(bson.M{"pending_text": ""}),
+		}
+	default:
+		return nil, errProjectStrategy
+	}
+
+	res, err := r.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	result.Changed = res.ModifiedCount
+	return result, nil
+}
+
+// ProjectRename reports what renaming a project changed.
+type ProjectRename struct {
+	From string `json:"from" example:"hmoe"`
+	To   string `json:"to" example:"home"`
+	// Renamed counts the todos moved to the new name, including those in
+	// the trash and the archive.
+	Renamed int64 `json:"renamed" example:"300"`
+	// Merged is whether the new name was already in use.
+	Merged bool `json:"merged"`
+}
+
+// RenameProject moves every todo in project from to project to with a single
+// UpdateMany, recording the change in each todo's history, and points the
+// owner's defaults for new todos at the new name. Renaming onto a
+// project in use is ErrProjectExists unless merge is set, and a project no
+// todo is in, even in the trash, is ErrProjectNotFound.
+func (r *MongoRepository) RenameProject(ctx context.Context, from string, to string, merge bool) (*ProjectRename, error) {
+	from, to = NormalizeProject(from), NormalizeProject(to)
+	if to == "" {
+		return nil, ErrEmptyProject
+	}
+	if from == to {
+		return nil, ErrSameProject
+	}
+	result := &ProjectRename{From: from, To: to}
+
+	existing, err := r.countTodos(ctx, OwnedBy(ctx, bson.D{primitive.E{Key: "project", Value: to}, notDeleted}))
+	if err != nil {
+		return nil, err
+	}
+	if existing > 0 && !merge {
+		return nil, ErrProjectExists
+	}
+	result.Merged = existing > 0
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "project", Value: from}})
+	previous, err := r.filterTodos(ctx, filter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"project": to, "updated_at": Now()}}
+	res, err := r.coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	result.Renamed = res.ModifiedCount
+	r.recordHistoryMany(ctx, HistoryUpdate, previous, []string{"project"})
+	r.renameDefaults(ctx, from, to)
+	return result, nil
+}
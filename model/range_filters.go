@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SinceFilter narrows filter to the todos whose time field, such as
+// created_at or updated_at, is at or after since.
+func SinceFilter(filter bson.D, field string, since time.Time) bson.D {
+	return append(filter, primitive.E{Key: field, Value: bson.M{"$gte": since}})
+}
+
+// UntilFilter narrows filter to the todos whose time field is at or before
+// until.
+func UntilFilter(filter bson.D, field string, until time.Time) bson.D {
+	return append(filter, primitive.E{Key: field, Value: bson.M{"$lte": until}})
+}
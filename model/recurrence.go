@@ -0,0 +1,141 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Recurrence is a parsed recurrence rule: every N days, weeks, months or
+// years.
+type Recurrence struct {
+	Every int
+	Unit  string
+}
+
+var recurrenceAliases = map[string]Recurrence{
+	"daily":   {Every: 1, Unit: "day"},
+	"weekly":  {Every: 1, Unit: "week"},
+	"monthly": {Every: 1, Unit: "month"},
+	"yearly":  {Every: 1, Unit: "year"},
+}
+
+// InvalidRecurrenceError reports a recurrence rule that could not be parsed.
+type InvalidRecurrenceError struct {
+	Rule string
+}
+
+func (e *InvalidRecurrenceError) Error() string {
+	return fmt.Sprintf("invalid recurrence %q, expected daily, weekly, monthly, yearly or \"every N days|weeks|months|years\"", e.Rule)
+}
+
+// ParseRecurrence parses rules such as "weekly" or "every 3 days".
+func ParseRecurrence(rule string) (Recurrence, error) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(rule), " "))
+	if r, ok := recurrenceAliases[normalized]; ok {
+		return r, nil
+	}
+
+	fields := strings.Fields(normalized)
+	if len(fields) != 3 || fields[0] != "every" {
+		return Recurrence{}, &InvalidRecurrenceError{Rule: rule}
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil || n < 1 || n > 366 {
+		return Recurrence{}, &InvalidRecurrenceError{Rule: rule}
+	}
+	unit := strings.TrimSuffix(fields[2], "s")
+	switch unit {
+	case "day", "week", "month", "year":
+	default:
+		return Recurrence{}, &InvalidRecurrenceError{Rule: rule}
+	}
+
+	return Recurrence{Every: n, Unit: unit}, nil
+}
+
+// Next returns the occurrence after from. Months and years fall on day of
+// the month where it exists and on the last day otherwise, so a todo due on
+// January 31st recurs on February 28th and then on March 31st rather than
+// drifting to the 28th. A day of 0 keeps the day of from.
+func (r Recurrence) Next(from time.Time, day int) time.Time {
+	if day == 0 {
+		day = from.Day()
+	}
+	switch r.Unit {
+	case "day":
+		return from.AddDate(0, 0, r.Every)
+	case "week":
+		return from.AddDate(0, 0, 7*r.Every)
+	case "month":
+		return addMonthsClamped(from, r.Every, day)
+	default:
+		return addMonthsClamped(from, 12*r.Every, day)
+	}
+}
+
+func addMonthsClamped(t time.Time, months int, day int) time.Time {
+	year, month, _ := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	return first.AddDate(0, 0, min(day, lastDayOfMonth(first))-1)
+}
+
+func lastDayOfMonth(t time.Time) int {
+	year, month, _ := t.Date()
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+// anchorDay returns the day of the month the occurrences after due fall on:
+// the anchor day of the series when due was clamped to the end of a shorter
+// month, and the day of due otherwise, so moving a due date moves the series.
+func anchorDay(due time.Time, anchor int) int {
+	if anchor > due.Day() && due.Day() == lastDayOfMonth(due) {
+		return anchor
+	}
+	return due.Day()
+}
+
+// spawnNextOccurrence inserts and returns a pending copy of the recurring
+// todo t, due one recurrence after its due date, or after now if it had
+// none. It returns nil if the next occurrence is already pending.
+func (r *MongoRepository) spawnNextOccurrence(ctx context.Context, t *Todo) (*Todo, error) {
+	rule, err := ParseRecurrence(t.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	base := Now()
+	if t.DueDate != nil {
+		base = *t.DueDate
+	}
+	day := anchorDay(base, t.RecurrenceDay)
+	due := rule.Next(base, day)
+
+	next := &Todo{
+		ID:            primitive.NewObjectID(),
+		CreatedAt:     Now(),
+		UpdatedAt:     Now(),
+		Text:          t.Text,
+		DueDate:       &due,
+		Tags:          t.Tags,
+		Project:       t.Project,
+		ParentID:      t.ParentID,
+		Recurrence:    t.Recurrence,
+		RecurrenceDay: day,
+		Owner:         t.Owner,
+	}
+	err = r.Create(ctx, next)
+	if errors.Is(err, ErrDuplicateTodo) {
+		// The next occurrence is already pending.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
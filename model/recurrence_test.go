@@ -0,0 +1,98 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		rule string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "31st through February",
+			rule: "monthly",
+			from: date(2025, time.January, 31),
+			want: []time.Time{date(2025, time.February, 28), date(2025, time.March, 31), date(2025, time.April, 30), date(2025, time.May, 31)},
+		},
+		{
+			name: "31st through a leap February",
+			rule: "monthly",
+			from: date(2024, time.January, 31),
+			want: []time.Time{date(2024, time.February, 29), date(2024, time.March, 31)},
+		},
+		{
+			name: "30th through February",
+			rule: "monthly",
+			from: date(2025, time.January, 30),
+			want: []time.Time{date(2025, time.February, 28), date(2025, time.March, 30)},
+		},
+		{
+			name: "every 2 months from the 31st",
+			rule: "every 2 months",
+			from: date(2024, time.December, 31),
+			want: []time.Time{date(2025, time.February, 28), date(2025, time.April, 30), date(2025, time.June, 30), date(2025, time.August, 31)},
+		},
+		{
+			name: "every 3 months across a year",
+			rule: "every 3 months",
+			from: date(2025, time.November, 30),
+			want: []time.Time{date(2026, time.February, 28), date(2026, time.May, 30)},
+		},
+		{
+			name: "yearly from a leap day",
+			rule: "yearly",
+			from: date(2024, time.February, 29),
+			want: []time.Time{date(2025, time.February, 28), date(2026, time.February, 28), date(2027, time.February, 28), date(2028, time.February, 29)},
+		},
+		{
+			name: "mid-month",
+			rule: "monthly",
+			from: date(2025, time.January, 15),
+			want: []time.Time{date(2025, time.February, 15), date(2025, time.March, 15)},
+		},
+		{
+			name: "weekly",
+			rule: "weekly",
+			from: date(2025, time.February, 24),
+			want: []time.Time{date(2025, time.March, 3), date(2025, time.March, 10)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseRecurrence(tc.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			due, anchor := tc.from, 0
+			for _, want := range tc.want {
+				anchor = anchorDay(due, anchor)
+				due = r.Next(due, anchor)
+				if !due.Equal(want) {
+					t.Fatalf("after %s: got %s, want %s", tc.from.Format(time.DateOnly), due.Format(time.DateOnly), want.Format(time.DateOnly))
+				}
+			}
+		})
+	}
+}
+
+func TestRecurrenceFollowsMovedDueDate(t *testing.T) {
+	r, err := ParseRecurrence("monthly")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The series was anchored on the 31st, then its due date was moved to
+	// the 10th: later occurrences follow the new day.
+	moved := date(2025, time.March, 10)
+	if got, want := r.Next(moved, anchorDay(moved, 31)), date(2025, time.April, 10); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got.Format(time.DateOnly), want.Format(time.DateOnly))
+	}
+}
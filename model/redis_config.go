@@ -1,29 +1,79 @@
 package model
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/chenyahui/gin-cache/persist"
 	"github.com/go-redis/redis/v8"
 )
 
+// todoCachePrefix starts the key of every cached todo response. It is
+// followed by the user the response was served to and the request URI.
+const todoCachePrefix = "cache:todos:"
+
+// globEscaper escapes the characters Redis treats as wildcards in patterns.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// TodoCacheKey is the key the response to requestURI is cached under for
+// user.
+func TodoCacheKey(user string, requestURI string) string {
+	return todoCachePrefix + user + ":" + requestURI
+}
+
 type RedisCache struct {
 	Store            *persist.RedisStore
 	DefaultCacheTime time.Duration
 }
 
 func SetupRedisCache() *RedisCache {
+	client := redis.NewClient(&redis.Options{
+		Network: "tcp",
+		Addr: fmt.Sprintf(
+			"%s:%s",
+			os.Getenv("REDIS_HOST"),
+			os.Getenv("REDIS_PORT"),
+		),
+	})
+	client.AddHook(redisTimingHook{})
+	if ChaosEnabled() {
+		client.AddHook(chaosRedisHook{})
+	}
+
 	return &RedisCache{
-		Store: persist.NewRedisStore(redis.NewClient(&redis.Options{
-			Network: "tcp",
-			Addr: fmt.Sprintf(
-				"%s:%s",
-				os.Getenv("REDIS_HOST"),
-				os.Getenv("REDIS_PORT"),
-			),
-		})),
+		Store:            persist.NewRedisStore(client),
 		DefaultCacheTime: 15 * time.Minute,
 	}
 }
+
+// InvalidateTodos drops every todo response cached for user, so writes that
+// change many todos at once are visible on their next read.
+func (r *RedisCache) InvalidateTodos(ctx context.Context, user string) error {
+	return r.deleteMatching(ctx, todoCachePrefix+globEscaper.Replace(user)+":*")
+}
+
+// InvalidateAllTodos drops the todo responses cached for every user, for
+// writes made without an owner scope such as those of the CLI.
+func (r *RedisCache) InvalidateAllTodos(ctx context.Context) error {
+	return r.deleteMatching(ctx, todoCachePrefix+"*")
+}
+
+func (r *RedisCache) deleteMatching(ctx context.Context, pattern string) error {
+	client := r.Store.RedisClient
+
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return client.Del(ctx, keys...).Err()
+}
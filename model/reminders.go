@@ -0,0 +1,268 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxRemindersPerPoll bounds the reminders fired by one poll, so a backlog
+// after downtime is worked off over several polls.
+const maxRemindersPerPoll = 100
+
+// MaxReminders caps the outstanding reminders of a todo, and how many of
+// the fired ones it keeps track of.
+const MaxReminders = 10
+
+var (
+	ErrReminderInPast   = errors.New("reminder time is in the past")
+	ErrTooManyReminders = fmt.Errorf("a todo can have at most %d outstanding reminders", MaxReminders)
+	ErrReminderNotFound = errors.New("reminder not found")
+)
+
+// Notifier delivers a reminder for a todo that was due at the given time.
+type Notifier interface {
+	Notify(ctx context.Context, todo *Todo, at time.Time) error
+}
+
+// LogNotifier delivers reminders by logging them.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(_ context.Context, todo *Todo, at time.Time) error {
+	log.Printf("Reminder: %q (%s) was due for a reminder at %s",
+		todo.Text, todo.ID.Hex(), at.Local().Format(time.DateTime))
+	return nil
+}
+
+// withoutReminders adds the fields holding outstanding reminders to the
+// $unset of an update, cancelling them as a todo is completed or deleted.
+// Fired reminders are kept.
+func withoutReminders(unset bson.M) bson.M {
+	unset["reminders"] = ""
+	unset["next_reminder_at"] = ""
+	return unset
+}
+
+// reminderTime rounds at to the millisecond precision MongoDB stores, so a
+// reminder can later be removed by the time it was added with.
+func reminderTime(at time.Time) (time.Time, error) {
+	at = at.Truncate(time.Millisecond)
+	if !at.After(Now()) {
+		return at, ErrReminderInPast
+	}
+	return at, nil
+}
+
+// reminderRefused explains why a reminder at the given time could not be
+// added to t. It is nil if t already has that reminder.
+func reminderRefused(t *Todo, at time.Time) error {
+	switch {
+	case t.Completed:
+		return ErrAlreadyCompleted
+	case slices.ContainsFunc(t.Reminders, at.Equal):
+		return nil
+	default:
+		return ErrTooManyReminders
+	}
+}
+
+// AddReminder schedules a reminder for the pending todo with the given id
+// at the given time, which must lie in the future, and returns the todo as
+// updated. Adding a reminder the todo already has changes nothing. A todo
+// with MaxReminders outstanding is refused with ErrTooManyReminders and a
+// completed one with ErrAlreadyCompleted.
+func (r *MongoRepository) AddReminder(ctx context.Context, id string, at time.Time) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	if at, err = reminderTime(at); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	open := append(bson.D{
+		primitive.E{Key: "completed", Value: false},
+		primitive.E{Key: "reminders", Value: bson.M{"$ne": at}},
+		primitive.E{Key: fmt.Sprintf("reminders.%d", MaxReminders-1), Value: bson.M{"$exists": false}},
+	}, filter...)
+	// Reminders are kept sorted, so the first is always next_reminder_at.
+	update := bson.M{
+		"$push": bson.M{"reminders": bson.M{"$each": bson.A{at}, "$sort": 1}},
+		"$min":  bson.M{"next_reminder_at": at},
+		"$set":  bson.M{"updated_at": Now()},
+	}
+
+	t := &Todo{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.coll.FindOneAndUpdate(ctx, open, update, opts).Decode(t)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if err := r.coll.FindOne(ctx, filter).Decode(t); err != nil {
+			return nil, deadlineError(err)
+		}
+		if err := reminderRefused(t, at); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	return t, nil
+}
+
+// RemoveReminder cancels the outstanding reminder of the todo with the
+// given id at the given time and returns the todo as updated. A reminder
+// the todo does not have, or that has already fired, is
+// ErrReminderNotFound.
+func (r *MongoRepository) RemoveReminder(ctx context.Context, id string, at time.Time) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	at = at.Truncate(time.Millisecond)
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"reminders": bson.M{"$filter": bson.M{
+				"input": "$reminders",
+				"cond":  bson.M{"$ne": bson.A{"$$this", at}},
+			}},
+			"updated_at": Now(),
+		}}},
+		{{Key: "$set", Value: bson.M{"next_reminder_at": bson.M{"$arrayElemAt": bson.A{"$reminders", 0}}}}},
+	}
+
+	t := &Todo{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	scheduled := append(bson.D{primitive.E{Key: "reminders", Value: at}}, filter...)
+	err = r.coll.FindOneAndUpdate(ctx, scheduled, update, opts).Decode(t)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if err := r.coll.FindOne(ctx, filter).Err(); err != nil {
+			return nil, deadlineError(err)
+		}
+		return nil, ErrReminderNotFound
+	}
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	return t, nil
+}
+
+// dueReminderFilter matches pending todos whose reminder time has passed and
+// has not fired yet.
+func dueReminderFilter(now time.Time) bson.D {
+	return bson.D{
+		primitive.E{Key: "reminded_at", Value: nil},
+		primitive.E{Key: "remind_at", Value: bson.M{"$lte": now}},
+		primitive.E{Key: "completed", Value: false},
+		notDeleted,
+	}
+}
+
+// dueListedReminderFilter matches pending todos whose earliest outstanding
+// reminder has passed, using the next_reminder_at index.
+func dueListedReminderFilter(now time.Time) bson.D {
+	return bson.D{
+		primitive.E{Key: "next_reminder_at", Value: bson.M{"$lte": now}},
+		primitive.E{Key: "completed", Value: false},
+		notDeleted,
+	}
+}
+
+// fireListedReminder moves the earliest outstanding reminder of a todo to
+// fired_reminders, keeping the last MaxReminders of them, and points
+// next_reminder_at at the one after it.
+var fireListedReminder = mongo.Pipeline{
+	{{Key: "$set", Value: bson.M{
+		"fired_reminders": bson.M{"$slice": bson.A{
+			bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$fired_reminders", bson.A{}}},
+				bson.A{"$next_reminder_at"},
+			}},
+			-MaxReminders,
+		}},
+		"reminders": bson.M{"$slice": bson.A{"$reminders", 1, MaxReminders}},
+	}}},
+	{{Key: "$set", Value: bson.M{"next_reminder_at": bson.M{"$arrayElemAt": bson.A{"$reminders", 0}}}}},
+}
+
+// claimDueReminder marks one due reminder fired and returns its todo and
+// the time it was due at. The remind_at reminder of a todo is tried before
+// its list of reminders. Nothing being due is mongo.ErrNoDocuments.
+func (r *MongoRepository) claimDueReminder(ctx context.Context) (*Todo, time.Time, error) {
+	now := Now()
+	update := bson.M{"$set": bson.M{"reminded_at": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	t := &Todo{}
+	err := r.coll.FindOneAndUpdate(ctx, dueReminderFilter(now), update, opts).Decode(t)
+	if err == nil {
+		return t, *t.RemindAt, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, time.Time{}, err
+	}
+
+	opts = options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	if err := r.coll.FindOneAndUpdate(ctx, dueListedReminderFilter(now), fireListedReminder, opts).Decode(t); err != nil {
+		return nil, time.Time{}, err
+	}
+	return t, *t.NextReminderAt, nil
+}
+
+// FireDueReminders claims due reminders one at a time, marking each fired
+// before notifying so that concurrent pollers never fire one twice, and
+// returns how many were fired.
+func (r *MongoRepository) FireDueReminders(ctx context.Context, notifier Notifier) (int, error) {
+	fired := 0
+	for fired < maxRemindersPerPoll {
+		t, at, err := r.claimDueReminder(ctx)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fired, nil
+		}
+		if err != nil {
+			return fired, err
+		}
+
+		fired++
+		if err := notifier.Notify(ctx, t, at); err != nil {
+			log.Printf("Delivering reminder for %s failed: %v", t.ID.Hex(), err)
+		}
+	}
+	return fired, nil
+}
+
+// RunReminders fires due reminders once per interval until ctx is
+// cancelled, returning once the poll in progress has finished.
+func (r *MongoRepository) RunReminders(ctx context.Context, interval time.Duration, notifier Notifier) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := r.FireDueReminders(ctx, notifier); err != nil && ctx.Err() == nil {
+			log.Printf("Firing reminders failed: %v", err)
+		}
+	}
+}
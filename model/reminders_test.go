@@ -0,0 +1,106 @@
+package model
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recordingNotifier records the times of the reminders it is given.
+type recordingNotifier struct {
+	fired []time.Time
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, _ *Todo, at time.Time) error {
+	n.fired = append(n.fired, at)
+	return nil
+}
+
+// addTestReminders creates a pending todo at start with reminders at the
+// given offsets from it.
+func addTestReminders(t *testing.T, repo *MongoRepository, start time.Time, offsets ...time.Duration) *Todo {
+	t.Helper()
+	ctx := context.Background()
+
+	todo := &Todo{ID: primitive.NewObjectID(), CreatedAt: start, UpdatedAt: start, Text: "call the bank"}
+	if err := repo.Create(ctx, todo); err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range offsets {
+		if _, err := repo.AddReminder(ctx, todo.ID.Hex(), start.Add(offset)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return todo
+}
+
+func TestFireDueRemindersOncePerEntry(t *testing.T) {
+	repo := useTestDatabase(t)
+	ctx := context.Background()
+	t.Cleanup(func() { Now = time.Now })
+	start := time.Date(2025, time.March, 5, 9, 0, 0, 0, time.UTC)
+	FreezeClock(start)
+	todo := addTestReminders(t, repo, start, 2*time.Hour, time.Hour)
+
+	notifier := &recordingNotifier{}
+	fire := func(at time.Time) {
+		FreezeClock(at)
+		for range 2 {
+			if _, err := repo.FireDueReminders(ctx, notifier); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	fire(start.Add(90 * time.Minute))
+	if want := []time.Time{start.Add(time.Hour)}; !slices.EqualFunc(notifier.fired, want, time.Time.Equal) {
+		t.Fatalf("fired %v, want %v", notifier.fired, want)
+	}
+	fire(start.Add(3 * time.Hour))
+	want := []time.Time{start.Add(time.Hour), start.Add(2 * time.Hour)}
+	if !slices.EqualFunc(notifier.fired, want, time.Time.Equal) {
+		t.Fatalf("fired %v, want %v", notifier.fired, want)
+	}
+
+	got, err := repo.GetByID(ctx, todo.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Reminders) != 0 || got.NextReminderAt != nil {
+		t.Errorf("got outstanding reminders %v next at %v, want none", got.Reminders, got.NextReminderAt)
+	}
+	if !slices.EqualFunc(got.FiredReminders, want, time.Time.Equal) {
+		t.Errorf("got fired reminders %v, want %v", got.FiredReminders, want)
+	}
+}
+
+func TestCompletingCancelsReminders(t *testing.T) {
+	repo := useTestDatabase(t)
+	ctx := context.Background()
+	t.Cleanup(func() { Now = time.Now })
+	start := time.Date(2025, time.March, 5, 9, 0, 0, 0, time.UTC)
+	FreezeClock(start)
+	completed := addTestReminders(t, repo, start, time.Hour)
+	deleted := addTestReminders(t, repo, start, time.Hour)
+
+	if _, err := repo.Complete(ctx, completed.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Delete(ctx, deleted.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Restore(ctx, deleted.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	FreezeClock(start.Add(2 * time.Hour))
+	fired, err := repo.FireDueReminders(ctx, &recordingNotifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fired != 0 {
+		t.Errorf("fired %d reminders of completed and deleted todos, want 0", fired)
+	}
+}
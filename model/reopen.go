@@ -0,0 +1,60 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Reopen marks the completed todo with the given id as pending again,
+// clearing its completed_at, and returns it as updated. Reopening a pending
+// todo changes nothing. A missing todo is mongo.ErrNoDocuments, and with
+// duplicate rejection on, reopening a todo another pending todo duplicates
+// is ErrDuplicateTodo.
+func (r *MongoRepository) Reopen(ctx context.Context, id string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	t := &Todo{}
+	if err := r.coll.FindOne(ctx, filter).Decode(t); err != nil {
+		return nil, deadlineError(err)
+	}
+	if !t.Completed {
+		return t, nil
+	}
+
+	set := bson.M{"completed": false, "updated_at": Now()}
+	if RejectDuplicates() {
+		set["pending_text"] = MatchKey(t.Text)
+	}
+	update := bson.M{
+		"$set":   set,
+		"$unset": bson.M{"completed_at": ""},
+	}
+
+	// Only the request that actually reopens the todo records it, however
+	// many race to.
+	completed := append(bson.D{primitive.E{Key: "completed", Value: true}}, filter...)
+	reopened := &Todo{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.coll.FindOneAndUpdate(ctx, completed, update, opts).Decode(reopened)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return r.GetByID(ctx, id)
+	}
+	if err != nil {
+		return nil, duplicateError(deadlineError(err))
+	}
+
+	r.recordHistory(ctx, HistoryReopen, t, []string{"completed", "completed_at"})
+	return reopened, nil
+}
@@ -0,0 +1,257 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// TodoRepository stores todos along with their notes, history and
+// templates. Both implementations scope what they read and write to the
+// owner of ctx, see WithOwner, and report a missing todo as
+// mongo.ErrNoDocuments. The methods of MongoRepository document the errors
+// of each.
+type TodoRepository interface {
+	// Create normalizes and inserts todo.
+	Create(ctx context.Context, todo *Todo) error
+	// CreateMany inserts todos that passed Validate, reporting failures as
+	// a mongo.BulkWriteException indexed by position in todos.
+	CreateMany(ctx context.Context, todos []*Todo) error
+	// Validate makes the checks of Create without inserting.
+	Validate(ctx context.Context, todo *Todo) error
+	// GetByID returns the todo with the given id outside the trash.
+	GetByID(ctx context.Context, id string) (*Todo, error)
+	// GetByIDs returns the todos among ids outside the trash, skipping
+	// missing ones.
+	GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*Todo, error)
+	// GetByText returns a todo outside the trash with the given text.
+	GetByText(ctx context.Context, text string) (*Todo, error)
+	// List returns a page of the todos matching query and how many match
+	// across all pages.
+	List(ctx context.Context, query TodoQuery) ([]*Todo, int64, error)
+	// Stream calls fn with every todo outside the trash and the archive and
+	// returns how many it was called with.
+	Stream(ctx context.Context, fn func(*Todo) error) (int64, error)
+	Search(ctx context.Context, query string, completed *bool) ([]*Todo, error)
+	Children(ctx context.Context, id string) ([]*Todo, error)
+	Blockers(ctx context.Context, id string) ([]*Todo, error)
+	Duplicates(ctx context.Context, threshold float64) ([]*DuplicateCluster, error)
+	Stats(ctx context.Context) (*TodoStats, error)
+	// Workload totals the estimated minutes of pending todos by due date.
+	Workload(ctx context.Context, query WorkloadQuery) (*Workload, error)
+	Projects(ctx context.Context) ([]*ProjectSummary, error)
+	// DeleteProject applies strategy to the todos in project, or only
+	// counts them on a dry run.
+	DeleteProject(ctx context.Context, project string, strategy ProjectStrategy, dryRun bool) (*ProjectDeletion, error)
+	// RenameProject moves the todos of one project to another, refusing
+	// to merge them into a project in use unless merge is set.
+	RenameProject(ctx context.Context, from string, to string, merge bool) (*ProjectRename, error)
+	// Update replaces the editable fields of the todo with the given id.
+	// Under WithVersion it returns ErrVersionMismatch if the todo has
+	// changed since that version.
+	Update(ctx context.Context, id string, todo *Todo) error
+	// Delete moves the todo to the trash and returns it as it was, refusing
+	// one with subtasks with ErrHasChildren.
+	Delete(ctx context.Context, id string) (*Todo, error)
+	// Complete completes the todo and returns it as updated, refusing one
+	// with pending blocking todos with a *BlockedError.
+	Complete(ctx context.Context, id string) (*Todo, error)
+	CompleteMany(ctx context.Context, ids []primitive.ObjectID) (int64, []primitive.ObjectID, error)
+	CompleteWithFollowup(ctx context.Context, id string, followup *Todo) (*Todo, *Todo, error)
+	Reopen(ctx context.Context, id string) (*Todo, error)
+	SetArchived(ctx context.Context, id string, archived bool) (*Todo, error)
+	SetPinned(ctx context.Context, id string, pinned bool) (*Todo, error)
+	SetPosition(ctx context.Context, id string, position float64) (*Todo, error)
+	MoveAfter(ctx context.Context, id string, after string) (*Todo, error)
+	LogTime(ctx context.Context, id string, minutes int) (*Todo, error)
+	Snooze(ctx context.Context, id string, until time.Time) (*Todo, error)
+	// AddReminder schedules a reminder for a pending todo, which
+	// RemoveReminder cancels again until it has fired.
+	AddReminder(ctx context.Context, id string, at time.Time) (*Todo, error)
+	RemoveReminder(ctx context.Context, id string, at time.Time) (*Todo, error)
+	// Deleted lists the todos in the trash.
+	Deleted(ctx context.Context) ([]*Todo, error)
+	Restore(ctx context.Context, id string) (*Todo, error)
+	// PurgeTodo permanently removes a todo that is in the trash.
+	PurgeTodo(ctx context.Context, id string) error
+	// Replace writes an undo snapshot of a todo back, reinserting it if it
+	// was purged.
+	Replace(ctx context.Context, todo *Todo) error
+	DeleteCompleted(ctx context.Context) (int64, error)
+	// Purge, Reassign and MarkStale maintain the todos of every owner and
+	// ignore the owner of ctx.
+	Purge(ctx context.Context, olderThan time.Duration) (int64, error)
+	// Reassign only narrows the todos it moves by the Tag and Project of
+	// query.
+	Reassign(ctx context.Context, from string, to string, query TodoQuery, dryRun bool) (*ReassignResult, error)
+	MarkStale(ctx context.Context, olderThan time.Duration, dryRun bool) (*AgingResult, error)
+
+	// AddNote appends a note to the todo with the given id.
+	AddNote(ctx context.Context, todoId string, author string, text string) (*Note, error)
+	// CreateNotes inserts notes as they are, for fixtures.
+	CreateNotes(ctx context.Context, notes []*Note) error
+	// Notes returns a page of the todo's notes, newest first.
+	Notes(ctx context.Context, todoId string, offset int64, limit int64) ([]*Note, error)
+	// LatestNotes returns the newest note of each of the given todos that
+	// has any, keyed by todo id.
+	LatestNotes(ctx context.Context, todoIds []primitive.ObjectID) (map[primitive.ObjectID]*Note, error)
+	DeleteNote(ctx context.Context, todoId string, noteId string) error
+	// History returns a page of the changes made to the todo, newest first.
+	History(ctx context.Context, todoId string, offset int64, limit int64) ([]*HistoryEntry, error)
+	// HistorySummary counts the changes made to the todo, nil if there
+	// are none.
+	HistorySummary(ctx context.Context, id primitive.ObjectID) (*HistorySummary, error)
+
+	// Defaults returns the defaults for new todos of the owner of ctx, and
+	// SetDefaults replaces them.
+	Defaults(ctx context.Context) (*Defaults, error)
+	SetDefaults(ctx context.Context, d *Defaults) error
+
+	CreateTemplate(ctx context.Context, t *Template) error
+	Templates(ctx context.Context) ([]*Template, error)
+	GetTemplateByID(ctx context.Context, id string) (*Template, error)
+	GetTemplateByName(ctx context.Context, name string) (*Template, error)
+	UpdateTemplate(ctx context.Context, id string, t *Template) (*Template, error)
+	DeleteTemplate(ctx context.Context, id string) error
+
+	// AcquireLock takes the named advisory lock for holder, shared by every
+	// owner, or returns a *LockHeldError naming whoever has it.
+	AcquireLock(ctx context.Context, name string, holder string) (*Lock, error)
+}
+
+// TodoQuery selects and orders the todos List returns. The zero value lists
+// every todo outside the trash and the archive in the default order.
+type TodoQuery struct {
+	// Stale and Overdue restrict the listing to pending todos marked stale
+	// or past their due date, archived ones included.
+	Stale   bool
+	Overdue bool
+	// Finished restricts the listing to completed todos, archived ones
+	// included, most recently completed first unless Sort is set.
+	Finished bool
+	// Awake leaves out todos snoozed until later.
+	Awake bool
+	// Completed restricts the listing to completed or to pending todos.
+	Completed       *bool
+	CreatedAfter    *time.Time
+	CreatedBefore   *time.Time
+	UpdatedAfter    *time.Time
+	Tag             string
+	Project         string
+	IncludeArchived bool
+	// Sort is a key accepted by ParseSort, empty for PinnedSort.
+	Sort   string
+	Offset int
+	// Limit is the maximum number of todos returned, 0 for no limit.
+	Limit int
+	// ByCursor switches to pagination by id: todos are ordered by id,
+	// starting after After unless it is zero, and Sort and Offset are
+	// ignored. The total count does not depend on After.
+	ByCursor bool
+	After    primitive.ObjectID
+	// View, if set, only reads the fields of its representation.
+	View *ListView
+}
+
+// MongoRepository is the TodoRepository backed by a MongoDB collection.
+// Notes, history, templates, defaults and locks live in collections of the
+// same database.
+type MongoRepository struct {
+	coll *mongo.Collection
+}
+
+func NewMongoRepository(coll *mongo.Collection) *MongoRepository {
+	return &MongoRepository{coll: coll}
+}
+
+// Namespace returns the fully-qualified database.collection holding todos.
+func (r *MongoRepository) Namespace() string {
+	return r.coll.Database().Name() + "." + r.coll.Name()
+}
+
+// Database returns the database holding todos and their related
+// collections.
+func (r *MongoRepository) Database() *mongo.Database {
+	return r.coll.Database()
+}
+
+// Ping checks that the primary is reachable.
+func (r *MongoRepository) Ping(ctx context.Context) error {
+	return r.coll.Database().Client().Ping(ctx, readpref.Primary())
+}
+
+func (r *MongoRepository) List(ctx context.Context, query TodoQuery) ([]*Todo, int64, error) {
+	filter := AllFilter()
+	switch {
+	case query.Finished:
+		filter = FinishedFilter()
+	case query.Overdue:
+		filter = OverdueFilter()
+	case query.Stale:
+		filter = StaleFilter()
+	}
+	if query.Completed != nil {
+		filter = CompletedFilter(filter, *query.Completed)
+	}
+	if query.CreatedAfter != nil {
+		filter = SinceFilter(filter, "created_at", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		filter = UntilFilter(filter, "created_at", *query.CreatedBefore)
+	}
+	if query.UpdatedAfter != nil {
+		filter = SinceFilter(filter, "updated_at", *query.UpdatedAfter)
+	}
+	if query.Tag != "" {
+		filter = TagFilter(filter, query.Tag)
+	}
+	if query.Project != "" {
+		filter = ProjectFilter(filter, query.Project)
+	}
+	if query.IncludeArchived {
+		filter = WithArchived(filter)
+	}
+	if query.Awake {
+		filter = Awake(filter)
+	}
+
+	sort := PinnedSort()
+	if query.Finished {
+		sort = FinishedSort()
+	}
+	if query.Sort != "" {
+		var err error
+		if sort, err = ParseSort(query.Sort); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	filter = OwnedBy(ctx, visible(ctx, filter))
+	total, err := r.countTodos(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page := options.Find().
+		SetSort(sort).
+		SetSkip(int64(query.Offset)).
+		SetLimit(int64(query.Limit))
+	if query.ByCursor {
+		if !query.After.IsZero() {
+			filter = AfterFilter(filter, query.After)
+		}
+		page = options.Find().
+			SetSort(IDSort()).
+			SetLimit(int64(query.Limit))
+	}
+	todos, err := r.filterTodos(ctx, filter, append(query.View.FindOptions(), page)...)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []*Todo{}, total, nil
+	}
+	return todos, total, err
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrEmptyQuery = errors.New("search query must not be empty")
+
+// indexNotFound is the server error code for a $text query on a collection
+// without a text index.
+const indexNotFound = 27
+
+// textIndex backs Search. A collection can only have one text index.
+var textIndex = mongo.IndexModel{
+	Keys: bson.D{primitive.E{Key: "text", Value: "text"}},
+}
+
+// Search returns the todos outside the trash and the archive whose text
+// matches query, best match first. Matching uses the text index, so words
+// are stemmed and case is ignored. completed, if not nil, narrows the results
+// to completed or pending todos. Unlike the other listings an empty result
+// is not an error.
+func (r *MongoRepository) Search(ctx context.Context, query string, completed *bool) ([]*Todo, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	filter := bson.D{
+		primitive.E{Key: "$text", Value: bson.M{"$search": query}},
+		notDeleted,
+		notArchived,
+	}
+	if completed != nil {
+		filter = append(filter, primitive.E{Key: "completed", Value: *completed})
+	}
+	filter = OwnedBy(ctx, visible(ctx, filter))
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{
+			primitive.E{Key: "score", Value: score},
+			primitive.E{Key: "_id", Value: 1},
+		})
+
+	todos, err := r.filterTodos(ctx, filter, opts)
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(indexNotFound) {
+		// Only the server creates indexes at startup, so a CLI run against
+		// a fresh database creates the text index itself.
+		if _, err := r.coll.Indexes().CreateOne(ctx, textIndex); err != nil {
+			return nil, deadlineError(err)
+		}
+		todos, err = r.filterTodos(ctx, filter, opts)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []*Todo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
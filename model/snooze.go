@@ -0,0 +1,79 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrSnoozeInPast = errors.New("snooze until a time in the future")
+
+// Awake narrows filter to todos that are not snoozed, or whose snooze has
+// run out. A snooze is never cleared, it simply stops matching.
+func Awake(filter bson.D) bson.D {
+	return append(filter, primitive.E{Key: "snoozed_until", Value: bson.M{"$not": bson.M{"$gt": Now()}}})
+}
+
+// ParseSnoozeDuration parses a Go duration such as "2h" or a whole number
+// of days such as "3d".
+func ParseSnoozeDuration(value string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.New("invalid duration " + strconv.Quote(value))
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(value); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 {
+		return 0, ErrSnoozeInPast
+	}
+	return d, nil
+}
+
+// Snooze hides the pending todo with the given id from the pending list
+// until the given time and returns it as updated. A missing todo is
+// mongo.ErrNoDocuments and a completed one ErrAlreadyCompleted.
+func (r *MongoRepository) Snooze(ctx context.Context, id string, until time.Time) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	if !until.After(Now()) {
+		return nil, ErrSnoozeInPast
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	pending := append(bson.D{primitive.E{Key: "completed", Value: false}}, filter...)
+	update := bson.M{"$set": bson.M{"snoozed_until": until, "updated_at": Now()}}
+
+	t := &Todo{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.coll.FindOneAndUpdate(ctx, pending, update, opts).Decode(t)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if err := r.coll.FindOne(ctx, filter).Err(); err != nil {
+			return nil, deadlineError(err)
+		}
+		return nil, ErrAlreadyCompleted
+	}
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	return t, nil
+}
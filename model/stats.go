@@ -0,0 +1,202 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// statsDays is how many days, today included, TodoStats breaks down.
+const statsDays = 30
+
+// DayStats counts the todos created and completed on one UTC day.
+type DayStats struct {
+	Date      string `json:"date" example:"2024-05-01"`
+	Created   int64  `json:"created" example:"4"`
+	Completed int64  `json:"completed" example:"3"`
+}
+
+// WeekStats compares the estimated and spent minutes of the todos completed
+// in one ISO week.
+type WeekStats struct {
+	Week            string `json:"week" example:"2024-W18"`
+	EstimateMinutes int64  `json:"estimate_minutes" example:"240"`
+	SpentMinutes    int64  `json:"spent_minutes" example:"310"`
+}
+
+// TodoStats summarizes the todos outside the trash and the archive.
+type TodoStats struct {
+	Total           int64       `json:"total" example:"12"`
+	Pending         int64       `json:"pending" example:"7"`
+	Completed       int64       `json:"completed" example:"5"`
+	Overdue         int64       `json:"overdue" example:"2"`
+	EstimateMinutes int64       `json:"estimate_minutes" example:"600"`
+	SpentMinutes    int64       `json:"spent_minutes" example:"480"`
+	Days            []DayStats  `json:"days"`
+	Weeks           []WeekStats `json:"weeks"`
+}
+
+// isoWeek formats the ISO week t falls in the way $dateToString does with
+// %G-W%V.
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// statsWindow is the range of days and ISO weeks TodoStats breaks down.
+type statsWindow struct {
+	now        time.Time
+	today      time.Time
+	since      time.Time
+	weeksSince time.Time
+}
+
+func currentStatsWindow() statsWindow {
+	now := Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	since := today.AddDate(0, 0, 1-statsDays)
+	// ISO weeks start on Monday.
+	weeksSince := since.AddDate(0, 0, -(int(since.Weekday())+6)%7)
+	return statsWindow{now: now, today: today, since: since, weeksSince: weeksSince}
+}
+
+// empty returns stats with zero counts for every day and week of w, along
+// with those days and weeks by date and ISO week.
+func (w statsWindow) empty() (*TodoStats, map[string]*DayStats, map[string]*WeekStats) {
+	stats := &TodoStats{Days: make([]DayStats, statsDays)}
+	days := make(map[string]*DayStats, statsDays)
+	for i := range stats.Days {
+		day := &stats.Days[i]
+		day.Date = w.since.AddDate(0, 0, i).Format(time.DateOnly)
+		days[day.Date] = day
+	}
+	weeks := map[string]*WeekStats{}
+	for start := w.weeksSince; !start.After(w.today); start = start.AddDate(0, 0, 7) {
+		stats.Weeks = append(stats.Weeks, WeekStats{Week: isoWeek(start)})
+	}
+	for i := range stats.Weeks {
+		weeks[stats.Weeks[i].Week] = &stats.Weeks[i]
+	}
+	return stats, days, weeks
+}
+
+// Stats counts the todos ctx can see in a single aggregation, along with
+// how many were created and completed on each of the last 30 days and the
+// estimated and spent minutes of those completed in each ISO week the 30
+// days touch, oldest first. Days and weeks without any are included with
+// zero counts.
+func (r *MongoRepository) Stats(ctx context.Context) (*TodoStats, error) {
+	w := currentStatsWindow()
+	now, since, weeksSince := w.now, w.since, w.weeksSince
+
+	perDay := func(field string) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{field: bson.M{"$gte": since}}},
+			bson.M{"$group": bson.M{
+				"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$" + field}},
+				"count": bson.M{"$sum": 1},
+			}},
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: OwnedBy(ctx, visible(ctx, AllFilter()))}},
+		{{Key: "$facet", Value: bson.M{
+			"counts": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":       nil,
+					"total":     bson.M{"$sum": 1},
+					"completed": bson.M{"$sum": bson.M{"$cond": bson.A{"$completed", 1, 0}}},
+					"estimate":  bson.M{"$sum": "$estimate_minutes"},
+					"spent":     bson.M{"$sum": "$spent_minutes"},
+					"overdue": bson.M{"$sum": bson.M{"$cond": bson.A{
+						bson.M{"$and": bson.A{
+							bson.M{"$not": bson.A{"$completed"}},
+							bson.M{"$eq": bson.A{bson.M{"$type": "$due_date"}, "date"}},
+							bson.M{"$lt": bson.A{"$due_date", now}},
+						}},
+						1, 0,
+					}}},
+				}},
+			},
+			"created":   perDay("created_at"),
+			"completed": perDay("completed_at"),
+			"weeks": bson.A{
+				bson.M{"$match": bson.M{"completed_at": bson.M{"$gte": weeksSince}}},
+				bson.M{"$group": bson.M{
+					"_id":      bson.M{"$dateToString": bson.M{"format": "%G-W%V", "date": "$completed_at"}},
+					"estimate": bson.M{"$sum": "$estimate_minutes"},
+					"spent":    bson.M{"$sum": "$spent_minutes"},
+				}},
+			},
+		}}},
+	}
+
+	var cur *mongo.Cursor
+	err := withRetry(ctx, func() (err error) {
+		cur, err = r.coll.Aggregate(ctx, pipeline, aggregateOptions(ctx))
+		return err
+	})
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	type dayCount struct {
+		Date  string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	var facets []struct {
+		Counts []struct {
+			Total     int64 `bson:"total"`
+			Completed int64 `bson:"completed"`
+			Overdue   int64 `bson:"overdue"`
+			Estimate  int64 `bson:"estimate"`
+			Spent     int64 `bson:"spent"`
+		} `bson:"counts"`
+		Created   []dayCount `bson:"created"`
+		Completed []dayCount `bson:"completed"`
+		Weeks     []struct {
+			Week     string `bson:"_id"`
+			Estimate int64  `bson:"estimate"`
+			Spent    int64  `bson:"spent"`
+		} `bson:"weeks"`
+	}
+	if err := cur.All(ctx, &facets); err != nil {
+		return nil, deadlineError(err)
+	}
+
+	stats, days, weeks := w.empty()
+	if len(facets) == 0 {
+		return stats, nil
+	}
+
+	facet := facets[0]
+	if len(facet.Counts) > 0 {
+		stats.Total = facet.Counts[0].Total
+		stats.Completed = facet.Counts[0].Completed
+		stats.Overdue = facet.Counts[0].Overdue
+		stats.EstimateMinutes = facet.Counts[0].Estimate
+		stats.SpentMinutes = facet.Counts[0].Spent
+		stats.Pending = stats.Total - stats.Completed
+	}
+	for _, c := range facet.Created {
+		if day, ok := days[c.Date]; ok {
+			day.Created = c.Count
+		}
+	}
+	for _, c := range facet.Completed {
+		if day, ok := days[c.Date]; ok {
+			day.Completed = c.Count
+		}
+	}
+	for _, w := range facet.Weeks {
+		if week, ok := weeks[w.Week]; ok {
+			week.EstimateMinutes = w.Estimate
+			week.SpentMinutes = w.Spent
+		}
+	}
+	return stats, nil
+}
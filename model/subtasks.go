@@ -0,0 +1,103 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrParentNotFound = errors.New("parent todo not found")
+	// ErrHasChildren is returned when deleting a todo that still has
+	// subtasks. Deletion never cascades; the subtasks must go first.
+	ErrHasChildren = errors.New("todo has subtasks, delete them first")
+)
+
+// ChildrenFilter matches the subtasks of the todo with the given id.
+func ChildrenFilter(parentId primitive.ObjectID) bson.D {
+	return bson.D{
+		notDeleted,
+		primitive.E{Key: "parent_id", Value: parentId},
+	}
+}
+
+// Children returns the subtasks of the todo with the given id. Unlike the
+// other listings an empty result is not an error.
+func (r *MongoRepository) Children(ctx context.Context, id string) ([]*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	todos, err := r.filterTodos(ctx, OwnedBy(ctx, ChildrenFilter(objectId)))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []*Todo{}, nil
+	}
+	return todos, err
+}
+
+// checkParent returns ErrParentNotFound unless parentId is a todo outside
+// the trash.
+func (r *MongoRepository) checkParent(ctx context.Context, parentId primitive.ObjectID) error {
+	n, err := r.coll.CountDocuments(ctx, OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: parentId}, notDeleted}))
+	if err != nil {
+		return deadlineError(err)
+	}
+	if n == 0 {
+		return ErrParentNotFound
+	}
+	return nil
+}
+
+// checkNoChildren returns ErrHasChildren if the todo has subtasks outside
+// the trash.
+func (r *MongoRepository) checkNoChildren(ctx context.Context, id primitive.ObjectID) error {
+	n, err := r.coll.CountDocuments(ctx, ChildrenFilter(id))
+	if err != nil {
+		return deadlineError(err)
+	}
+	if n > 0 {
+		return ErrHasChildren
+	}
+	return nil
+}
+
+// treeOrder reorders todos in place so subtasks directly follow their
+// parent, and returns each todo's nesting depth. Subtasks whose parent is
+// not among todos stay where they are at depth 0.
+func treeOrder(todos []*Todo) []int {
+	present := make(map[primitive.ObjectID]bool, len(todos))
+	children := make(map[primitive.ObjectID][]*Todo)
+	for _, t := range todos {
+		present[t.ID] = true
+	}
+
+	var roots []*Todo
+	for _, t := range todos {
+		if t.ParentID != nil && present[*t.ParentID] && *t.ParentID != t.ID {
+			children[*t.ParentID] = append(children[*t.ParentID], t)
+		} else {
+			roots = append(roots, t)
+		}
+	}
+
+	ordered := make([]*Todo, 0, len(todos))
+	depths := make([]int, 0, len(todos))
+	var visit func(t *Todo, depth int)
+	visit = func(t *Todo, depth int) {
+		ordered = append(ordered, t)
+		depths = append(depths, depth)
+		for _, child := range children[t.ID] {
+			visit(child, depth+1)
+		}
+	}
+	for _, t := range roots {
+		visit(t, 0)
+	}
+
+	copy(todos, ordered)
+	return depths
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NormalizeTags returns tags in the form they are stored: normalized,
+// lowercased and without empty entries or duplicates, in first-seen order.
+func NormalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(NormalizeText(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+// TagFilter narrows filter to todos carrying tag.
+func TagFilter(filter bson.D, tag string) bson.D {
+	tag = strings.ToLower(NormalizeText(tag))
+	return append(filter, primitive.E{Key: "tags", Value: bson.M{"$in": bson.A{tag}}})
+}
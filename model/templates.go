@@ -0,0 +1,201 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrDuplicateTemplate = errors.New("a template with this name already exists")
+
+// TemplateItem is one todo a template creates.
+type TemplateItem struct {
+	Text    string   `json:"text" bson:"text" binding:"required,max=500" example:"Tag the release"`
+	Tags    []string `json:"tags,omitempty" bson:"tags,omitempty" binding:"max=20,dive,max=32"`
+	Project string   `json:"project,omitempty" bson:"project,omitempty" binding:"max=64" example:"release"`
+}
+
+// Template is a named list of todos that can be created again and again,
+// such as a release checklist.
+type Template struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	Name      string             `json:"name" bson:"name" example:"release steps"`
+	Owner     string             `json:"owner,omitempty" bson:"owner,omitempty"`
+	Items     []TemplateItem     `json:"items" bson:"items"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+func (r *MongoRepository) templatesCollection() *mongo.Collection {
+	return r.coll.Database().Collection("todo_templates")
+}
+
+// templateNameIndex keeps template names unique per owner.
+var templateNameIndex = mongo.IndexModel{
+	Keys: bson.D{
+		primitive.E{Key: "owner", Value: 1},
+		primitive.E{Key: "name", Value: 1},
+	},
+	Options: options.Index().SetUnique(true),
+}
+
+func normalizeTemplate(t *Template) {
+	t.Name = NormalizeText(t.Name)
+	for i := range t.Items {
+		t.Items[i].Text = NormalizeText(t.Items[i].Text)
+		t.Items[i].Tags = NormalizeTags(t.Items[i].Tags)
+		t.Items[i].Project = NormalizeProject(t.Items[i].Project)
+	}
+}
+
+// CreateTemplate inserts t for the owner ctx is scoped to. A name already
+// in use is ErrDuplicateTemplate.
+func (r *MongoRepository) CreateTemplate(ctx context.Context, t *Template) error {
+	t.ID = primitive.NewObjectID()
+	t.CreatedAt = Now()
+	t.UpdatedAt = t.CreatedAt
+	t.Owner, _ = ownerFrom(ctx)
+	normalizeTemplate(t)
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	_, err := r.templatesCollection().InsertOne(ctx, t)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateTemplate
+	}
+	return deadlineError(err)
+}
+
+// Templates returns the templates ctx can see in name order.
+func (r *MongoRepository) Templates(ctx context.Context) ([]*Template, error) {
+	opts := findOptions(ctx).SetSort(bson.D{primitive.E{Key: "name", Value: 1}})
+	cur, err := r.templatesCollection().Find(ctx, OwnedBy(ctx, bson.D{}), opts)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	templates := []*Template{}
+	if err := cur.All(ctx, &templates); err != nil {
+		return nil, deadlineError(err)
+	}
+	return templates, nil
+}
+
+// GetTemplateByID returns the template with the given id. A missing
+// template is mongo.ErrNoDocuments.
+func (r *MongoRepository) GetTemplateByID(ctx context.Context, id string) (*Template, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.findTemplate(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}})
+}
+
+// GetTemplateByName returns the template with the given name. A missing
+// template is mongo.ErrNoDocuments.
+func (r *MongoRepository) GetTemplateByName(ctx context.Context, name string) (*Template, error) {
+	return r.findTemplate(ctx, bson.D{primitive.E{Key: "name", Value: NormalizeText(name)}})
+}
+
+func (r *MongoRepository) findTemplate(ctx context.Context, filter bson.D) (*Template, error) {
+	t := &Template{}
+	if err := r.templatesCollection().FindOne(ctx, OwnedBy(ctx, filter)).Decode(t); err != nil {
+		return nil, deadlineError(err)
+	}
+	return t, nil
+}
+
+// UpdateTemplate replaces the name and items of the template with the given
+// id and returns it as updated. A missing template is mongo.ErrNoDocuments
+// and a name already in use ErrDuplicateTemplate.
+func (r *MongoRepository) UpdateTemplate(ctx context.Context, id string, t *Template) (*Template, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	normalizeTemplate(t)
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}})
+	update := bson.M{"$set": bson.M{"name": t.Name, "items": t.Items, "updated_at": Now()}}
+	updated := &Template{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.templatesCollection().FindOneAndUpdate(ctx, filter, update, opts).Decode(updated)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrDuplicateTemplate
+	}
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	return updated, nil
+}
+
+// DeleteTemplate permanently removes the template with the given id. The
+// todos created from it are kept. A missing template is
+// mongo.ErrNoDocuments.
+func (r *MongoRepository) DeleteTemplate(ctx context.Context, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	res, err := r.templatesCollection().DeleteOne(ctx, OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}}))
+	if err != nil {
+		return deadlineError(err)
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// InstantiateTemplate creates a pending todo in repo for every item of t, in
+// item order, with a single CreateMany, and returns them. With
+// RejectDuplicates set, an item duplicating a pending todo is
+// ErrDuplicateTodo, though the other items are still created.
+func InstantiateTemplate(ctx context.Context, repo TodoRepository, t *Template) ([]*Todo, error) {
+	now := Now()
+	todos := make([]*Todo, len(t.Items))
+	for i, item := range t.Items {
+		todos[i] = &Todo{
+			ID:        primitive.NewObjectID(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Text:      item.Text,
+			Tags:      item.Tags,
+			Project:   item.Project,
+		}
+	}
+
+	if err := repo.CreateMany(ctx, todos); err != nil {
+		return nil, duplicateError(err)
+	}
+	return todos, nil
+}
+
+// TemplateFromPending builds a template named name from the pending todos
+// ctx can see, in their manual order. Having no pending todos is
+// mongo.ErrNoDocuments.
+func (r *MongoRepository) TemplateFromPending(ctx context.Context, name string) (*Template, error) {
+	todos, err := r.filterTodos(ctx, OwnedBy(ctx, PendingFilter()), options.Find().SetSort(PositionSort()))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Template{Name: name, Items: make([]TemplateItem, len(todos))}
+	for i, todo := range todos {
+		t.Items[i] = TemplateItem{Text: todo.Text, Tags: todo.Tags, Project: todo.Project}
+	}
+	return t, nil
+}
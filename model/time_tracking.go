@@ -0,0 +1,42 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidMinutes = errors.New("minutes must be positive")
+
+// LogTime adds minutes to the time spent on the todo with the given id and
+// returns it as updated. The time is added with $inc, so concurrent logs
+// all count. A missing todo is mongo.ErrNoDocuments.
+func (r *MongoRepository) LogTime(ctx context.Context, id string, minutes int) (*Todo, error) {
+	if minutes <= 0 {
+		return nil, ErrInvalidMinutes
+	}
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	update := bson.M{
+		"$inc": bson.M{"spent_minutes": minutes},
+		"$set": bson.M{"updated_at": Now()},
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	t := &Todo{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := r.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(t); err != nil {
+		return nil, deadlineError(err)
+	}
+
+	return t, nil
+}
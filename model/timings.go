@@ -0,0 +1,86 @@
+package model
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Timings accumulates the time a request spends waiting on each backing
+// store, so slow requests can be attributed to Mongo, Redis or handler code.
+type Timings struct {
+	mongo atomic.Int64
+	redis atomic.Int64
+}
+
+func (t *Timings) Mongo() time.Duration {
+	return time.Duration(t.mongo.Load())
+}
+
+func (t *Timings) Redis() time.Duration {
+	return time.Duration(t.redis.Load())
+}
+
+type timingsKey struct{}
+
+// WithTimings returns a context that store calls made with it report their
+// durations to, along with the Timings they are accumulated in.
+func WithTimings(ctx context.Context) (context.Context, *Timings) {
+	t := &Timings{}
+	return context.WithValue(ctx, timingsKey{}, t), t
+}
+
+func timingsFrom(ctx context.Context) *Timings {
+	t, _ := ctx.Value(timingsKey{}).(*Timings)
+	return t
+}
+
+// mongoTimingMonitor adds the duration of every Mongo command to the
+// Timings of the context it was issued with.
+var mongoTimingMonitor = &event.CommandMonitor{
+	Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+		if t := timingsFrom(ctx); t != nil {
+			t.mongo.Add(int64(e.Duration))
+		}
+	},
+	Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+		if t := timingsFrom(ctx); t != nil {
+			t.mongo.Add(int64(e.Duration))
+		}
+	},
+}
+
+type redisStartKey struct{}
+
+// redisTimingHook adds the duration of every Redis command to the Timings of
+// the context it was issued with.
+type redisTimingHook struct{}
+
+func (redisTimingHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (redisTimingHook) AfterProcess(ctx context.Context, _ redis.Cmder) error {
+	recordRedisTime(ctx)
+	return nil
+}
+
+func (redisTimingHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (redisTimingHook) AfterProcessPipeline(ctx context.Context, _ []redis.Cmder) error {
+	recordRedisTime(ctx)
+	return nil
+}
+
+func recordRedisTime(ctx context.Context) {
+	t := timingsFrom(ctx)
+	start, ok := ctx.Value(redisStartKey{}).(time.Time)
+	if t != nil && ok {
+		t.redis.Add(int64(time.Since(start)))
+	}
+}
@@ -3,8 +3,12 @@ package model
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -15,18 +19,31 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var Collection *mongo.Collection
+var mongoURI string
 
-func init() {
+// Connect loads .env and returns the configured collection, creating it if
+// needed. It must be called before anything talks to MongoDB; failing to
+// connect is fatal.
+func Connect() *mongo.Collection {
 	var ctx = context.TODO()
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatalf("unable to load .env file: %e", err)
 	}
 
-	mongoURI := os.Getenv("DB_URI")
+	mongoURI = os.Getenv("DB_URI")
 	databaseName := os.Getenv("DB_NAME")
+	if databaseName == "" {
+		log.Fatal("DB_NAME must be set")
+	}
 	collectionName := os.Getenv("DB_COLLECTION_NAME")
+	if collectionName == "" {
+		log.Fatal("DB_COLLECTION_NAME must be set")
+	}
+
+	if err := loadOpTimeouts(); err != nil {
+		log.Fatal(err)
+	}
 
 	credential := options.Credential{
 		Username: os.Getenv("DB_USERNAME"),
@@ -35,6 +52,13 @@ func init() {
 
 	clientOptions := options.Client().ApplyURI(mongoURI)
 	clientOptions.SetAuth(credential)
+	clientOptions.SetMonitor(mongoTimingMonitor)
+	clientOptions.SetServerMonitor(mongoServerMonitor)
+	clientOptions.SetRetryReads(true)
+	clientOptions.SetRetryWrites(true)
+	if ChaosEnabled() {
+		clientOptions.SetDialer(&chaosDialer{})
+	}
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		log.Fatal(err)
@@ -45,99 +69,497 @@ func init() {
 		log.Fatal(err)
 	}
 
-	Collection = client.Database(databaseName).Collection(collectionName)
+	collection, err := UseCollection(ctx, client.Database(databaseName), collectionName, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return collection
+}
+
+// UseCollection returns collectionName in db. A missing collection is
+// created when create is true, with a log line saying so, and is an error
+// otherwise; either way a typo'd name never silently reads as an empty todo
+// list.
+func UseCollection(ctx context.Context, db *mongo.Database, collectionName string, create bool) (*mongo.Collection, error) {
+	namespace := db.Name() + "." + collectionName
+
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": collectionName})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		if !create {
+			return nil, fmt.Errorf("collection %s does not exist", namespace)
+		}
+
+		log.Printf("Collection %s does not exist, creating it", namespace)
+		err = db.CreateCollection(ctx, collectionName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return db.Collection(collectionName), nil
+}
+
+// RedactedURI returns DB_URI without its user info, safe for logs and admin
+// diagnostics endpoints.
+func RedactedURI() string {
+	u, err := url.Parse(mongoURI)
+	if err != nil {
+		return "unparseable"
+	}
+
+	u.User = nil
+	return u.String()
 }
 
 type TodoDocInput struct {
-	Text      string `json:"text" bson:"text"`
-	Completed bool   `json:"completed" bson:"completed"`
+	Text            string     `json:"text" bson:"text"`
+	Completed       bool       `json:"completed" bson:"completed"`
+	DueDate         *time.Time `json:"due_date,omitempty" bson:"due_date,omitempty"`
+	Tags            []string   `json:"tags,omitempty" bson:"tags,omitempty" binding:"max=20,dive,max=32"`
+	ParentID        *string    `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	Recurrence      string     `json:"recurrence,omitempty" bson:"recurrence,omitempty" example:"weekly"`
+	RemindAt        *time.Time `json:"remind_at,omitempty" bson:"remind_at,omitempty"`
+	Project         string     `json:"project,omitempty" bson:"project,omitempty" binding:"max=64" example:"home"`
+	Color           string     `json:"color,omitempty" bson:"color,omitempty" binding:"omitempty,todocolor" example:"blue"`
+	EstimateMinutes int        `json:"estimate_minutes,omitempty" bson:"estimate_minutes,omitempty" binding:"gte=0" example:"30"`
+	BlockedBy       []string   `json:"blocked_by,omitempty" bson:"blocked_by,omitempty" binding:"max=50"`
+}
+
+// Todo returns a todo with the editable fields of in, leaving the id,
+// timestamps, owner and every field the server maintains unset. parent_id
+// and blocked_by must be ObjectIDs.
+func (in *TodoDocInput) Todo() (*Todo, error) {
+	todo := &Todo{
+		Text:            in.Text,
+		Completed:       in.Completed,
+		DueDate:         in.DueDate,
+		Tags:            in.Tags,
+		Recurrence:      in.Recurrence,
+		RemindAt:        in.RemindAt,
+		Project:         in.Project,
+		Color:           in.Color,
+		EstimateMinutes: in.EstimateMinutes,
+	}
+	if in.ParentID != nil {
+		parentId, err := primitive.ObjectIDFromHex(*in.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("parent_id %q is not a valid id", *in.ParentID)
+		}
+		todo.ParentID = &parentId
+	}
+	for _, hex := range in.BlockedBy {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return nil, fmt.Errorf("blocked_by %q is not a valid id", hex)
+		}
+		todo.BlockedBy = append(todo.BlockedBy, id)
+	}
+	return todo, nil
 }
 
 type Todo struct {
-	ID        primitive.ObjectID `json:"_id" bson:"_id"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
-	Text      string             `json:"text" bson:"text"`
-	Completed bool               `json:"completed" bson:"completed"`
+	ID              primitive.ObjectID   `json:"_id" bson:"_id"`
+	CreatedAt       time.Time            `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at" bson:"updated_at"`
+	Text            string               `json:"text" bson:"text"`
+	Completed       bool                 `json:"completed" bson:"completed"`
+	NormalizedText  string               `json:"-" bson:"normalized_text"`
+	PendingText     string               `json:"-" bson:"pending_text,omitempty"`
+	StaleSince      *time.Time           `json:"stale_since,omitempty" bson:"stale_since,omitempty"`
+	SnoozedUntil    *time.Time           `json:"snoozed_until,omitempty" bson:"snoozed_until,omitempty"`
+	DueDate         *time.Time           `json:"due_date,omitempty" bson:"due_date,omitempty"`
+	Tags            []string             `json:"tags,omitempty" bson:"tags,omitempty" binding:"max=20,dive,max=32"`
+	DeletedAt       *time.Time           `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	Archived        bool                 `json:"archived" bson:"archived,omitempty"`
+	Pinned          bool                 `json:"pinned" bson:"pinned,omitempty"`
+	Color           string               `json:"color,omitempty" bson:"color,omitempty" binding:"omitempty,todocolor"`
+	EstimateMinutes int                  `json:"estimate_minutes,omitempty" bson:"estimate_minutes,omitempty" binding:"gte=0"`
+	SpentMinutes    int                  `json:"spent_minutes,omitempty" bson:"spent_minutes,omitempty"`
+	ParentID        *primitive.ObjectID  `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	Recurrence      string               `json:"recurrence,omitempty" bson:"recurrence,omitempty"`
+	RecurrenceDay   int                  `json:"-" bson:"recurrence_day,omitempty"`
+	RemindAt        *time.Time           `json:"remind_at,omitempty" bson:"remind_at,omitempty"`
+	RemindedAt      *time.Time           `json:"reminded_at,omitempty" bson:"reminded_at,omitempty"`
+	Reminders       []time.Time          `json:"reminders,omitempty" bson:"reminders,omitempty"`
+	FiredReminders  []time.Time          `json:"fired_reminders,omitempty" bson:"fired_reminders,omitempty"`
+	NextReminderAt  *time.Time           `json:"next_reminder_at,omitempty" bson:"next_reminder_at,omitempty"`
+	CompletedAt     *time.Time           `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	Owner           string               `json:"owner,omitempty" bson:"owner,omitempty"`
+	Project         string               `json:"project,omitempty" bson:"project,omitempty" binding:"max=64"`
+	Position        float64              `json:"position" bson:"position"`
+	FollowupOf      *primitive.ObjectID  `json:"followup_of,omitempty" bson:"followup_of,omitempty"`
+	BlockedBy       []primitive.ObjectID `json:"blocked_by,omitempty" bson:"blocked_by,omitempty" binding:"max=50"`
+	LastNote        *Note                `json:"last_note,omitempty" bson:"-"`
+}
+
+// textFilter matches todos by text, falling back to the raw text for
+// documents written before normalized_text existed.
+func textFilter(text string) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"normalized_text": MatchKey(text)},
+		bson.M{"text": text},
+	}}
 }
 
-func CreateTodo(ctx context.Context, todo *Todo) error {
-	_, err := Collection.InsertOne(ctx, todo)
-	return err
+// prepareNewTodo normalizes todo the way every create path stores it. It
+// gives todo to the owner ctx is scoped to, drops the reminder state only
+// a stored todo can have and, when RejectDuplicates is set, claims the
+// text of a pending todo in pendingTextIndex.
+func prepareNewTodo(ctx context.Context, todo *Todo) {
+	if owner, ok := ownerFrom(ctx); ok {
+		todo.Owner = owner
+	}
+	todo.Text = NormalizeText(todo.Text)
+	todo.NormalizedText = MatchKey(todo.Text)
+	todo.Tags = NormalizeTags(todo.Tags)
+	todo.Project = NormalizeProject(todo.Project)
+	todo.Color = NormalizeColor(todo.Color)
+	todo.RemindedAt = nil
+	todo.Reminders = nil
+	todo.FiredReminders = nil
+	todo.NextReminderAt = nil
+	todo.PendingText = ""
+	if RejectDuplicates() && !todo.Completed {
+		todo.PendingText = todo.NormalizedText
+	}
+	if todo.Position == 0 {
+		todo.Position = initialPosition(todo)
+	}
 }
 
-func GetAll(ctx context.Context) ([]*Todo, error) {
-	filter := bson.D{{}}
-	return FilterTodos(ctx, filter)
+// Create inserts todo, returning ErrParentNotFound if it names a parent
+// that does not exist and an *InvalidRecurrenceError for a bad recurrence.
+// When RejectDuplicates is set, a pending todo of the same owner with the
+// same text is ErrDuplicateTodo.
+func (r *MongoRepository) Create(ctx context.Context, todo *Todo) error {
+	prepareNewTodo(ctx, todo)
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	if err := r.Validate(ctx, todo); err != nil {
+		return err
+	}
+
+	_, err := r.coll.InsertOne(ctx, todo)
+	return duplicateError(deadlineError(err))
 }
 
-func GetTodoById(ctx context.Context, id string) (*Todo, error) {
+// Validate runs the checks Create makes before inserting todo, for
+// callers inserting with CreateMany: an *InvalidRecurrenceError for a
+// bad recurrence, ErrParentNotFound for a parent that does not exist,
+// ErrBlockerNotFound for a blocking todo that does not exist and a
+// *BlockedError for a completed todo with pending blocking todos.
+func (r *MongoRepository) Validate(ctx context.Context, todo *Todo) error {
+	if todo.Recurrence != "" {
+		if _, err := ParseRecurrence(todo.Recurrence); err != nil {
+			return err
+		}
+	}
+	if todo.ParentID != nil {
+		if err := r.checkParent(ctx, *todo.ParentID); err != nil {
+			return err
+		}
+	}
+	if err := r.checkBlockers(ctx, todo.ID, todo.BlockedBy); err != nil {
+		return err
+	}
+	if todo.Completed {
+		return r.checkUnblocked(ctx, todo.BlockedBy)
+	}
+	return nil
+}
+
+// notDeleted excludes todos in the trash. Every listing filter starts with
+// it; the trash itself is read with DeletedFilter.
+var notDeleted = primitive.E{Key: "deleted_at", Value: bson.M{"$exists": false}}
+
+// notArchived keeps archived todos out of the default listings; see
+// WithArchived.
+var notArchived = primitive.E{Key: "archived", Value: bson.M{"$ne": true}}
+
+func AllFilter() bson.D {
+	return bson.D{notDeleted, notArchived}
+}
+
+func PendingFilter() bson.D {
+	return bson.D{
+		notDeleted,
+		notArchived,
+		primitive.E{Key: "completed", Value: false},
+	}
+}
+
+// WithArchived widens filter to also match archived todos.
+func WithArchived(filter bson.D) bson.D {
+	widened := make(bson.D, 0, len(filter))
+	for _, e := range filter {
+		if e.Key != notArchived.Key {
+			widened = append(widened, e)
+		}
+	}
+	return widened
+}
+
+func FinishedFilter() bson.D {
+	return bson.D{
+		notDeleted,
+		primitive.E{Key: "completed", Value: true},
+	}
+}
+
+// FinishedSort orders finished todos most recently completed first. Todos
+// completed before completed_at was recorded sort last.
+func FinishedSort() bson.D {
+	return bson.D{
+		primitive.E{Key: "completed_at", Value: -1},
+		primitive.E{Key: "_id", Value: 1},
+	}
+}
+
+// OverdueFilter matches pending todos whose due date has passed.
+func OverdueFilter() bson.D {
+	return bson.D{
+		notDeleted,
+		primitive.E{Key: "completed", Value: false},
+		primitive.E{Key: "due_date", Value: bson.M{"$lt": Now()}},
+	}
+}
+
+// CreateMany inserts todos with a single unordered InsertMany, so one
+// failing document does not prevent the others from being written. Failures
+// are reported as a mongo.BulkWriteException indexed by position in todos,
+// with a duplicate key error carrying the message of ErrDuplicateTodo. The
+// todos are prepared like those of Create.
+func (r *MongoRepository) CreateMany(ctx context.Context, todos []*Todo) error {
+	docs := make([]interface{}, len(todos))
+	for i, todo := range todos {
+		prepareNewTodo(ctx, todo)
+		docs[i] = todo
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	_, err := r.coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) {
+		for i, writeErr := range bulkErr.WriteErrors {
+			if mongo.IsDuplicateKeyError(writeErr.WriteError) {
+				bulkErr.WriteErrors[i].Message = ErrDuplicateTodo.Error()
+			}
+		}
+		return bulkErr
+	}
+	return deadlineError(err)
+}
+
+// GetByID returns the todo with the given id. A todo in the trash is
+// mongo.ErrNoDocuments, like a missing one.
+func (r *MongoRepository) GetByID(ctx context.Context, id string) (*Todo, error) {
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
 
-	filter := bson.M{"_id": objectId}
-	t := &Todo{}
-	err = Collection.FindOne(ctx, filter).Decode(t)
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	opts := options.FindOne()
+	if budget, ok := opBudget(ctx); ok {
+		opts.SetMaxTime(budget)
+	}
+	var raw bson.Raw
+	err = withRetry(ctx, func() (err error) {
+		raw, err = r.coll.FindOne(ctx, filter, opts).Raw()
+		return err
+	})
 	if err != nil {
-		return t, err
+		return &Todo{}, deadlineError(err)
 	}
 
-	return t, nil
+	return r.decodeTodo(raw)
 }
 
-func UpdateTodo(ctx context.Context, todo *Todo, id string) error {
+// Update replaces the editable fields of the todo with the given id, which
+// must not be in the trash, and records the fields it changed in the todo's history. Completing a
+// recurring todo also creates its next occurrence. New blocking todos must
+// exist and must not form a cycle, and completing a todo whose blocking todos
+// are pending is refused with a *BlockedError.
+func (r *MongoRepository) Update(ctx context.Context, id string, todo *Todo) error {
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
+	if todo.Recurrence != "" {
+		if _, err := ParseRecurrence(todo.Recurrence); err != nil {
+			return err
+		}
+	}
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
 
-	filter := bson.D{primitive.E{
-		Key: "_id", Value: objectId,
-	}}
 	t := &Todo{}
-	err = Collection.FindOne(ctx, filter).Decode(t)
+	err = r.coll.FindOne(ctx, filter).Decode(t)
+	if err != nil {
+		return deadlineError(err)
+	}
+	version, conditional := versionFrom(ctx)
+	if conditional {
+		if !sameVersion(t.UpdatedAt, version) {
+			return ErrVersionMismatch
+		}
+		filter = append(filter, primitive.E{Key: "updated_at", Value: t.UpdatedAt})
+	}
+
+	set := bson.M{
+		"completed":       todo.Completed,
+		"text":            NormalizeText(todo.Text),
+		"normalized_text": MatchKey(todo.Text),
+		"updated_at":      Now(),
+	}
+	unset := bson.M{
+		"stale_since": "",
+	}
+	switch {
+	case todo.Completed && !t.Completed:
+		set["completed_at"] = Now()
+	case !todo.Completed:
+		unset["completed_at"] = ""
+	}
+	if todo.Completed {
+		unset["pending_text"] = ""
+		withoutReminders(unset)
+	} else if t.PendingText != "" {
+		set["pending_text"] = MatchKey(todo.Text)
+	}
+	if todo.DueDate != nil {
+		set["due_date"] = todo.DueDate
+	} else {
+		unset["due_date"] = ""
+	}
+	if tags := NormalizeTags(todo.Tags); len(tags) > 0 {
+		set["tags"] = tags
+	} else {
+		unset["tags"] = ""
+	}
+	if project := NormalizeProject(todo.Project); project != "" {
+		set["project"] = project
+	} else {
+		unset["project"] = ""
+	}
+	if len(todo.BlockedBy) > 0 {
+		set["blocked_by"] = todo.BlockedBy
+	} else {
+		unset["blocked_by"] = ""
+	}
+	if todo.EstimateMinutes > 0 {
+		set["estimate_minutes"] = todo.EstimateMinutes
+	} else {
+		unset["estimate_minutes"] = ""
+	}
+	if c := NormalizeColor(todo.Color); c != "" {
+		set["color"] = c
+	} else {
+		unset["color"] = ""
+	}
+	if todo.Recurrence != "" {
+		set["recurrence"] = todo.Recurrence
+	} else {
+		unset["recurrence"] = ""
+	}
+	if todo.RemindAt != nil {
+		set["remind_at"] = todo.RemindAt
+		if t.RemindAt == nil || !t.RemindAt.Equal(*todo.RemindAt) {
+			unset["reminded_at"] = ""
+		}
+	} else {
+		unset["remind_at"] = ""
+		unset["reminded_at"] = ""
+	}
+	update := bson.M{"$set": set, "$unset": unset}
+	changed, err := changedFields(t, set, unset)
 	if err != nil {
 		return err
 	}
+	if slices.Contains(changed, "blocked_by") {
+		if err := r.checkBlockers(ctx, objectId, todo.BlockedBy); err != nil {
+			return err
+		}
+	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"completed":  todo.Completed,
-			"text":       todo.Text,
-			"updated_at": time.Now(),
-		},
+	// Only the request that actually completes the todo spawns the next
+	// occurrence, however many race to complete it.
+	completing := todo.Completed && !t.Completed
+	if completing {
+		if err := r.checkUnblocked(ctx, todo.BlockedBy); err != nil {
+			return err
+		}
+	}
+	if completing {
+		filter = append(filter, primitive.E{Key: "completed", Value: false})
 	}
 
-	_, err = Collection.UpdateOne(ctx, filter, update)
+	res, err := r.coll.UpdateOne(ctx, filter, update)
 	if err != nil {
+		return duplicateError(deadlineError(err))
+	}
+	if conditional && res.MatchedCount == 0 {
+		return ErrVersionMismatch
+	}
+
+	if res.ModifiedCount == 1 && len(changed) > 0 {
+		action := HistoryUpdate
+		if completing {
+			action = HistoryComplete
+		}
+		r.recordHistory(ctx, action, t, changed)
+	}
+
+	if completing && res.ModifiedCount == 1 && todo.Recurrence != "" {
+		t.Text = NormalizeText(todo.Text)
+		t.DueDate = todo.DueDate
+		t.Tags = todo.Tags
+		t.Project = NormalizeProject(todo.Project)
+		t.Recurrence = todo.Recurrence
+		_, err := r.spawnNextOccurrence(ctx, t)
 		return err
 	}
 
 	return nil
 }
 
-func FilterTodos(ctx context.Context, filter interface{}) ([]*Todo, error) {
+// filterTodos returns the todos matching filter, with find options such as
+// a limit, skip or sort applied to the query. Matching nothing is
+// mongo.ErrNoDocuments.
+func (r *MongoRepository) filterTodos(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]*Todo, error) {
 	var todos []*Todo
 
-	cur, err := Collection.Find(ctx, filter)
+	opts = append([]*options.FindOptions{findOptions(ctx)}, opts...)
+	var cur *mongo.Cursor
+	err := withRetry(ctx, func() (err error) {
+		cur, err = r.coll.Find(ctx, filter, opts...)
+		return err
+	})
 	if err != nil {
-		return todos, err
+		return todos, deadlineError(err)
 	}
 
 	for cur.Next(ctx) {
-		var t Todo
-		err := cur.Decode(&t)
+		t, err := r.decodeTodo(cur.Current)
 		if err != nil {
 			return todos, err
 		}
 
-		todos = append(todos, &t)
+		todos = append(todos, t)
 	}
 
 	if err := cur.Err(); err != nil {
-		return todos, err
+		return todos, deadlineError(err)
 	}
 
 	err = cur.Close(ctx)
@@ -152,76 +574,307 @@ func FilterTodos(ctx context.Context, filter interface{}) ([]*Todo, error) {
 	return todos, nil
 }
 
-func CompleteTodo(ctx context.Context, text string) error {
-	filter := bson.D{primitive.E{Key: "text", Value: text}}
+// Stream calls fn for each todo outside the trash and the archive as it is
+// read from the cursor, without collecting the results, and returns how many
+// were visited.
+func (r *MongoRepository) Stream(ctx context.Context, fn func(*Todo) error) (int64, error) {
+	var count int64
 
-	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
-		primitive.E{Key: "completed", Value: true},
-	}}}
+	cur, err := r.coll.Find(ctx, OwnedBy(ctx, visible(ctx, AllFilter())), findOptions(ctx))
+	if err != nil {
+		return count, deadlineError(err)
+	}
+	defer cur.Close(ctx)
 
-	t := &Todo{}
-	return Collection.FindOneAndUpdate(ctx, filter, update).Decode(t)
-}
+	for cur.Next(ctx) {
+		t, err := r.decodeTodo(cur.Current)
+		if err != nil {
+			return count, err
+		}
 
-func GetPending(ctx context.Context) ([]*Todo, error) {
-	filter := bson.D{
-		primitive.E{Key: "completed", Value: false},
+		if err := fn(t); err != nil {
+			return count, err
+		}
+		count++
 	}
 
-	return FilterTodos(ctx, filter)
+	return count, deadlineError(cur.Err())
 }
 
-func GetFinished(ctx context.Context) ([]*Todo, error) {
-	filter := bson.D{
-		primitive.E{Key: "completed", Value: true},
+// Complete completes the todo with the given id, creating the next
+// occurrence if it recurs, and returns it as updated. Completing a completed
+// todo changes nothing. A missing todo is mongo.ErrNoDocuments and one with
+// pending blocking todos a *BlockedError.
+func (r *MongoRepository) Complete(ctx context.Context, id string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	t := &Todo{}
+	if err := r.coll.FindOne(ctx, filter).Decode(t); err != nil {
+		return nil, deadlineError(err)
+	}
+	if t.Completed {
+		return t, nil
+	}
+	if err := r.completeTodo(ctx, t); err != nil {
+		return nil, deadlineError(err)
 	}
 
-	return FilterTodos(ctx, filter)
+	if err := r.coll.FindOne(ctx, filter).Decode(t); err != nil {
+		return nil, deadlineError(err)
+	}
+	return t, nil
 }
 
-func DeleteTodoById(ctx context.Context, id string) error {
-	objectId, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
+// completeTodo completes the pending todo t unless it is blocked.
+func (r *MongoRepository) completeTodo(ctx context.Context, t *Todo) error {
+	if err := r.checkUnblocked(ctx, t.BlockedBy); err != nil {
 		return err
 	}
 
-	filter := bson.M{"_id": objectId}
-
-	res, err := Collection.DeleteOne(ctx, filter)
+	// Only the call that actually completes the todo records it and spawns
+	// the next occurrence, however many race to complete it.
+	now := Now()
+	update := bson.M{
+		"$set":   bson.M{"completed": true, "completed_at": now, "updated_at": now},
+		"$unset": withoutReminders(bson.M{"pending_text": ""}),
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.D{
+		primitive.E{Key: "_id", Value: t.ID},
+		primitive.E{Key: "completed", Value: false},
+	}, update)
 	if err != nil {
 		return err
 	}
-
-	if res.DeletedCount == 0 {
-		return errors.New("no todos were deleted")
+	if res.ModifiedCount == 0 {
+		return nil
 	}
 
+	r.recordHistory(ctx, HistoryComplete, t, []string{"completed", "completed_at"})
+	if t.Recurrence != "" {
+		_, err := r.spawnNextOccurrence(ctx, t)
+		return err
+	}
 	return nil
 }
 
-func DeleteTodo(ctx context.Context, text string) error {
-	filter := bson.D{
-		primitive.E{Key: "text", Value: text},
+// CompleteMany completes the pending todos among ids and returns how many
+// it completed, along with the ids of the next occurrences it created.
+// Todos that are not recurring are completed with a single UpdateMany;
+// recurring ones are completed one at a time so that exactly one next
+// occurrence is created for each. Ids of missing, already completed and
+// blocked todos are skipped.
+func (r *MongoRepository) CompleteMany(ctx context.Context, ids []primitive.ObjectID) (int64, []primitive.ObjectID, error) {
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{
+		primitive.E{Key: "_id", Value: bson.M{"$in": ids}},
+		notDeleted,
+		primitive.E{Key: "completed", Value: false},
+	})
+	todos, err := r.filterTodos(ctx, filter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := Now()
+	update := bson.M{
+		"$set":   bson.M{"completed": true, "completed_at": now, "updated_at": now},
+		"$unset": withoutReminders(bson.M{"pending_text": ""}),
+	}
+
+	unblocked := todos[:0]
+	for _, t := range todos {
+		err := r.checkUnblocked(ctx, t.BlockedBy)
+		var blocked *BlockedError
+		if errors.As(err, &blocked) {
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		unblocked = append(unblocked, t)
+	}
+	todos = unblocked
+
+	var completed int64
+	var spawned, plain []primitive.ObjectID
+	for _, t := range todos {
+		if t.Recurrence == "" {
+			plain = append(plain, t.ID)
+			continue
+		}
+
+		res, err := r.coll.UpdateOne(ctx, bson.D{
+			primitive.E{Key: "_id", Value: t.ID},
+			primitive.E{Key: "completed", Value: false},
+		}, update)
+		if err != nil {
+			return completed, spawned, deadlineError(err)
+		}
+		if res.ModifiedCount == 0 {
+			continue
+		}
+		completed++
+		r.recordHistory(ctx, HistoryComplete, t, []string{"completed", "completed_at"})
+		next, err := r.spawnNextOccurrence(ctx, t)
+		if err != nil {
+			return completed, spawned, err
+		}
+		if next != nil {
+			spawned = append(spawned, next.ID)
+		}
+	}
+	if len(plain) == 0 {
+		return completed, spawned, nil
 	}
 
-	res, err := Collection.DeleteOne(ctx, filter)
+	res, err := r.coll.UpdateMany(ctx, bson.D{
+		primitive.E{Key: "_id", Value: bson.M{"$in": plain}},
+		primitive.E{Key: "completed", Value: false},
+	}, update)
 	if err != nil {
+		return completed, spawned, deadlineError(err)
+	}
+	for _, t := range todos {
+		if t.Recurrence == "" {
+			r.recordHistory(ctx, HistoryComplete, t, []string{"completed", "completed_at"})
+		}
+	}
+	return completed + res.ModifiedCount, spawned, nil
+}
+
+// GetByIDs returns the todos among ids outside the trash, in a single
+// query. Missing ids are skipped.
+func (r *MongoRepository) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*Todo, error) {
+	filter := OwnedBy(ctx, bson.D{
+		primitive.E{Key: "_id", Value: bson.M{"$in": ids}},
+		notDeleted,
+	})
+	todos, err := r.filterTodos(ctx, filter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []*Todo{}, nil
+	}
+	return todos, err
+}
+
+// GetByText returns the todo outside the trash with the given text.
+func (r *MongoRepository) GetByText(ctx context.Context, text string) (*Todo, error) {
+	filter := bson.D{primitive.E{Key: "$and", Value: bson.A{textFilter(text), OwnedBy(ctx, bson.D{notDeleted})}}}
+
+	t := &Todo{}
+	if err := r.coll.FindOne(ctx, filter).Decode(t); err != nil {
+		return nil, deadlineError(err)
+	}
+	return t, nil
+}
+
+func (r *MongoRepository) countTodos(ctx context.Context, filter interface{}) (int64, error) {
+	opts := options.Count()
+	if budget, ok := opBudget(ctx); ok {
+		opts.SetMaxTime(budget)
+	}
+	var count int64
+	err := withRetry(ctx, func() (err error) {
+		count, err = r.coll.CountDocuments(ctx, filter, opts)
 		return err
+	})
+	return count, deadlineError(err)
+}
+
+// Delete moves the todo to the trash and returns it as it was
+// before deletion. It stays restorable until purged. Todos with subtasks
+// are refused with ErrHasChildren. A missing todo is mongo.ErrNoDocuments.
+// The deletion is recorded in the todo's history.
+func (r *MongoRepository) Delete(ctx context.Context, id string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
 	}
 
-	if res.DeletedCount == 0 {
-		return errors.New("no todos were deleted")
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: objectId}, notDeleted})
+	update := bson.M{
+		"$set":   bson.M{"deleted_at": Now()},
+		"$unset": withoutReminders(bson.M{"pending_text": ""}),
 	}
 
-	return nil
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	// Look the todo up first so that the subtasks of someone else's todo
+	// do not give its existence away.
+	n, err := r.coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	if n == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	if err := r.checkNoChildren(ctx, objectId); err != nil {
+		return nil, err
+	}
+
+	t := &Todo{}
+	err = r.coll.FindOneAndUpdate(ctx, filter, update).Decode(t)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	r.recordHistory(ctx, HistoryDelete, t, []string{"deleted_at"})
+
+	return t, nil
 }
 
 func PrintTodos(todos []*Todo) {
+	PrintTodoPage(todos, 0)
+}
+
+// PrintTodoPage prints todos numbered from offset+1, so consecutive pages of
+// a listing keep counting where the previous page stopped. Subtasks are
+// moved under their parent when it is on the same page, reordering todos in
+// place so callers can map numbers back to todos. Due dates are shown in red
+// once they have passed on a pending todo, pinned todos are starred and named
+// colors are shown as a swatch.
+func PrintTodoPage(todos []*Todo, offset int64) {
+	now := Now()
+	depths := treeOrder(todos)
 	for i, v := range todos {
+		n := offset + int64(i) + 1
+		// The prefix is printed on its own so the swatch's color does not
+		// end the color of the rest of the line.
+		prefix := strings.Repeat("    ", depths[i])
+		if v.Pinned {
+			prefix += "★ "
+		}
+		fmt.Print(prefix + colorSwatch(v.Color))
 		if v.Completed {
-			color.Green("%d: %s\n", i+1, v.Text)
+			if v.CompletedAt != nil {
+				color.Green("%d: %s (done %s)\n", n, v.Text, v.CompletedAt.Local().Format(time.DateOnly))
+			} else {
+				color.Green("%d: %s\n", n, v.Text)
+			}
+			continue
+		}
+
+		if v.DueDate == nil {
+			color.Yellow("%d: %s\n", n, v.Text)
+			continue
+		}
+
+		due := v.DueDate.Local().Format(time.DateOnly)
+		if v.DueDate.Before(now) {
+			fmt.Printf("%s %s\n", color.YellowString("%d: %s", n, v.Text), color.RedString("(due %s)", due))
 		} else {
-			color.Yellow("%d: %s\n", i+1, v.Text)
+			color.Yellow("%d: %s (due %s)\n", n, v.Text, due)
 		}
 	}
 }
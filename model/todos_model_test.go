@@ -0,0 +1,65 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTrashedTodoIsNotFound(t *testing.T) {
+	repo := useTestDatabase(t)
+	ctx := context.Background()
+
+	todo := &Todo{ID: primitive.NewObjectID(), CreatedAt: Now(), UpdatedAt: Now(), Text: "water plants"}
+	if err := repo.Create(ctx, todo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Delete(ctx, todo.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.GetByID(ctx, todo.ID.Hex()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("getting a trashed todo: got %v, want ErrNoDocuments", err)
+	}
+	if err := repo.Update(ctx, todo.ID.Hex(), &Todo{Text: "water plants", Completed: true}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("updating a trashed todo: got %v, want ErrNoDocuments", err)
+	}
+}
+
+func BenchmarkStream(b *testing.B) {
+	const total, batch = 100_000, 1_000
+
+	repo := useTestDatabase(b)
+	ctx := context.Background()
+	for start := 0; start < total; start += batch {
+		todos := make([]*Todo, batch)
+		for i := range todos {
+			todos[i] = &Todo{
+				ID:        primitive.NewObjectID(),
+				CreatedAt: Now(),
+				UpdatedAt: Now(),
+				Text:      fmt.Sprintf("todo %d", start+i),
+				Tags:      []string{"bench"},
+			}
+		}
+		if err := repo.CreateMany(ctx, todos); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count, err := repo.Stream(ctx, func(*Todo) error { return nil })
+		if err != nil {
+			b.Fatal(err)
+		}
+		if count != total {
+			b.Fatalf("Stream visited %d todos, want %d", count, total)
+		}
+	}
+}
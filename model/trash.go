@@ -0,0 +1,180 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrTodoNotInTrash = errors.New("todo not found in trash")
+
+// DeletedFilter matches todos in the trash.
+func DeletedFilter() bson.D {
+	return bson.D{
+		primitive.E{Key: "deleted_at", Value: bson.M{"$exists": true}},
+	}
+}
+
+type deletedKey struct{}
+
+// IncludingDeleted returns a context under which the listings, search,
+// stats and export also return todos in the trash, for admins and tooling
+// that need to see them. Reads of a single todo are unaffected.
+func IncludingDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedKey{}, true)
+}
+
+func deletedIncluded(ctx context.Context) bool {
+	included, _ := ctx.Value(deletedKey{}).(bool)
+	return included
+}
+
+// visible drops the notDeleted condition from a listing filter when ctx
+// includes deleted todos.
+func visible(ctx context.Context, filter bson.D) bson.D {
+	if !deletedIncluded(ctx) {
+		return filter
+	}
+	widened := make(bson.D, 0, len(filter))
+	for _, e := range filter {
+		if e.Key != notDeleted.Key {
+			widened = append(widened, e)
+		}
+	}
+	return widened
+}
+
+// Deleted returns the todos in the trash.
+func (r *MongoRepository) Deleted(ctx context.Context) ([]*Todo, error) {
+	todos, err := r.filterTodos(ctx, OwnedBy(ctx, DeletedFilter()))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []*Todo{}, nil
+	}
+	return todos, err
+}
+
+// Restore takes the todo with the given id out of the trash.
+func (r *MongoRepository) Restore(ctx context.Context, id string) (*Todo, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.D{primitive.E{Key: "_id", Value: objectId}}
+	filter = OwnedBy(ctx, append(filter, DeletedFilter()...))
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": ""},
+		"$set":   bson.M{"updated_at": Now()},
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	res, err := r.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrTodoNotInTrash
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+// Purge permanently removes todos that have been in the trash for
+// longer than olderThan, along with their notes and history, and returns how
+// many todos were removed.
+func (r *MongoRepository) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	filter := bson.D{
+		primitive.E{Key: "deleted_at", Value: bson.M{"$lt": Now().Add(-olderThan)}},
+	}
+
+	ids, err := r.coll.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	res, err := r.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = r.notesCollection().DeleteMany(ctx, bson.M{"todo_id": bson.M{"$in": ids}})
+	if err != nil {
+		return res.DeletedCount, err
+	}
+	_, err = r.historyCollection().DeleteMany(ctx, bson.M{"todo_id": bson.M{"$in": ids}})
+	return res.DeletedCount, err
+}
+
+// PurgeTodo permanently removes the todo with the given id from the trash,
+// along with its notes and history, without waiting for Purge.
+func (r *MongoRepository) PurgeTodo(ctx context.Context, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := bson.D{primitive.E{Key: "_id", Value: objectId}}
+	res, err := r.coll.DeleteOne(ctx, OwnedBy(ctx, append(filter, DeletedFilter()...)))
+	if err != nil {
+		return deadlineError(err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrTodoNotInTrash
+	}
+
+	if _, err := r.notesCollection().DeleteMany(ctx, bson.M{"todo_id": objectId}); err != nil {
+		return deadlineError(err)
+	}
+	_, err = r.historyCollection().DeleteMany(ctx, bson.M{"todo_id": objectId})
+	return deadlineError(err)
+}
+
+// DeleteCompleted permanently removes the completed todos ctx can see, in
+// the trash or not, along with their notes and history, and returns how many
+// todos were removed. Completed todos that still have subtasks outside the
+// trash are kept, as deleting them would orphan the subtasks.
+func (r *MongoRepository) DeleteCompleted(ctx context.Context) (int64, error) {
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "completed", Value: true}})
+	ids, err := r.coll.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return 0, deadlineError(err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	parents, err := r.coll.Distinct(ctx, "parent_id", bson.D{
+		notDeleted,
+		primitive.E{Key: "parent_id", Value: bson.M{"$in": ids}},
+	})
+	if err != nil {
+		return 0, deadlineError(err)
+	}
+
+	res, err := r.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids, "$nin": parents}})
+	if err != nil {
+		return 0, deadlineError(err)
+	}
+
+	_, err = r.notesCollection().DeleteMany(ctx, bson.M{"todo_id": bson.M{"$in": ids, "$nin": parents}})
+	if err != nil {
+		return res.DeletedCount, deadlineError(err)
+	}
+	_, err = r.historyCollection().DeleteMany(ctx, bson.M{"todo_id": bson.M{"$in": ids, "$nin": parents}})
+	return res.DeletedCount, deadlineError(err)
+}
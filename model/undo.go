@@ -0,0 +1,140 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultUndoWindow = 30 * time.Second
+
+var ErrUndoTokenNotFound = errors.New("undo token is invalid or has expired")
+
+// UndoStore keeps snapshots of todos in Redis for a short window so a
+// deletion or bulk completion can be reversed with the token handed out
+// when it happened.
+type UndoStore struct {
+	client *redis.Client
+	Window time.Duration
+}
+
+// NewUndoStore reads the undo window from UNDO_WINDOW as a Go duration,
+// defaulting to 30 seconds.
+func NewUndoStore(client *redis.Client) (*UndoStore, error) {
+	window, err := undoWindow()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UndoStore{client: client, Window: window}, nil
+}
+
+func undoWindow() (time.Duration, error) {
+	raw := os.Getenv("UNDO_WINDOW")
+	if raw == "" {
+		return defaultUndoWindow, nil
+	}
+	window, err := time.ParseDuration(raw)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("UNDO_WINDOW must be a positive duration, got %q", raw)
+	}
+	return window, nil
+}
+
+// UndoSnapshot is what an undo token restores: the todos as they were
+// before the operation it reverses.
+type UndoSnapshot struct {
+	// Owner is the user who may use the token, empty outside a scope.
+	Owner string  `bson:"owner,omitempty"`
+	Todos []*Todo `bson:"todos"`
+	// Bulk is set when the snapshot reverses a bulk operation.
+	Bulk bool `bson:"bulk,omitempty"`
+	// Spawned are the next occurrences of recurring todos the operation
+	// created, deleted again by undo.
+	Spawned []primitive.ObjectID `bson:"spawned,omitempty"`
+}
+
+// Save stores snapshot for the owner of ctx and returns the token that
+// restores it.
+func (s *UndoStore) Save(ctx context.Context, snapshot *UndoSnapshot) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	snapshot.Owner, _ = ownerFrom(ctx)
+	encoded, err := bson.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.client.Set(ctx, undoKey(token), encoded, s.Window).Err()
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Take consumes token and returns the snapshot it was saved with. Tokens
+// are single-use, and only the owner of ctx that saved a token can use it:
+// to anyone else it does not exist, and it stays valid for its owner.
+func (s *UndoStore) Take(ctx context.Context, token string) (*UndoSnapshot, error) {
+	key := undoKey(token)
+	encoded, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrUndoTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &UndoSnapshot{}
+	if err := bson.Unmarshal(encoded, snapshot); err != nil {
+		return nil, err
+	}
+	if owner, _ := ownerFrom(ctx); snapshot.Owner != owner {
+		return nil, ErrUndoTokenNotFound
+	}
+
+	// Whoever deletes the key first has used the token.
+	n, err := s.client.Del(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrUndoTokenNotFound
+	}
+
+	for _, t := range snapshot.Todos {
+		// A todo restored by undo is not held to pendingTextIndex, as the
+		// text may have been reused since.
+		t.PendingText = ""
+	}
+	return snapshot, nil
+}
+
+// Replace writes t back over the stored todo with its id, taking it out
+// of the trash or reinserting it if it was purged meanwhile.
+func (r *MongoRepository) Replace(ctx context.Context, t *Todo) error {
+	ctx, cancel := writeContext(ctx)
+	defer cancel()
+
+	filter := OwnedBy(ctx, bson.D{primitive.E{Key: "_id", Value: t.ID}})
+	_, err := r.coll.ReplaceOne(ctx, filter, t, options.Replace().SetUpsert(true))
+	return duplicateError(deadlineError(err))
+}
+
+func undoKey(token string) string {
+	return "undo:" + token
+}
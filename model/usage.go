@@ -0,0 +1,195 @@
+package model
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	usageKeyPrefix = "usage:"
+	// usageKeyTTL keeps a day's counters in Redis long enough for several
+	// hourly flushes after the day ends.
+	usageKeyTTL = 72 * time.Hour
+	// usageRetention is how long daily rows are kept; monthly rollups are
+	// kept forever.
+	usageRetention = 396 * 24 * time.Hour
+)
+
+// UsageRow is one user's API usage over a day, or a month for rollups.
+type UsageRow struct {
+	User     string    `json:"user" bson:"user"`
+	Period   time.Time `json:"period" bson:"period"`
+	Requests int64     `json:"requests" bson:"requests"`
+	Bytes    int64     `json:"bytes" bson:"bytes"`
+}
+
+// UsageStore counts requests and bytes per user and day in Redis, and
+// flushes the counters to the usage collections of db.
+type UsageStore struct {
+	client *redis.Client
+	db     *mongo.Database
+}
+
+func NewUsageStore(client *redis.Client, db *mongo.Database) *UsageStore {
+	return &UsageStore{client: client, db: db}
+}
+
+func dailyUsageCollection(db *mongo.Database) *mongo.Collection {
+	return db.Collection("usage")
+}
+
+func monthlyUsageCollection(db *mongo.Database) *mongo.Collection {
+	return db.Collection("usage_monthly")
+}
+
+func usageKey(day time.Time, user string) string {
+	return usageKeyPrefix + day.UTC().Format(time.DateOnly) + ":" + user
+}
+
+// Record adds one request of the given size to user's counters for today
+// in a single pipelined round trip.
+func (s *UsageStore) Record(ctx context.Context, user string, bytes int64) error {
+	key := usageKey(time.Now(), user)
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, "requests", 1)
+		pipe.HIncrBy(ctx, key, "bytes", bytes)
+		pipe.Expire(ctx, key, usageKeyTTL)
+		return nil
+	})
+	return err
+}
+
+// Flush copies every day's counters still in Redis to the daily usage
+// collection and recomputes the monthly rollups they belong to. Redis holds
+// running totals, so flushing twice writes the same values.
+func (s *UsageStore) Flush(ctx context.Context) (int, error) {
+	months := map[time.Time]bool{}
+	flushed := 0
+
+	iter := s.client.Scan(ctx, 0, usageKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		dayPart, user, ok := strings.Cut(strings.TrimPrefix(key, usageKeyPrefix), ":")
+		if !ok {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, dayPart)
+		if err != nil {
+			continue
+		}
+
+		counters, err := s.client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return flushed, err
+		}
+		requests, _ := strconv.ParseInt(counters["requests"], 10, 64)
+		bytes, _ := strconv.ParseInt(counters["bytes"], 10, 64)
+
+		_, err = dailyUsageCollection(s.db).UpdateOne(ctx,
+			bson.M{"user": user, "period": day},
+			bson.M{"$set": bson.M{"requests": requests, "bytes": bytes}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return flushed, err
+		}
+		months[time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)] = true
+		flushed++
+	}
+	if err := iter.Err(); err != nil {
+		return flushed, err
+	}
+
+	for month := range months {
+		if err := s.rollUp(ctx, month); err != nil {
+			return flushed, err
+		}
+	}
+	return flushed, nil
+}
+
+// rollUp rewrites the monthly rows for month from its daily rows.
+func (s *UsageStore) rollUp(ctx context.Context, month time.Time) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"period": bson.M{"$gte": month, "$lt": month.AddDate(0, 1, 0)}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      bson.M{"user": "$user", "period": month},
+			"requests": bson.M{"$sum": "$requests"},
+			"bytes":    bson.M{"$sum": "$bytes"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":      0,
+			"user":     "$_id.user",
+			"period":   "$_id.period",
+			"requests": 1,
+			"bytes":    1,
+		}}},
+		{{Key: "$merge", Value: bson.M{
+			"into":        monthlyUsageCollection(s.db).Name(),
+			"on":          bson.A{"user", "period"},
+			"whenMatched": "replace",
+		}}},
+	}
+	cur, err := dailyUsageCollection(s.db).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cur.Close(ctx)
+}
+
+// RunUsageFlush flushes usage counters once per interval until ctx is
+// cancelled.
+func (s *UsageStore) RunUsageFlush(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := s.Flush(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Flushing usage counters failed: %v", err)
+		}
+	}
+}
+
+// Usage returns daily usage rows between from and to inclusive, for one
+// user or, with an empty user, for everyone. With monthly set it returns
+// the monthly rollups instead.
+func (s *UsageStore) Usage(ctx context.Context, user string, from time.Time, to time.Time, monthly bool) ([]*UsageRow, error) {
+	filter := bson.D{primitive.E{Key: "period", Value: bson.M{"$gte": from, "$lte": to}}}
+	if user != "" {
+		filter = append(filter, primitive.E{Key: "user", Value: user})
+	}
+
+	collection := dailyUsageCollection(s.db)
+	if monthly {
+		collection = monthlyUsageCollection(s.db)
+	}
+	opts := findOptions(ctx).SetSort(bson.D{
+		primitive.E{Key: "period", Value: 1},
+		primitive.E{Key: "user", Value: 1},
+	})
+	cur, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	rows := []*UsageRow{}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, deadlineError(err)
+	}
+	return rows, nil
+}
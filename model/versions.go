@@ -0,0 +1,48 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrVersionMismatch is returned by Update when the todo changed after the
+// version the caller expected it at.
+var ErrVersionMismatch = errors.New("todo was changed since it was read")
+
+type versionKey struct{}
+
+// WithVersion returns a context under which Update only changes a todo
+// that was last updated at version, so concurrent writers cannot overwrite
+// each other unseen.
+func WithVersion(ctx context.Context, version time.Time) context.Context {
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+func versionFrom(ctx context.Context) (time.Time, bool) {
+	version, ok := ctx.Value(versionKey{}).(time.Time)
+	return version, ok
+}
+
+// sameVersion compares updated_at values at the millisecond precision
+// MongoDB stores them with.
+func sameVersion(a time.Time, b time.Time) bool {
+	return a.UnixMilli() == b.UnixMilli()
+}
+
+// ETag returns the entity tag of the todo's current version, which changes
+// with every update.
+func (t *Todo) ETag() string {
+	return strconv.Quote(strconv.FormatInt(t.UpdatedAt.UnixMilli(), 10))
+}
+
+// ParseETag returns the version an entity tag returned by ETag stands for.
+func ParseETag(etag string) (time.Time, error) {
+	millis, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`), 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("invalid entity tag " + etag)
+	}
+	return time.UnixMilli(millis), nil
+}
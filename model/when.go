@@ -0,0 +1,165 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHour is the time of day a date given without one stands for.
+const defaultHour = 9
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// ParseWhen parses a time written relative to now, such as "tomorrow 9am",
+// "friday at 17:30", "in 2 hours" or "9pm", as well as YYYY-MM-DD,
+// "YYYY-MM-DD HH:MM" and RFC3339. Times of day are read in the location of
+// now. A day without a time means 9am, and a time without a day means its
+// next occurrence. Weekdays are never today, so "monday" on a Monday is a
+// week away.
+func ParseWhen(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	invalid := fmt.Errorf("cannot tell when %q is", value)
+	s := strings.ToLower(strings.Join(strings.Fields(value), " "))
+
+	if t, err := time.ParseInLocation(time.DateOnly+" 15:04", s, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return t.Add(defaultHour * time.Hour), nil
+	}
+	if rest, ok := strings.CutPrefix(s, "in "); ok {
+		d, err := parseWhenDuration(rest)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		return now.Add(d), nil
+	}
+
+	words := strings.Fields(s)
+	day, words, dayGiven := parseWhenDay(words, now)
+	if len(words) > 0 && words[0] == "at" {
+		words = words[1:]
+	}
+	hour, minute := defaultHour, 0
+	if len(words) > 0 {
+		var ok bool
+		if hour, minute, ok = parseClock(strings.Join(words, "")); !ok {
+			return time.Time{}, invalid
+		}
+	} else if !dayGiven {
+		return time.Time{}, invalid
+	}
+
+	t := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+	if !dayGiven && !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parseWhenDay reads the day words such as "tomorrow" or "next friday" at
+// the start of words, returning the day, the words left over and whether
+// a day was given at all.
+func parseWhenDay(words []string, now time.Time) (time.Time, []string, bool) {
+	if len(words) == 0 {
+		return now, words, false
+	}
+	switch words[0] {
+	case "today", "tonight":
+		return now, words[1:], true
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), words[1:], true
+	}
+
+	rest := words
+	if rest[0] == "next" && len(rest) > 1 {
+		rest = rest[1:]
+	}
+	if weekday, ok := weekdayNames[rest[0]]; ok {
+		days := (int(weekday)-int(now.Weekday())+6)%7 + 1
+		return now.AddDate(0, 0, days), rest[1:], true
+	}
+	return now, words, false
+}
+
+// parseClock parses a time of day such as "9am", "9:30pm", "17:30", "noon"
+// or "midnight". A bare number without am or pm is refused as ambiguous.
+func parseClock(s string) (int, int, bool) {
+	switch s {
+	case "noon":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+
+	suffix := ""
+	for _, half := range []string{"am", "pm"} {
+		if base, ok := strings.CutSuffix(s, half); ok {
+			s, suffix = base, half
+		}
+	}
+	h, m, colon := strings.Cut(s, ":")
+	if !colon {
+		m = "0"
+	}
+	hour, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, 0, false
+	}
+	minute, err := strconv.Atoi(m)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, false
+	}
+
+	switch {
+	case suffix == "" && colon && hour >= 0 && hour <= 23:
+		return hour, minute, true
+	case suffix != "" && hour >= 1 && hour <= 12:
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+		return hour, minute, true
+	}
+	return 0, 0, false
+}
+
+// parseWhenDuration parses the duration of "in 2 hours", given as words or
+// as accepted by ParseSnoozeDuration.
+func parseWhenDuration(s string) (time.Duration, error) {
+	if d, err := ParseSnoozeDuration(strings.ReplaceAll(s, " ", "")); err == nil {
+		return d, nil
+	}
+
+	count, unit, ok := strings.Cut(s, " ")
+	n, err := strconv.Atoi(count)
+	if count == "a" || count == "an" {
+		n, err = 1, nil
+	}
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	switch strings.TrimSuffix(unit, "s") {
+	case "minute", "min":
+		return time.Duration(n) * time.Minute, nil
+	case "hour":
+		return time.Duration(n) * time.Hour, nil
+	case "day":
+		return time.Duration(n) * 24 * time.Hour, nil
+	case "week":
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid duration %q", s)
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	// A Wednesday afternoon.
+	now := time.Date(2025, time.March, 5, 14, 20, 0, 0, time.UTC)
+	at := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	for _, tc := range []struct {
+		value string
+		want  time.Time
+	}{
+		{"tomorrow 9am", at(time.March, 6, 9, 0)},
+		{"Tomorrow at 9:30 PM", at(time.March, 6, 21, 30)},
+		{"tomorrow", at(time.March, 6, 9, 0)},
+		{"today 17:45", at(time.March, 5, 17, 45)},
+		{"tonight 11pm", at(time.March, 5, 23, 0)},
+		{"friday", at(time.March, 7, 9, 0)},
+		{"next fri noon", at(time.March, 7, 12, 0)},
+		{"wednesday 8am", at(time.March, 12, 8, 0)},
+		{"6pm", at(time.March, 5, 18, 0)},
+		{"9am", at(time.March, 6, 9, 0)},
+		{"12am", at(time.March, 6, 0, 0)},
+		{"midnight", at(time.March, 6, 0, 0)},
+		{"in 2 hours", at(time.March, 5, 16, 20)},
+		{"in 90m", at(time.March, 5, 15, 50)},
+		{"in 3d", at(time.March, 8, 14, 20)},
+		{"in a week", at(time.March, 12, 14, 20)},
+		{"2025-04-01", at(time.April, 1, 9, 0)},
+		{"2025-04-01 07:15", at(time.April, 1, 7, 15)},
+		{"2025-04-01T07:15:00Z", at(time.April, 1, 7, 15)},
+	} {
+		got, err := ParseWhen(tc.value, now)
+		if err != nil {
+			t.Errorf("ParseWhen(%q): %v", tc.value, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("ParseWhen(%q) = %s, want %s", tc.value, got, tc.want)
+		}
+	}
+
+	for _, value := range []string{"", "someday", "tomorrow 9", "13pm", "friday 25:00", "in 0 hours", "in two hours", "next"} {
+		if got, err := ParseWhen(value, now); err == nil {
+			t.Errorf("ParseWhen(%q) = %s, want an error", value, got)
+		}
+	}
+}
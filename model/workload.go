@@ -0,0 +1,204 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxWorkloadDays is the longest range, both ends included, a workload
+// covers.
+const MaxWorkloadDays = 90
+
+// Defaults for WORKLOAD_CAPACITY_MINUTES and
+// WORKLOAD_DEFAULT_ESTIMATE_MINUTES.
+const (
+	defaultWorkloadCapacity = 8 * 60
+	defaultWorkloadEstimate = 30
+)
+
+// ErrWorkloadRange is returned for a workload ending before it starts or
+// spanning more than MaxWorkloadDays.
+var ErrWorkloadRange = fmt.Errorf("the range must end on or after its start and span at most %d days", MaxWorkloadDays)
+
+// WorkloadDay totals the estimated minutes of the pending todos due on one
+// day.
+type WorkloadDay struct {
+	Date    string `json:"date" example:"2024-05-07"`
+	Minutes int64  `json:"minutes" example:"840"`
+	Todos   int64  `json:"todos" example:"9"`
+	// Unestimated counts the todos without an estimate, which were counted
+	// with the default estimate.
+	Unestimated  int64 `json:"unestimated" example:"2"`
+	OverCapacity bool  `json:"over_capacity" example:"true"`
+}
+
+// Workload is the pending work scheduled on each day of a range.
+type Workload struct {
+	From                   string        `json:"from" example:"2024-05-06"`
+	To                     string        `json:"to" example:"2024-05-12"`
+	Timezone               string        `json:"timezone" example:"Europe/Paris"`
+	CapacityMinutes        int           `json:"capacity_minutes" example:"480"`
+	DefaultEstimateMinutes int           `json:"default_estimate_minutes" example:"30"`
+	Days                   []WorkloadDay `json:"days"`
+}
+
+// WorkloadQuery selects the days a workload covers and how it is counted.
+// From and To are dates in Location, only their year, month and day count.
+type WorkloadQuery struct {
+	From            time.Time
+	To              time.Time
+	Location        *time.Location
+	CapacityMinutes int
+	DefaultEstimate int
+}
+
+// WorkloadSettings returns the daily capacity and the estimate of todos
+// without one, in minutes, read from WORKLOAD_CAPACITY_MINUTES and
+// WORKLOAD_DEFAULT_ESTIMATE_MINUTES.
+func WorkloadSettings() (capacity int, estimate int, err error) {
+	capacity, estimate = defaultWorkloadCapacity, defaultWorkloadEstimate
+	if raw := os.Getenv("WORKLOAD_CAPACITY_MINUTES"); raw != "" {
+		if capacity, err = strconv.Atoi(raw); err != nil || capacity < 1 {
+			return 0, 0, fmt.Errorf("WORKLOAD_CAPACITY_MINUTES must be a positive number of minutes, got %q", raw)
+		}
+	}
+	if raw := os.Getenv("WORKLOAD_DEFAULT_ESTIMATE_MINUTES"); raw != "" {
+		if estimate, err = strconv.Atoi(raw); err != nil || estimate < 0 {
+			return 0, 0, fmt.Errorf("WORKLOAD_DEFAULT_ESTIMATE_MINUTES must be a number of minutes, got %q", raw)
+		}
+	}
+	return capacity, estimate, nil
+}
+
+// workloadWindow is the range of days of a workload in its location.
+type workloadWindow struct {
+	query WorkloadQuery
+	start time.Time
+	end   time.Time
+	today time.Time
+}
+
+func newWorkloadWindow(query WorkloadQuery) (workloadWindow, error) {
+	if query.Location == nil {
+		query.Location = time.UTC
+	}
+	day := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, query.Location)
+	}
+	w := workloadWindow{query: query, start: day(query.From), end: day(query.To).AddDate(0, 0, 1)}
+	if !w.end.After(w.start) || w.start.AddDate(0, 0, MaxWorkloadDays).Before(w.end) {
+		return w, ErrWorkloadRange
+	}
+	w.today = day(Now().In(query.Location))
+	return w, nil
+}
+
+// empty returns a workload with no work on each of its days, along with
+// those days by date.
+func (w workloadWindow) empty() (*Workload, map[string]*WorkloadDay) {
+	workload := &Workload{
+		From:                   w.start.Format(time.DateOnly),
+		To:                     w.end.AddDate(0, 0, -1).Format(time.DateOnly),
+		Timezone:               w.query.Location.String(),
+		CapacityMinutes:        w.query.CapacityMinutes,
+		DefaultEstimateMinutes: w.query.DefaultEstimate,
+	}
+	for start := w.start; start.Before(w.end); start = start.AddDate(0, 0, 1) {
+		workload.Days = append(workload.Days, WorkloadDay{Date: start.Format(time.DateOnly)})
+	}
+	days := make(map[string]*WorkloadDay, len(workload.Days))
+	for i := range workload.Days {
+		days[workload.Days[i].Date] = &workload.Days[i]
+	}
+	return workload, days
+}
+
+// add counts the todos due on date, flagging the day once it is over
+// capacity. Dates outside the window are ignored.
+func (w workloadWindow) add(days map[string]*WorkloadDay, date string, minutes int64, todos int64, unestimated int64) {
+	day, ok := days[date]
+	if !ok {
+		return
+	}
+	day.Minutes += minutes
+	day.Todos += todos
+	day.Unestimated += unestimated
+	day.OverCapacity = day.Minutes > int64(w.query.CapacityMinutes)
+}
+
+// dueDay returns the date a todo due at due is counted on: its due date in
+// the window's location, or today once it is overdue.
+func (w workloadWindow) dueDay(due time.Time) string {
+	if due.Before(w.today) {
+		return w.today.Format(time.DateOnly)
+	}
+	return due.In(w.query.Location).Format(time.DateOnly)
+}
+
+// Workload totals the estimated minutes of the pending todos outside the
+// trash and the archive by due date in query.Location, counting overdue
+// todos into today and those without an estimate with the default. A range
+// ending before it starts or longer than MaxWorkloadDays is
+// ErrWorkloadRange.
+func (r *MongoRepository) Workload(ctx context.Context, query WorkloadQuery) (*Workload, error) {
+	w, err := newWorkloadWindow(query)
+	if err != nil {
+		return nil, err
+	}
+	workload, days := w.empty()
+
+	match := OwnedBy(ctx, bson.D{
+		notDeleted,
+		notArchived,
+		primitive.E{Key: "completed", Value: false},
+		primitive.E{Key: "due_date", Value: bson.M{"$lt": w.end}},
+	})
+	estimated := bson.M{"$gt": bson.A{"$estimate_minutes", 0}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$dateToString": bson.M{
+				"format":   "%Y-%m-%d",
+				"date":     bson.M{"$max": bson.A{"$due_date", w.today}},
+				"timezone": w.query.Location.String(),
+			}},
+			"minutes": bson.M{"$sum": bson.M{
+				"$cond": bson.A{estimated, "$estimate_minutes", w.query.DefaultEstimate},
+			}},
+			"todos": bson.M{"$sum": 1},
+			"unestimated": bson.M{"$sum": bson.M{
+				"$cond": bson.A{estimated, 0, 1},
+			}},
+		}}},
+	}
+
+	var cur *mongo.Cursor
+	err = withRetry(ctx, func() (err error) {
+		cur, err = r.coll.Aggregate(ctx, pipeline, aggregateOptions(ctx))
+		return err
+	})
+	if err != nil {
+		return nil, deadlineError(err)
+	}
+
+	var rows []struct {
+		Date        string `bson:"_id"`
+		Minutes     int64  `bson:"minutes"`
+		Todos       int64  `bson:"todos"`
+		Unestimated int64  `bson:"unestimated"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, deadlineError(err)
+	}
+	for _, row := range rows {
+		w.add(days, row.Date, row.Minutes, row.Todos, row.Unestimated)
+	}
+	return workload, nil
+}